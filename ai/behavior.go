@@ -0,0 +1,213 @@
+package ai
+
+import (
+    "encoding/json"
+    "errors"
+    "fmt"
+    "io"
+    "sort"
+)
+
+// ActionDef describes a GOAP action as written in a behavior file
+type ActionDef struct {
+    Name          string    `json:"name"`
+    Cost          float64   `json:"cost"`
+    Preconditions GOAPState `json:"preconditions"`
+    Effects       GOAPState `json:"effects"`
+}
+
+// GoalDef describes a goal as written in a behavior file.
+// The goal is relevant when every fact in When matches the current state, and its
+// utility is Priority plus the weighted sum of its considerations.
+type GoalDef struct {
+    Name string    `json:"name"`
+    When GOAPState `json:"when"`
+    Goal GOAPState `json:"goal"`
+    // Priority is the base utility. Goals without one are ranked by their position in the file.
+    Priority       *float64           `json:"priority"`
+    Considerations []ConsiderationDef `json:"considerations"`
+}
+
+// ConsiderationDef adds Weight times a named input, usually in the 0..1 range, to a goal's utility
+type ConsiderationDef struct {
+    Input  string  `json:"input"`
+    Weight float64 `json:"weight"`
+}
+
+// Vocabulary lists the names a behavior file may refer to
+type Vocabulary struct {
+    // IsSensed reports whether a fact is present in the state built by the agent
+    IsSensed func(fact string) bool
+    // HasExecutor reports whether an action can be executed by the agent
+    HasExecutor func(action string) bool
+    // HasInput reports whether a consideration input can be computed for the agent
+    HasInput func(input string) bool
+}
+
+// Behavior is a set of actions and the goals they are planned for
+type Behavior struct {
+    Actions []ActionDef `json:"actions"`
+    Goals   []GoalDef   `json:"goals"`
+}
+
+// ParseBehavior decodes a behavior definition from JSON and checks that it is well formed.
+// Facts and action names are not checked here, see Behavior.Validate.
+func ParseBehavior(r io.Reader) (*Behavior, error) {
+    var b Behavior
+    decoder := json.NewDecoder(r)
+    decoder.DisallowUnknownFields()
+    if err := decoder.Decode(&b); err != nil {
+        return nil, fmt.Errorf("decode behavior: %w", err)
+    }
+    if err := b.checkStructure(); err != nil {
+        return nil, err
+    }
+    return &b, nil
+}
+
+func (b *Behavior) checkStructure() error {
+    var errs []error
+    if len(b.Actions) == 0 {
+        errs = append(errs, errors.New("behavior has no actions"))
+    }
+    if len(b.Goals) == 0 {
+        errs = append(errs, errors.New("behavior has no goals"))
+    }
+
+    names := make(map[string]bool)
+    for i, action := range b.Actions {
+        if action.Name == "" {
+            errs = append(errs, fmt.Errorf("action %d has no name", i))
+        } else if names[action.Name] {
+            errs = append(errs, fmt.Errorf("action %q is defined twice", action.Name))
+        }
+        names[action.Name] = true
+        if action.Cost < 0 {
+            errs = append(errs, fmt.Errorf("action %q has negative cost %v", action.Name, action.Cost))
+        }
+        if len(action.Effects) == 0 {
+            errs = append(errs, fmt.Errorf("action %q has no effects", action.Name))
+        }
+        errs = append(errs, checkValues("action "+action.Name+" preconditions", action.Preconditions))
+        errs = append(errs, checkValues("action "+action.Name+" effects", action.Effects))
+    }
+
+    for i, goal := range b.Goals {
+        if goal.Name == "" {
+            errs = append(errs, fmt.Errorf("goal %d has no name", i))
+        }
+        if len(goal.Goal) == 0 {
+            errs = append(errs, fmt.Errorf("goal %q has an empty goal state", goal.Name))
+        }
+        errs = append(errs, checkValues("goal "+goal.Name+" conditions", goal.When))
+        errs = append(errs, checkValues("goal "+goal.Name+" state", goal.Goal))
+        for _, c := range goal.Considerations {
+            if c.Input == "" {
+                errs = append(errs, fmt.Errorf("goal %q has a consideration without input", goal.Name))
+            }
+        }
+    }
+    return errors.Join(errs...)
+}
+
+// checkValues rejects lists and objects, which cannot be compared as fact values
+func checkValues(where string, state GOAPState) error {
+    var errs []error
+    for _, fact := range sortedFacts(state) {
+        switch state[fact].(type) {
+        case bool, float64, string:
+        default:
+            errs = append(errs, fmt.Errorf("%s: fact %q must be a boolean, number or string", where, fact))
+        }
+    }
+    return errors.Join(errs...)
+}
+
+// sortedFacts returns the keys of a state in a stable order for error reporting
+func sortedFacts(state GOAPState) []string {
+    facts := make([]string, 0, len(state))
+    for fact := range state {
+        facts = append(facts, fact)
+    }
+    sort.Strings(facts)
+    return facts
+}
+
+// Validate checks that every fact is either sensed or produced by an action effect,
+// that goal conditions only use sensed facts, that every action and consideration
+// input is known to the agent, and that the actions stay within the planner's fact limit.
+func (b *Behavior) Validate(v Vocabulary) error {
+    var errs []error
+    isSensed := v.IsSensed
+    produced := make(map[string]bool)
+    referenced := make(map[string]bool)
+    for _, action := range b.Actions {
+        for fact := range action.Effects {
+            produced[fact] = true
+            referenced[fact] = true
+        }
+        for fact := range action.Preconditions {
+            referenced[fact] = true
+        }
+    }
+    if len(referenced) > maxFacts {
+        errs = append(errs, fmt.Errorf("actions refer to %d facts, the planner supports at most %d", len(referenced), maxFacts))
+    }
+    known := func(fact string) bool {
+        return isSensed(fact) || produced[fact]
+    }
+
+    for _, action := range b.Actions {
+        if !v.HasExecutor(action.Name) {
+            errs = append(errs, fmt.Errorf("action %q has no registered executor", action.Name))
+        }
+        for _, fact := range sortedFacts(action.Preconditions) {
+            if !known(fact) {
+                errs = append(errs, fmt.Errorf("action %q: unknown precondition fact %q", action.Name, fact))
+            }
+        }
+    }
+
+    for _, goal := range b.Goals {
+        for _, fact := range sortedFacts(goal.When) {
+            if !isSensed(fact) {
+                errs = append(errs, fmt.Errorf("goal %q: condition fact %q is not sensed", goal.Name, fact))
+            }
+        }
+        for _, fact := range sortedFacts(goal.Goal) {
+            if !known(fact) {
+                errs = append(errs, fmt.Errorf("goal %q: unknown goal fact %q", goal.Name, fact))
+            }
+        }
+        for _, c := range goal.Considerations {
+            if !v.HasInput(c.Input) {
+                errs = append(errs, fmt.Errorf("goal %q: unknown consideration input %q", goal.Name, c.Input))
+            }
+        }
+    }
+    return errors.Join(errs...)
+}
+
+// NewPlanner creates a planner with the behavior's actions
+func (b *Behavior) NewPlanner() *GOAPPlanner {
+    p := NewGOAPPlanner()
+    for _, def := range b.Actions {
+        p.AddAction(GOAPAction{
+            Name:          def.Name,
+            Cost:          def.Cost,
+            Preconditions: def.Preconditions,
+            Effects:       def.Effects,
+        })
+    }
+    return p
+}
+
+// matchesState checks that every fact in conds has the same value in state
+func matchesState(state, conds GOAPState) bool {
+    for k, v := range conds {
+        if state[k] != v {
+            return false
+        }
+    }
+    return true
+}
@@ -0,0 +1,109 @@
+package ai
+
+import (
+    "fmt"
+    "github.com/stretchr/testify/assert"
+    "strings"
+    "testing"
+)
+
+const testBehavior = `{
+  "actions": [
+    {"name": "Eat", "cost": 1, "preconditions": {"hasFood": true}, "effects": {"hungry": false}},
+    {"name": "Forage", "cost": 2, "effects": {"hasFood": true}}
+  ],
+  "goals": [
+    {"name": "Feed", "when": {"hungry": true}, "goal": {"hungry": false}, "priority": 1, "considerations": [{"input": "hunger", "weight": 2}]},
+    {"name": "Stock", "goal": {"hasFood": true}, "priority": 1.5}
+  ]
+}`
+
+func TestBehavior(t *testing.T) {
+    vocabulary := Vocabulary{
+        IsSensed:    func(fact string) bool { return fact == "hungry" },
+        HasExecutor: func(action string) bool { return action == "Eat" || action == "Forage" },
+        HasInput:    func(input string) bool { return input == "hunger" },
+    }
+
+    t.Run("Goals are ranked by utility", func(t *testing.T) {
+        b, err := ParseBehavior(strings.NewReader(testBehavior))
+        assert.NoError(t, err)
+        assert.NoError(t, b.Validate(vocabulary))
+
+        state := GOAPState{"hungry": true}
+        hunger := 0.1
+        input := func(name string) float64 { return hunger }
+        ranked := b.RankGoals(state, input)
+        assert.Equal(t, "Stock", ranked[0].Name)
+        assert.InDelta(t, 1.2, ranked[1].Score, 1e-9)
+
+        hunger = 0.5
+        ranked = b.RankGoals(state, input)
+        assert.Equal(t, "Feed", ranked[0].Name)
+        goal, plan, ok := b.NewPlanner().PlanForGoals(state, ranked)
+        assert.True(t, ok)
+        assert.Equal(t, "Feed", goal.Name)
+        assert.Len(t, plan, 2)
+
+        ranked = b.RankGoals(GOAPState{"hungry": false}, input)
+        assert.Equal(t, "Stock", ranked[0].Name)
+        assert.Zero(t, ranked[1].Score, "Feed is not relevant when not hungry")
+    })
+
+    t.Run("Falls back when a goal is satisfied", func(t *testing.T) {
+        b, err := ParseBehavior(strings.NewReader(testBehavior))
+        assert.NoError(t, err)
+        state := GOAPState{"hungry": false, "hasFood": true}
+        _, _, ok := b.NewPlanner().PlanForGoals(state, b.RankGoals(state, func(string) float64 { return 1 }))
+        assert.False(t, ok)
+
+        state = GOAPState{"hungry": true, "hasFood": true}
+        goal, plan, ok := b.NewPlanner().PlanForGoals(state, []ScoredGoal{
+            {Name: "Stock", State: GOAPState{"hasFood": true}, Score: 5},
+            {Name: "Feed", State: GOAPState{"hungry": false}, Score: 1},
+        })
+        assert.True(t, ok)
+        assert.Equal(t, "Feed", goal.Name)
+        assert.Len(t, plan, 1)
+    })
+
+    t.Run("Unknown facts, inputs and executors", func(t *testing.T) {
+        b, err := ParseBehavior(strings.NewReader(testBehavior))
+        assert.NoError(t, err)
+        err = b.Validate(Vocabulary{
+            IsSensed:    func(string) bool { return false },
+            HasExecutor: func(action string) bool { return action == "Eat" },
+            HasInput:    func(string) bool { return false },
+        })
+        assert.ErrorContains(t, err, `action "Forage" has no registered executor`)
+        assert.ErrorContains(t, err, `goal "Feed": condition fact "hungry" is not sensed`)
+        assert.ErrorContains(t, err, `goal "Feed": unknown consideration input "hunger"`)
+    })
+
+    t.Run("Too many facts for the planner", func(t *testing.T) {
+        b := &Behavior{Goals: []GoalDef{{Name: "Done", Goal: GOAPState{"fact0": true}}}}
+        for i := 0; i <= maxFacts; i++ {
+            fact := fmt.Sprintf("fact%d", i)
+            b.Actions = append(b.Actions, ActionDef{Name: "Set" + fact, Effects: GOAPState{fact: true}})
+        }
+        anything := Vocabulary{
+            IsSensed:    func(string) bool { return true },
+            HasExecutor: func(string) bool { return true },
+            HasInput:    func(string) bool { return true },
+        }
+        assert.ErrorContains(t, b.Validate(anything), "actions refer to 65 facts, the planner supports at most 64")
+
+        b.Actions = b.Actions[:maxFacts]
+        assert.NoError(t, b.Validate(anything))
+    })
+
+    t.Run("Malformed behavior", func(t *testing.T) {
+        _, err := ParseBehavior(strings.NewReader(`{"actions": [{"name": "A", "cost": -1, "effects": {"x": [1]}}], "goals": []}`))
+        assert.ErrorContains(t, err, "negative cost")
+        assert.ErrorContains(t, err, "must be a boolean, number or string")
+        assert.ErrorContains(t, err, "no goals")
+
+        _, err = ParseBehavior(strings.NewReader(`{"actions": [], "typo": 1}`))
+        assert.ErrorContains(t, err, "unknown field")
+    })
+}
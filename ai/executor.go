@@ -0,0 +1,217 @@
+package ai
+
+import (
+    "maps"
+    "slices"
+)
+
+// ActionStatus is the result of running an action for one tick
+type ActionStatus int
+
+const (
+    ActionRunning ActionStatus = iota
+    ActionSucceeded
+    ActionFailed
+)
+
+func (s ActionStatus) String() string {
+    switch s {
+    case ActionRunning:
+        return "running"
+    case ActionSucceeded:
+        return "succeeded"
+    case ActionFailed:
+        return "failed"
+    }
+    return "unknown"
+}
+
+// ActionRunner carries out the actions of a plan for an agent
+type ActionRunner interface {
+    // StartAction is called before the first tick of an action. Returning false fails it.
+    StartAction(action GOAPAction) bool
+    // TickAction runs the action for one tick
+    TickAction(action GOAPAction) ActionStatus
+    // AbortAction is called when a started action is interrupted before it finished
+    AbortAction(action GOAPAction)
+}
+
+// PlanExecutor keeps a plan across ticks and runs its actions one after another.
+// Plan holds the remaining actions, the first one being the current action.
+type PlanExecutor struct {
+    Goal    ScoredGoal
+    Plan    []GOAPAction
+    Started bool
+
+    // unreachable are the goals the last Replan found no plan for, from unreachableIn
+    unreachable   []string
+    unreachableIn GOAPState
+}
+
+// Current returns the action being executed
+func (e *PlanExecutor) Current() (GOAPAction, bool) {
+    if len(e.Plan) == 0 {
+        return GOAPAction{}, false
+    }
+    return e.Plan[0], true
+}
+
+// SetPlan replaces the plan, aborting the current action if it was started.
+// A plan with the same goal and remaining actions keeps the current action running.
+func (e *PlanExecutor) SetPlan(goal ScoredGoal, plan []GOAPAction, runner ActionRunner) {
+    if goal.Name == e.Goal.Name && samePlan(plan, e.Plan) {
+        e.Goal = goal
+        return
+    }
+    e.Abort(runner)
+    e.Goal = goal
+    e.Plan = plan
+}
+
+// samePlan compares two plans by action name
+func samePlan(a, b []GOAPAction) bool {
+    if len(a) != len(b) {
+        return false
+    }
+    for i := range a {
+        if a[i].Name != b[i].Name {
+            return false
+        }
+    }
+    return true
+}
+
+// Abort interrupts the current action and drops the plan
+func (e *PlanExecutor) Abort(runner ActionRunner) {
+    if action, ok := e.Current(); ok && e.Started {
+        runner.AbortAction(action)
+    }
+    e.Goal = ScoredGoal{}
+    e.Plan = nil
+    e.Started = false
+}
+
+// Refresh checks the remaining plan against the current state. Actions whose
+// outcome is already in place are skipped, so the plan resumes from the latest
+// action that can still run. It reports false when the plan is empty, already
+// reached its goal, or can no longer reach it and must be replaced.
+func (e *PlanExecutor) Refresh(state GOAPState, runner ActionRunner) bool {
+    if len(e.Plan) == 0 || matchesState(state, e.Goal.State) {
+        return false
+    }
+    for skip := len(e.Plan) - 1; skip >= 0; skip-- {
+        if !PlanValid(state, e.Plan[skip:], e.Goal.State) {
+            continue
+        }
+        if skip > 0 {
+            if e.Started {
+                runner.AbortAction(e.Plan[0])
+            }
+            e.Plan = e.Plan[skip:]
+            e.Started = false
+        }
+        return true
+    }
+    return false
+}
+
+// Replan plans for the most useful goal that needs and has a plan and switches to it,
+// dropping the current plan when there is none. The goals ranked above it that no plan
+// reaches are remembered, so they do not outrank the new plan until the state changes.
+// It reports whether there is a plan to execute.
+func (e *PlanExecutor) Replan(planner *GOAPPlanner, state GOAPState, ranked []ScoredGoal, runner ActionRunner) bool {
+    goal, plan, ok := planner.PlanForGoals(state, ranked)
+    e.unreachable = e.unreachable[:0]
+    for _, g := range ranked {
+        if ok && g.Name == goal.Name {
+            break
+        }
+        if g.Score > 0 && !matchesState(state, g.State) {
+            e.unreachable = append(e.unreachable, g.Name)
+        }
+    }
+    e.unreachableIn = maps.Clone(state)
+    if !ok {
+        e.Abort(runner)
+        return false
+    }
+    e.SetPlan(goal, plan, runner)
+    return true
+}
+
+// Outranked reports whether the plan's goal is no longer relevant, or a goal with a
+// higher score is relevant, not yet satisfied and was not found unreachable by the last
+// Replan in the same state. Ranked must be sorted by score.
+func (e *PlanExecutor) Outranked(state GOAPState, ranked []ScoredGoal) bool {
+    current := 0.0
+    for _, goal := range ranked {
+        if goal.Name == e.Goal.Name {
+            current = goal.Score
+        }
+    }
+    if current <= 0 {
+        return true
+    }
+    for _, goal := range ranked {
+        if goal.Score <= current {
+            return false
+        }
+        if matchesState(state, goal.State) {
+            continue
+        }
+        if !slices.Contains(e.unreachable, goal.Name) || !maps.Equal(state, e.unreachableIn) {
+            return true
+        }
+    }
+    return false
+}
+
+// Tick runs the current action for one tick. It returns ActionSucceeded once the
+// last action of the plan succeeded and ActionFailed if an action failed, in
+// which case the plan is dropped.
+func (e *PlanExecutor) Tick(runner ActionRunner) ActionStatus {
+    action, ok := e.Current()
+    if !ok {
+        return ActionSucceeded
+    }
+    if !e.Started {
+        if !runner.StartAction(action) {
+            e.Abort(runner)
+            return ActionFailed
+        }
+        e.Started = true
+    }
+
+    switch status := runner.TickAction(action); status {
+    case ActionSucceeded:
+        e.Plan = e.Plan[1:]
+        e.Started = false
+        if len(e.Plan) == 0 {
+            return ActionSucceeded
+        }
+        return ActionRunning
+    case ActionFailed:
+        e.Started = false
+        e.Abort(runner)
+        return ActionFailed
+    default:
+        return ActionRunning
+    }
+}
+
+// PlanValid reports whether the actions can run in order starting from state and reach the goal
+func PlanValid(state GOAPState, plan []GOAPAction, goal GOAPState) bool {
+    simulated := make(GOAPState, len(state))
+    for k, v := range state {
+        simulated[k] = v
+    }
+    for _, action := range plan {
+        if !matchesState(simulated, action.Preconditions) {
+            return false
+        }
+        for k, v := range action.Effects {
+            simulated[k] = v
+        }
+    }
+    return matchesState(simulated, goal)
+}
@@ -0,0 +1,124 @@
+package ai
+
+import (
+    "github.com/stretchr/testify/assert"
+    "testing"
+)
+
+// scriptedRunner returns queued statuses per action and records the hook calls
+type scriptedRunner struct {
+    statuses map[string][]ActionStatus
+    calls    []string
+}
+
+func (r *scriptedRunner) StartAction(action GOAPAction) bool {
+    r.calls = append(r.calls, "start "+action.Name)
+    return true
+}
+
+func (r *scriptedRunner) TickAction(action GOAPAction) ActionStatus {
+    r.calls = append(r.calls, "tick "+action.Name)
+    queue := r.statuses[action.Name]
+    if len(queue) == 0 {
+        return ActionRunning
+    }
+    r.statuses[action.Name] = queue[1:]
+    return queue[0]
+}
+
+func (r *scriptedRunner) AbortAction(action GOAPAction) {
+    r.calls = append(r.calls, "abort "+action.Name)
+}
+
+func TestPlanExecutor(t *testing.T) {
+    forage := GOAPAction{Name: "Forage", Effects: GOAPState{"hasFood": true}}
+    eat := GOAPAction{Name: "Eat", Preconditions: GOAPState{"hasFood": true}, Effects: GOAPState{"hungry": false}}
+    feed := ScoredGoal{Name: "Feed", State: GOAPState{"hungry": false}, Score: 1}
+
+    t.Run("Actions run across ticks until the plan succeeds", func(t *testing.T) {
+        runner := &scriptedRunner{statuses: map[string][]ActionStatus{
+            "Forage": {ActionRunning, ActionSucceeded},
+            "Eat":    {ActionSucceeded},
+        }}
+        var e PlanExecutor
+        e.SetPlan(feed, []GOAPAction{forage, eat}, runner)
+
+        assert.Equal(t, ActionRunning, e.Tick(runner))
+        assert.Equal(t, ActionRunning, e.Tick(runner))
+        assert.Equal(t, ActionSucceeded, e.Tick(runner))
+        assert.Equal(t, []string{"start Forage", "tick Forage", "tick Forage", "start Eat", "tick Eat"}, runner.calls)
+    })
+
+    t.Run("A failed action drops the plan", func(t *testing.T) {
+        runner := &scriptedRunner{statuses: map[string][]ActionStatus{"Forage": {ActionFailed}}}
+        var e PlanExecutor
+        e.SetPlan(feed, []GOAPAction{forage, eat}, runner)
+
+        assert.Equal(t, ActionFailed, e.Tick(runner))
+        assert.Empty(t, e.Plan)
+        assert.False(t, e.Refresh(GOAPState{"hungry": true}, runner))
+    })
+
+    t.Run("Refresh skips actions whose outcome is already in place", func(t *testing.T) {
+        runner := &scriptedRunner{}
+        var e PlanExecutor
+        e.SetPlan(feed, []GOAPAction{forage, eat}, runner)
+        e.Tick(runner)
+
+        assert.True(t, e.Refresh(GOAPState{"hungry": true, "hasFood": false}, runner))
+        assert.True(t, e.Refresh(GOAPState{"hungry": true, "hasFood": true}, runner))
+        current, _ := e.Current()
+        assert.Equal(t, "Eat", current.Name)
+        assert.Contains(t, runner.calls, "abort Forage")
+    })
+
+    t.Run("Refresh rejects a plan that cannot reach its goal", func(t *testing.T) {
+        runner := &scriptedRunner{}
+        var e PlanExecutor
+        e.SetPlan(feed, []GOAPAction{eat}, runner)
+
+        assert.False(t, e.Refresh(GOAPState{"hungry": true, "hasFood": false}, runner))
+        assert.False(t, e.Refresh(GOAPState{"hungry": false, "hasFood": true}, runner))
+    })
+
+    t.Run("Only a more useful unsatisfied goal outranks the plan", func(t *testing.T) {
+        runner := &scriptedRunner{}
+        var e PlanExecutor
+        e.SetPlan(feed, []GOAPAction{forage, eat}, runner)
+        state := GOAPState{"hungry": true, "safe": true}
+
+        assert.False(t, e.Outranked(state, []ScoredGoal{feed}))
+        assert.False(t, e.Outranked(state, []ScoredGoal{{Name: "Hide", State: GOAPState{"safe": true}, Score: 5}, feed}))
+        assert.True(t, e.Outranked(state, []ScoredGoal{{Name: "Flee", State: GOAPState{"safe": false}, Score: 5}, feed}))
+        assert.True(t, e.Outranked(state, []ScoredGoal{{Name: "Feed", State: feed.State}}))
+    })
+
+    t.Run("A goal no plan reaches does not outrank the plan until the state changes", func(t *testing.T) {
+        runner := &scriptedRunner{}
+        planner := NewGOAPPlanner()
+        planner.AddAction(forage)
+        planner.AddAction(eat)
+        flee := ScoredGoal{Name: "Flee", State: GOAPState{"safe": true}, Score: 5}
+        ranked := []ScoredGoal{flee, feed}
+        state := GOAPState{"hungry": true, "hasFood": false, "safe": false}
+
+        var e PlanExecutor
+        assert.True(t, e.Replan(planner, state, ranked, runner))
+        assert.Equal(t, "Feed", e.Goal.Name)
+        assert.False(t, e.Outranked(GOAPState{"hungry": true, "hasFood": false, "safe": false}, ranked))
+        assert.True(t, e.Outranked(GOAPState{"hungry": true, "hasFood": true, "safe": false}, ranked))
+    })
+
+    t.Run("Setting the same plan keeps the current action running", func(t *testing.T) {
+        runner := &scriptedRunner{}
+        var e PlanExecutor
+        e.SetPlan(feed, []GOAPAction{forage, eat}, runner)
+        e.Tick(runner)
+        e.SetPlan(feed, []GOAPAction{forage, eat}, runner)
+        assert.True(t, e.Started)
+
+        e.SetPlan(ScoredGoal{Name: "Stock", State: GOAPState{"hasFood": true}}, []GOAPAction{forage}, runner)
+        assert.False(t, e.Started)
+        assert.Equal(t, []string{"start Forage", "tick Forage", "abort Forage"}, runner.calls)
+    })
+}
@@ -0,0 +1,50 @@
+package ai
+
+import "sort"
+
+// ScoredGoal is a goal together with its utility for the current state.
+// Goals with a score of zero or less are not relevant.
+type ScoredGoal struct {
+    Name  string
+    State GOAPState
+    Score float64
+}
+
+// RankGoals scores every goal of the behavior and returns them from highest to lowest utility.
+// Goals with equal scores keep their order in the behavior. Input returns the value of a
+// consideration input for the agent being scored.
+func (b *Behavior) RankGoals(state GOAPState, input func(name string) float64) []ScoredGoal {
+    ranked := make([]ScoredGoal, len(b.Goals))
+    for i, goal := range b.Goals {
+        ranked[i] = ScoredGoal{Name: goal.Name, State: goal.Goal}
+        if !matchesState(state, goal.When) {
+            continue
+        }
+        score := float64(len(b.Goals) - i)
+        if goal.Priority != nil {
+            score = *goal.Priority
+        }
+        for _, c := range goal.Considerations {
+            score += c.Weight * input(c.Input)
+        }
+        ranked[i].Score = score
+    }
+    sort.SliceStable(ranked, func(i, j int) bool {
+        return ranked[i].Score > ranked[j].Score
+    })
+    return ranked
+}
+
+// PlanForGoals tries the relevant goals in order and returns the first one that
+// needs and has a plan. Goals that are already satisfied or cannot be reached are skipped.
+func (p *GOAPPlanner) PlanForGoals(start GOAPState, goals []ScoredGoal) (ScoredGoal, []GOAPAction, bool) {
+    for _, goal := range goals {
+        if goal.Score <= 0 {
+            continue
+        }
+        if plan := p.Plan(start, goal.State); len(plan) > 0 {
+            return goal, plan, true
+        }
+    }
+    return ScoredGoal{}, nil, false
+}
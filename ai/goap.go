@@ -1,10 +1,10 @@
 package ai
 
 import (
+    "container/heap"
     "fmt"
     "math"
-    "sort"
-    "strings"
+    "sync/atomic"
 )
 
 // GOAPState represents the world state as a map of string keys to interface{} values
@@ -12,15 +12,22 @@ type GOAPState map[string]interface{}
 
 // GOAPAction represents an action that can be taken in the GOAP system
 type GOAPAction struct {
-    Name          string
+    Name string
+    // Cost is the static cost of the action. It is used when CostFunc is nil.
+    Cost float64
+    // CostFunc computes a state-dependent cost and overrides Cost when set.
     CostFunc      func(state GOAPState) float64
     Preconditions GOAPState
     Effects       GOAPState
 }
 
-// GOAPPlanner is the main struct for the GOAP system
+// GOAPPlanner is the main struct for the GOAP system.
+// Actions are compiled into an internal fact table the first time Plan is called
+// and recompiled whenever the Actions slice is replaced or grown. Modifying an
+// action in place after planning is not detected; use AddAction instead.
 type GOAPPlanner struct {
     Actions []GOAPAction
+    domain  atomic.Pointer[goapDomain]
 }
 
 // NewGOAPPlanner creates a new GOAPPlanner
@@ -37,55 +44,20 @@ func (p *GOAPPlanner) AddAction(action GOAPAction) {
 
 // Plan finds the optimal sequence of actions to reach the goal state from the start state
 func (p *GOAPPlanner) Plan(start, goal GOAPState) []GOAPAction {
-    openList := []GOAPState{start}
-    cameFrom := make(map[string]GOAPState)
-    gScore := make(map[string]float64)
-    fScore := make(map[string]float64)
-    actionTaken := make(map[string]GOAPAction)
-
-    startKey := stateToString(start)
-    gScore[startKey] = 0
-    fScore[startKey] = p.heuristic(start, goal)
-
-    for len(openList) > 0 {
-        current := p.lowestFScore(openList, fScore)
-        currentKey := stateToString(current)
-        if p.stateEquals(current, goal) {
-            return p.reconstructPath(cameFrom, actionTaken, current)
-        }
-
-        openList = p.removeState(openList, current)
-
-        for _, action := range p.Actions {
-            if p.canExecuteAction(current, action) {
-                neighbor := p.applyEffects(current, action.Effects)
-                neighborKey := stateToString(neighbor)
-                tentativeGScore := gScore[currentKey] + action.CostFunc(current)
-
-                if _, exists := gScore[neighborKey]; !exists || tentativeGScore < gScore[neighborKey] {
-                    cameFrom[neighborKey] = current
-                    actionTaken[neighborKey] = action
-                    gScore[neighborKey] = tentativeGScore
-                    fScore[neighborKey] = gScore[neighborKey] + p.heuristic(neighbor, goal)
-                    if !p.containsState(openList, neighbor) {
-                        openList = append(openList, neighbor)
-                    }
-                }
-            }
-        }
-    }
-
-    return nil // No plan found
+    d := p.compiled()
+    return d.search(d.actions, start, goal)
 }
 
 // PlanWithStateSelection plans actions using state-dependent action selection
 func (p *GOAPPlanner) PlanWithStateSelection(start, goal GOAPState) []GOAPAction {
-    relevantActions := p.SelectActions(start)
-    oldActions := p.Actions
-    p.Actions = relevantActions
-    plan := p.Plan(start, goal)
-    p.Actions = oldActions
-    return plan
+    d := p.compiled()
+    relevantActions := make([]compiledAction, 0, len(d.actions))
+    for _, action := range d.actions {
+        if p.isActionRelevant(start, *action.action) {
+            relevantActions = append(relevantActions, action)
+        }
+    }
+    return d.search(relevantActions, start, goal)
 }
 
 // SelectActions filters actions based on the current state
@@ -105,105 +77,319 @@ func (p *GOAPPlanner) isActionRelevant(state GOAPState, action GOAPAction) bool
     return true
 }
 
-// heuristic estimates the cost from a state to the goal state
-func (p *GOAPPlanner) heuristic(state, goal GOAPState) float64 {
-    // Simple heuristic: count the number of mismatched goals
-    count := 0
-    for k, v := range goal {
-        if state[k] != v {
-            count++
-        }
+// compiled returns the compiled domain for the current Actions, rebuilding it if they changed
+func (p *GOAPPlanner) compiled() *goapDomain {
+    d := p.domain.Load()
+    if d != nil && d.matches(p.Actions) {
+        return d
     }
-    return float64(count)
+    d = compileDomain(p.Actions)
+    p.domain.Store(d)
+    return d
+}
+
+// maxFacts is the number of distinct fact keys a planner's actions may reference
+const maxFacts = 64
+
+const (
+    // unsetValue marks a fact that is not present in a state
+    unsetValue uint8 = 0
+    // otherValue marks a start-state value that no action or goal refers to
+    otherValue uint8 = math.MaxUint8
+)
+
+// packedState is the compact, comparable form of a GOAPState used during search.
+// Each fact is interned to an index and each of its values to a small integer.
+type packedState [maxFacts]uint8
+
+// factCond is a single "fact has value" requirement or assignment
+type factCond struct {
+    fact  uint8
+    value uint8
+}
+
+type compiledAction struct {
+    action        *GOAPAction
+    preconditions []factCond
+    effects       []factCond
+}
+
+// goapDomain holds the interned facts and actions of a planner
+type goapDomain struct {
+    source    []GOAPAction
+    facts     map[string]uint8
+    factNames []string
+    values    []map[interface{}]uint8
+    valueList [][]interface{}
+    actions   []compiledAction
 }
 
-// lowestFScore finds the state with the lowest fScore in the open list
-func (p *GOAPPlanner) lowestFScore(list []GOAPState, fScore map[string]float64) GOAPState {
-    lowestF := math.Inf(1)
-    var lowestState GOAPState
-    for _, state := range list {
-        key := stateToString(state)
-        if f, exists := fScore[key]; exists && f < lowestF {
-            lowestF = f
-            lowestState = state
+func compileDomain(actions []GOAPAction) *goapDomain {
+    d := &goapDomain{
+        source: actions,
+        facts:  make(map[string]uint8),
+    }
+    d.actions = make([]compiledAction, len(actions))
+    for i := range actions {
+        action := &actions[i]
+        d.actions[i] = compiledAction{
+            action:        action,
+            preconditions: d.internState(action.Preconditions),
+            effects:       d.internState(action.Effects),
         }
     }
-    return lowestState
+    return d
 }
 
-// stateEquals checks if two states are equal
-func (p *GOAPPlanner) stateEquals(current, goal GOAPState) bool {
-    for k, v := range goal {
-        if bv := current[k]; bv != v {
-            return false
-        }
+// matches reports whether the domain was compiled from the given actions slice
+func (d *goapDomain) matches(actions []GOAPAction) bool {
+    if len(actions) != len(d.source) {
+        return false
     }
-    return true
+    return len(actions) == 0 || &actions[0] == &d.source[0]
 }
 
-// reconstructPath builds the sequence of actions from start to goal
-func (p *GOAPPlanner) reconstructPath(cameFrom map[string]GOAPState, actionTaken map[string]GOAPAction, current GOAPState) []GOAPAction {
-    var path []GOAPAction
-    for {
-        currentKey := stateToString(current)
-        action, exists := actionTaken[currentKey]
-        if !exists {
-            break
-        }
-        path = append([]GOAPAction{action}, path...)
-        current = cameFrom[currentKey]
+func (d *goapDomain) internState(state GOAPState) []factCond {
+    conds := make([]factCond, 0, len(state))
+    for k, v := range state {
+        fact := d.internFact(k)
+        conds = append(conds, factCond{fact: fact, value: d.internValue(fact, v)})
     }
-    return path
+    return conds
+}
+
+func (d *goapDomain) internFact(name string) uint8 {
+    if id, ok := d.facts[name]; ok {
+        return id
+    }
+    if len(d.factNames) == maxFacts {
+        panic(fmt.Sprintf("goap: more than %d facts referenced by actions", maxFacts))
+    }
+    id := uint8(len(d.factNames))
+    d.facts[name] = id
+    d.factNames = append(d.factNames, name)
+    d.values = append(d.values, make(map[interface{}]uint8))
+    d.valueList = append(d.valueList, nil)
+    return id
 }
 
-// removeState removes a state from the list of states
-func (p *GOAPPlanner) removeState(list []GOAPState, state GOAPState) []GOAPState {
-    for i, s := range list {
-        if p.stateEquals(s, state) {
-            return append(list[:i], list[i+1:]...)
+func (d *goapDomain) internValue(fact uint8, value interface{}) uint8 {
+    if value == nil {
+        return unsetValue
+    }
+    if id, ok := d.values[fact][value]; ok {
+        return id
+    }
+    if len(d.valueList[fact]) == int(otherValue)-1 {
+        panic(fmt.Sprintf("goap: too many distinct values for fact %q", d.factNames[fact]))
+    }
+    d.valueList[fact] = append(d.valueList[fact], value)
+    id := uint8(len(d.valueList[fact]))
+    d.values[fact][value] = id
+    return id
+}
+
+// lookupValue returns the interned id of a value without growing the domain
+func (d *goapDomain) lookupValue(fact uint8, value interface{}) uint8 {
+    if value == nil {
+        return unsetValue
+    }
+    if id, ok := d.values[fact][value]; ok {
+        return id
+    }
+    return otherValue
+}
+
+// packStart converts the start state to its packed form.
+// Facts that no action refers to never change during search and are ignored.
+func (d *goapDomain) packStart(start GOAPState) packedState {
+    var packed packedState
+    for fact, name := range d.factNames {
+        packed[fact] = d.lookupValue(uint8(fact), start[name])
+    }
+    return packed
+}
+
+// packGoal converts the goal to a list of conditions. It reports false when the
+// goal requires a value no action can produce and the start state does not have.
+func (d *goapDomain) packGoal(start, goal GOAPState) ([]factCond, bool) {
+    conds := make([]factCond, 0, len(goal))
+    for name, value := range goal {
+        fact, known := d.facts[name]
+        if !known {
+            if start[name] != value {
+                return nil, false
+            }
+            continue
         }
+        id := d.lookupValue(fact, value)
+        if id == otherValue && start[name] != value {
+            return nil, false
+        }
+        conds = append(conds, factCond{fact: fact, value: id})
     }
-    return list
+    return conds, true
 }
 
-// canExecuteAction checks if an action's preconditions are met in the given state
-func (p *GOAPPlanner) canExecuteAction(state GOAPState, action GOAPAction) bool {
-    for k, v := range action.Preconditions {
-        if state[k] != v {
-            return false
+// unpack converts a packed state back to a GOAPState for cost functions
+func (d *goapDomain) unpack(state packedState, start GOAPState) GOAPState {
+    unpacked := make(GOAPState, len(start))
+    for k, v := range start {
+        unpacked[k] = v
+    }
+    for fact, name := range d.factNames {
+        switch id := state[fact]; id {
+        case unsetValue:
+            delete(unpacked, name)
+        case otherValue:
+            // Value carried over unchanged from the start state
+        default:
+            unpacked[name] = d.valueList[fact][id-1]
         }
     }
-    return true
+    return unpacked
 }
 
-// applyEffects applies an action's effects to a state
-func (p *GOAPPlanner) applyEffects(state GOAPState, effects GOAPState) GOAPState {
-    newState := make(GOAPState)
-    for k, v := range state {
-        newState[k] = v
+// planNode is a search node of the A* open list
+type planNode struct {
+    state  packedState
+    g, f   float64
+    parent *planNode
+    action *GOAPAction
+    seq    int
+    index  int
+}
+
+// openList is a binary heap of plan nodes ordered by fScore, ties broken by insertion order
+type openList []*planNode
+
+func (o openList) Len() int { return len(o) }
+
+func (o openList) Less(i, j int) bool {
+    if o[i].f != o[j].f {
+        return o[i].f < o[j].f
     }
-    for k, v := range effects {
-        newState[k] = v
+    return o[i].seq < o[j].seq
+}
+
+func (o openList) Swap(i, j int) {
+    o[i], o[j] = o[j], o[i]
+    o[i].index = i
+    o[j].index = j
+}
+
+func (o *openList) Push(x any) {
+    n := x.(*planNode)
+    n.index = len(*o)
+    *o = append(*o, n)
+}
+
+func (o *openList) Pop() any {
+    old := *o
+    n := old[len(old)-1]
+    old[len(old)-1] = nil
+    n.index = -1
+    *o = old[:len(old)-1]
+    return n
+}
+
+// search runs A* over packed states using the given subset of actions
+func (d *goapDomain) search(actions []compiledAction, start, goal GOAPState) []GOAPAction {
+    goalConds, ok := d.packGoal(start, goal)
+    if !ok {
+        return nil // No plan found
     }
-    return newState
+
+    startNode := &planNode{state: d.packStart(start), index: -1}
+    startNode.f = heuristic(startNode.state, goalConds)
+    nodes := map[packedState]*planNode{startNode.state: startNode}
+    open := &openList{}
+    heap.Push(open, startNode)
+    seq := 1
+
+    for open.Len() > 0 {
+        current := heap.Pop(open).(*planNode)
+        if satisfies(current.state, goalConds) {
+            return reconstructPath(current)
+        }
+
+        var currentState GOAPState
+        for i := range actions {
+            action := &actions[i]
+            if !satisfies(current.state, action.preconditions) {
+                continue
+            }
+
+            neighbor := current.state
+            for _, effect := range action.effects {
+                neighbor[effect.fact] = effect.value
+            }
+
+            cost := action.action.Cost
+            if action.action.CostFunc != nil {
+                if currentState == nil {
+                    currentState = d.unpack(current.state, start)
+                }
+                cost = action.action.CostFunc(currentState)
+            }
+            tentativeGScore := current.g + cost
+
+            node, exists := nodes[neighbor]
+            if exists && tentativeGScore >= node.g {
+                continue
+            }
+            if !exists {
+                node = &planNode{state: neighbor, index: -1}
+                nodes[neighbor] = node
+            }
+            node.parent = current
+            node.action = action.action
+            node.g = tentativeGScore
+            node.f = tentativeGScore + heuristic(neighbor, goalConds)
+            if node.index >= 0 {
+                heap.Fix(open, node.index)
+            } else {
+                // New node, or a closed node reopened because a cheaper path was found
+                node.seq = seq
+                seq++
+                heap.Push(open, node)
+            }
+        }
+    }
+
+    return nil // No plan found
 }
 
-// containsState checks if a state is in the list of states
-func (p *GOAPPlanner) containsState(list []GOAPState, state GOAPState) bool {
-    for _, s := range list {
-        if p.stateEquals(s, state) {
-            return true
+// satisfies checks that every condition holds in the state
+func satisfies(state packedState, conds []factCond) bool {
+    for _, c := range conds {
+        if state[c.fact] != c.value {
+            return false
         }
     }
-    return false
+    return true
 }
 
-// stateToString converts a GOAPState to a string representation
-func stateToString(state GOAPState) string {
-    var pairs []string
-    for k, v := range state {
-        pairs = append(pairs, fmt.Sprintf("%s:%v", k, v))
+// heuristic estimates the cost from a state to the goal state
+func heuristic(state packedState, goal []factCond) float64 {
+    // Simple heuristic: count the number of mismatched goals
+    count := 0
+    for _, c := range goal {
+        if state[c.fact] != c.value {
+            count++
+        }
+    }
+    return float64(count)
+}
+
+// reconstructPath builds the sequence of actions from start to goal
+func reconstructPath(current *planNode) []GOAPAction {
+    var path []GOAPAction
+    for n := current; n.action != nil; n = n.parent {
+        path = append(path, *n.action)
+    }
+    for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+        path[i], path[j] = path[j], path[i]
     }
-    sort.Strings(pairs) // Sort to ensure consistent string representation
-    return strings.Join(pairs, "|")
+    return path
 }
@@ -0,0 +1,160 @@
+package ai
+
+import (
+    "fmt"
+    "math"
+    "sort"
+    "strings"
+)
+
+// legacyPlanner is the original slice-based planner, kept to compare results and
+// benchmark against the heap-based implementation.
+type legacyPlanner struct {
+    Actions []GOAPAction
+}
+
+// Plan finds the optimal sequence of actions to reach the goal state from the start state
+func (p *legacyPlanner) Plan(start, goal GOAPState) []GOAPAction {
+    openList := []GOAPState{start}
+    cameFrom := make(map[string]GOAPState)
+    gScore := make(map[string]float64)
+    fScore := make(map[string]float64)
+    actionTaken := make(map[string]GOAPAction)
+
+    startKey := legacyStateToString(start)
+    gScore[startKey] = 0
+    fScore[startKey] = p.heuristic(start, goal)
+
+    for len(openList) > 0 {
+        current := p.lowestFScore(openList, fScore)
+        currentKey := legacyStateToString(current)
+        if p.stateEquals(current, goal) {
+            return p.reconstructPath(cameFrom, actionTaken, current)
+        }
+
+        openList = p.removeState(openList, current)
+
+        for _, action := range p.Actions {
+            if p.canExecuteAction(current, action) {
+                neighbor := p.applyEffects(current, action.Effects)
+                neighborKey := legacyStateToString(neighbor)
+                tentativeGScore := gScore[currentKey] + action.CostFunc(current)
+
+                if _, exists := gScore[neighborKey]; !exists || tentativeGScore < gScore[neighborKey] {
+                    cameFrom[neighborKey] = current
+                    actionTaken[neighborKey] = action
+                    gScore[neighborKey] = tentativeGScore
+                    fScore[neighborKey] = gScore[neighborKey] + p.heuristic(neighbor, goal)
+                    if !p.containsState(openList, neighbor) {
+                        openList = append(openList, neighbor)
+                    }
+                }
+            }
+        }
+    }
+
+    return nil // No plan found
+}
+
+// heuristic estimates the cost from a state to the goal state
+func (p *legacyPlanner) heuristic(state, goal GOAPState) float64 {
+    // Simple heuristic: count the number of mismatched goals
+    count := 0
+    for k, v := range goal {
+        if state[k] != v {
+            count++
+        }
+    }
+    return float64(count)
+}
+
+// lowestFScore finds the state with the lowest fScore in the open list
+func (p *legacyPlanner) lowestFScore(list []GOAPState, fScore map[string]float64) GOAPState {
+    lowestF := math.Inf(1)
+    var lowestState GOAPState
+    for _, state := range list {
+        key := legacyStateToString(state)
+        if f, exists := fScore[key]; exists && f < lowestF {
+            lowestF = f
+            lowestState = state
+        }
+    }
+    return lowestState
+}
+
+// stateEquals checks if two states are equal
+func (p *legacyPlanner) stateEquals(current, goal GOAPState) bool {
+    for k, v := range goal {
+        if bv := current[k]; bv != v {
+            return false
+        }
+    }
+    return true
+}
+
+// reconstructPath builds the sequence of actions from start to goal
+func (p *legacyPlanner) reconstructPath(cameFrom map[string]GOAPState, actionTaken map[string]GOAPAction, current GOAPState) []GOAPAction {
+    var path []GOAPAction
+    for {
+        currentKey := legacyStateToString(current)
+        action, exists := actionTaken[currentKey]
+        if !exists {
+            break
+        }
+        path = append([]GOAPAction{action}, path...)
+        current = cameFrom[currentKey]
+    }
+    return path
+}
+
+// removeState removes a state from the list of states
+func (p *legacyPlanner) removeState(list []GOAPState, state GOAPState) []GOAPState {
+    for i, s := range list {
+        if p.stateEquals(s, state) {
+            return append(list[:i], list[i+1:]...)
+        }
+    }
+    return list
+}
+
+// canExecuteAction checks if an action's preconditions are met in the given state
+func (p *legacyPlanner) canExecuteAction(state GOAPState, action GOAPAction) bool {
+    for k, v := range action.Preconditions {
+        if state[k] != v {
+            return false
+        }
+    }
+    return true
+}
+
+// applyEffects applies an action's effects to a state
+func (p *legacyPlanner) applyEffects(state GOAPState, effects GOAPState) GOAPState {
+    newState := make(GOAPState)
+    for k, v := range state {
+        newState[k] = v
+    }
+    for k, v := range effects {
+        newState[k] = v
+    }
+    return newState
+}
+
+// containsState checks if a state is in the list of states
+func (p *legacyPlanner) containsState(list []GOAPState, state GOAPState) bool {
+    for _, s := range list {
+        if p.stateEquals(s, state) {
+            return true
+        }
+    }
+    return false
+}
+
+// legacyStateToString converts a GOAPState to a string representation
+func legacyStateToString(state GOAPState) string {
+    var pairs []string
+    for k, v := range state {
+        pairs = append(pairs, fmt.Sprintf("%s:%v", k, v))
+    }
+    sort.Strings(pairs) // Sort to ensure consistent string representation
+    return strings.Join(pairs, "|")
+}
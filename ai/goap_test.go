@@ -0,0 +1,162 @@
+package ai
+
+import (
+    "fmt"
+    "github.com/stretchr/testify/assert"
+    "testing"
+)
+
+// npcActions mirrors the NPC action set from the units package.
+// With dynamicCost the costs go through CostFunc, which the legacy planner requires.
+func npcActions(dynamicCost bool) []GOAPAction {
+    action := func(name string, cost float64, pre, eff GOAPState) GOAPAction {
+        a := GOAPAction{Name: name, Cost: cost, Preconditions: pre, Effects: eff}
+        if dynamicCost {
+            a.CostFunc = func(state GOAPState) float64 { return cost }
+        }
+        return a
+    }
+    return []GOAPAction{
+        action("RunToSafety", 1, GOAPState{"lowHealth": true, "monstersArround": true}, GOAPState{"inDanger": false}),
+        action("LookForMushroom", 2, GOAPState{"seeMushroom": true}, GOAPState{"mushroomNear": true}),
+        action("TakeMushroom", 2, GOAPState{"mushroomNear": true}, GOAPState{"hasFullHealth": true}),
+        action("FindMonster", 3, GOAPState{"hasTarget": false, "monstersArround": true}, GOAPState{"hasTarget": true}),
+        action("MoveToTarget", 3, GOAPState{"hasTarget": true, "inAttackRange": false}, GOAPState{"inAttackRange": true}),
+        action("AttackMonster", 4, GOAPState{"hasTarget": true, "inAttackRange": true}, GOAPState{"hasDefeatedMonster": true}),
+        action("MoveToDen", 4, GOAPState{"seeGoblinDen": true}, GOAPState{"denInAttackRange": true}),
+        action("AttackDen", 4, GOAPState{"denInAttackRange": true}, GOAPState{"hasDefeatedDen": true}),
+        action("Wander", 6, GOAPState{}, GOAPState{"monstersArround": true, "seeMushroom": true}),
+    }
+}
+
+var npcFacts = []string{
+    "lowHealth", "hasFullHealth", "inDanger", "hasTarget", "inAttackRange", "monstersArround",
+    "mushroomNear", "seeMushroom", "denInAttackRange", "seeGoblinDen",
+}
+
+// npcStates enumerates a spread of start states by toggling facts from a bit pattern
+func npcStates() []GOAPState {
+    states := make([]GOAPState, 0)
+    for bits := 0; bits < 1<<len(npcFacts); bits += 7 {
+        state := GOAPState{}
+        for i, fact := range npcFacts {
+            state[fact] = bits&(1<<i) != 0
+        }
+        states = append(states, state)
+    }
+    return states
+}
+
+var npcGoals = []GOAPState{
+    {"inDanger": false},
+    {"hasDefeatedMonster": true},
+    {"hasFullHealth": true},
+    {"inAttackRange": true},
+    {"hasTarget": true},
+    {"hasDefeatedDen": true},
+    {"denInAttackRange": true},
+    {"monstersArround": true},
+}
+
+func planCost(plan []GOAPAction) float64 {
+    cost := 0.0
+    for _, a := range plan {
+        cost += a.Cost
+    }
+    return cost
+}
+
+func newPlanner(actions []GOAPAction) *GOAPPlanner {
+    p := NewGOAPPlanner()
+    for _, a := range actions {
+        p.AddAction(a)
+    }
+    return p
+}
+
+func TestPlanMatchesLegacy(t *testing.T) {
+    planner := newPlanner(npcActions(false))
+    legacy := &legacyPlanner{Actions: npcActions(true)}
+
+    for _, start := range npcStates() {
+        for _, goal := range npcGoals {
+            plan := planner.Plan(start, goal)
+            legacyPlan := legacy.Plan(start, goal)
+            assert.Equal(t, legacyPlan == nil, plan == nil, "start %v goal %v", start, goal)
+            assert.Equal(t, planCost(legacyPlan), planCost(plan), "start %v goal %v", start, goal)
+        }
+    }
+}
+
+func TestPlan(t *testing.T) {
+    planner := newPlanner(npcActions(false))
+
+    t.Run("Chain of actions", func(t *testing.T) {
+        start := GOAPState{"hasTarget": false, "monstersArround": true, "inAttackRange": false}
+        plan := planner.Plan(start, GOAPState{"hasDefeatedMonster": true})
+        names := make([]string, len(plan))
+        for i, a := range plan {
+            names[i] = a.Name
+        }
+        assert.Equal(t, []string{"FindMonster", "MoveToTarget", "AttackMonster"}, names)
+    })
+
+    t.Run("Goal already satisfied", func(t *testing.T) {
+        assert.Nil(t, planner.Plan(GOAPState{"hasTarget": true}, GOAPState{"hasTarget": true}))
+    })
+
+    t.Run("Goal fact unknown to actions", func(t *testing.T) {
+        assert.Nil(t, planner.Plan(GOAPState{}, GOAPState{"isRich": true}))
+    })
+
+    t.Run("Cost function sees the planned state", func(t *testing.T) {
+        p := NewGOAPPlanner()
+        p.AddAction(GOAPAction{Name: "A", Cost: 1, Effects: GOAPState{"a": true}})
+        p.AddAction(GOAPAction{
+            Name: "B",
+            CostFunc: func(state GOAPState) float64 {
+                assert.Equal(t, "kept", state["untouched"])
+                if state["a"] == true {
+                    return 1
+                }
+                return 10
+            },
+            Effects: GOAPState{"b": true},
+        })
+        plan := p.Plan(GOAPState{"untouched": "kept"}, GOAPState{"a": true, "b": true})
+        assert.Len(t, plan, 2)
+        assert.Equal(t, "A", plan[0].Name)
+    })
+
+    t.Run("Recompiles after AddAction", func(t *testing.T) {
+        p := NewGOAPPlanner()
+        p.AddAction(GOAPAction{Name: "A", Cost: 1, Effects: GOAPState{"a": true}})
+        assert.Nil(t, p.Plan(GOAPState{}, GOAPState{"b": true}))
+        p.AddAction(GOAPAction{Name: "B", Cost: 1, Effects: GOAPState{"b": true}})
+        assert.Len(t, p.Plan(GOAPState{}, GOAPState{"b": true}), 1)
+    })
+}
+
+func benchmarkPlan(b *testing.B, plan func(start, goal GOAPState) []GOAPAction) {
+    states := npcStates()
+    b.ResetTimer()
+    for i := 0; i < b.N; i++ {
+        start := states[i%len(states)]
+        goal := npcGoals[i%len(npcGoals)]
+        plan(start, goal)
+    }
+}
+
+func BenchmarkPlan(b *testing.B) {
+    for _, dynamicCost := range []bool{false, true} {
+        b.Run(fmt.Sprintf("dynamicCost=%v", dynamicCost), func(b *testing.B) {
+            planner := newPlanner(npcActions(dynamicCost))
+            benchmarkPlan(b, planner.Plan)
+        })
+    }
+}
+
+func BenchmarkLegacyPlan(b *testing.B) {
+    legacy := &legacyPlanner{Actions: npcActions(true)}
+    benchmarkPlan(b, legacy.Plan)
+}
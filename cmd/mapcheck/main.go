@@ -0,0 +1,105 @@
+// Command mapcheck analyses map files and reports their connected regions, unreachable
+// tiles, chokepoints and border gaps. It exits with status 1 when a map has problems.
+package main
+
+import (
+    gamemap "example.com/maj/map"
+    "example.com/maj/map/analysis"
+    "flag"
+    "fmt"
+    "image"
+    "os"
+    "strings"
+)
+
+func main() {
+    draw := flag.Bool("draw", false, "draw each map with its regions, chokepoints and border gaps")
+    flag.Usage = func() {
+        fmt.Fprintln(flag.CommandLine.Output(), "usage: mapcheck [-draw] map...")
+        flag.PrintDefaults()
+    }
+    flag.Parse()
+    if flag.NArg() == 0 {
+        flag.Usage()
+        os.Exit(2)
+    }
+
+    failed := false
+    for _, file := range flag.Args() {
+        gameMap, err := gamemap.LoadGameMapFile(file)
+        if err != nil {
+            fmt.Fprintln(os.Stderr, err)
+            failed = true
+            continue
+        }
+        report := analysis.Analyze(gameMap)
+        printReport(file, gameMap, report)
+        if *draw {
+            drawReport(gameMap, report)
+        }
+        failed = failed || len(report.Problems()) > 0
+    }
+    if failed {
+        os.Exit(1)
+    }
+}
+
+func printReport(file string, gameMap *gamemap.GameMap, report *analysis.Report) {
+    fmt.Printf("%s: %s, %dx%d tiles\n", file, gameMap.Name, gameMap.Width, gameMap.Height)
+    sizes := make([]string, len(report.Components))
+    for i, component := range report.Components {
+        sizes[i] = fmt.Sprint(len(component))
+    }
+    fmt.Printf("  regions:     %d (%s tiles)\n", len(report.Components), strings.Join(sizes, ", "))
+    fmt.Printf("  unreachable: %d tiles\n", len(report.Unreachable))
+    fmt.Printf("  chokepoints: %d%s\n", len(report.Chokepoints), points(report.Chokepoints))
+    fmt.Printf("  border gaps: %d%s\n", len(report.BorderGaps), points(report.BorderGaps))
+    for _, problem := range report.Problems() {
+        fmt.Printf("  problem: %s\n", problem)
+    }
+}
+
+// points lists the first tiles of a list
+func points(tiles []image.Point) string {
+    const shown = 10
+    if len(tiles) == 0 {
+        return ""
+    }
+    var list []string
+    for _, p := range tiles[:min(len(tiles), shown)] {
+        list = append(list, fmt.Sprintf("%d,%d", p.X, p.Y))
+    }
+    if len(tiles) > shown {
+        list = append(list, "...")
+    }
+    return " at " + strings.Join(list, " ")
+}
+
+// drawReport prints the map with # for blocked tiles, . for the main region, digits for
+// the other regions, ! for chokepoints and X for border gaps
+func drawReport(gameMap *gamemap.GameMap, report *analysis.Report) {
+    marks := make(map[image.Point]byte)
+    for _, p := range report.Chokepoints {
+        marks[p] = '!'
+    }
+    for _, p := range report.BorderGaps {
+        marks[p] = 'X'
+    }
+    for y := 0; y < gameMap.Height; y++ {
+        row := make([]byte, gameMap.Width)
+        for x := range row {
+            region := report.Regions[y][x]
+            switch mark, ok := marks[image.Pt(x, y)]; {
+            case ok:
+                row[x] = mark
+            case region < 0:
+                row[x] = '#'
+            case region == 0:
+                row[x] = '.'
+            default:
+                row[x] = byte('0' + region%10)
+            }
+        }
+        fmt.Printf("  %s\n", row)
+    }
+}
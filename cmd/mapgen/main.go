@@ -0,0 +1,63 @@
+// Command mapgen generates a map from a seed and writes it in the CSV text format the game
+// loads, or in the JSON format to keep the spawn points it places.
+package main
+
+import (
+    "errors"
+    gamemap "example.com/maj/map"
+    "example.com/maj/map/mapgen"
+    "flag"
+    "fmt"
+    "log"
+    "os"
+    "time"
+)
+
+func main() {
+    layout := flag.String("layout", string(mapgen.Caves), fmt.Sprintf("layout of the map, one of %v", mapgen.Layouts))
+    seed := flag.Int64("seed", time.Now().UnixNano(), "random seed, the same seed and settings generate the same map")
+    defaults := mapgen.DefaultConfig(mapgen.Caves, 0)
+    width := flag.Int("width", defaults.Width, "map width in tiles")
+    height := flag.Int("height", defaults.Height, "map height in tiles")
+    dens := flag.Float64("dens", defaults.DenDensity, "goblin dens per 1000 spawnable tiles")
+    mushrooms := flag.Float64("mushrooms", defaults.MushroomDensity, "mushroom fields per 1000 spawnable tiles")
+    npcs := flag.Int("npcs", defaults.NPCs, "number of NPCs starting next to the player")
+    format := flag.String("format", "txt", "output format, txt for the CSV grid or json to keep the spawn points")
+    output := flag.String("o", "", "file to write the map to, standard output when empty")
+    flag.Parse()
+
+    config := mapgen.DefaultConfig(mapgen.Layout(*layout), *seed)
+    config.Width = *width
+    config.Height = *height
+    config.DenDensity = *dens
+    config.MushroomDensity = *mushrooms
+    config.NPCs = *npcs
+    gameMap, err := mapgen.Generate(config)
+    if err != nil {
+        log.Fatal(err)
+    }
+
+    write := gamemap.WriteCSV
+    switch *format {
+    case "txt":
+    case "json":
+        write = gamemap.WriteJSON
+    default:
+        log.Fatalf("unknown format %q", *format)
+    }
+
+    if *output == "" {
+        if err := write(os.Stdout, gameMap); err != nil {
+            log.Fatal(err)
+        }
+        return
+    }
+    f, err := os.Create(*output)
+    if err != nil {
+        log.Fatal(err)
+    }
+    // A failed close can leave the map truncated
+    if err := errors.Join(write(f, gameMap), f.Close()); err != nil {
+        log.Fatal(err)
+    }
+}
@@ -0,0 +1,95 @@
+// Command simulate runs the game world without a window, renderer or input
+// and prints a summary of what happened.
+package main
+
+import (
+    "example.com/maj/game"
+    "example.com/maj/logging"
+    gamemap "example.com/maj/map"
+    "example.com/maj/sim"
+    "example.com/maj/units"
+    "flag"
+    "fmt"
+    "log"
+    "log/slog"
+    "runtime"
+    "time"
+)
+
+func main() {
+    mapFile := flag.String("map", "", "map file to load, defaults to the built-in map")
+    ticks := flag.Int("ticks", 60*sim.TicksPerSecond, "number of simulation ticks to run")
+    npcs := flag.Int("npcs", 5, "number of NPCs to spawn on maps without characters")
+    seed := flag.Int64("seed", time.Now().UnixNano(), "random seed, the same seed and map reproduce a run")
+    load := flag.String("load", "", "start from a saved world snapshot instead of -map, -seed and -npcs")
+    save := flag.String("save", "", "write a world snapshot to this file after the run")
+    workers := flag.Int("workers", runtime.GOMAXPROCS(0), "number of goroutines NPCs think on, the result does not depend on it")
+    behaviorFile := flag.String("behavior", "", "JSON file with NPC actions and goals, defaults to the built-in behavior")
+    var level slog.Level
+    flag.TextVar(&level, "log-level", slog.LevelWarn, "lowest level of the logs written to stderr")
+    trace := flag.String("trace", "", "comma separated names of the entities to log")
+    decisions := flag.String("decisions", "", "write the NPC decisions to this JSON-lines file")
+    flag.Parse()
+
+    logger, err := logging.Open(level, *decisions)
+    if err != nil {
+        log.Fatal(err)
+    }
+    defer logger.Close()
+    logger.Filter.Trace(logging.SplitNames(*trace)...)
+    slog.SetDefault(logger.Logger)
+
+    if *behaviorFile != "" {
+        behavior, err := units.LoadNPCBehaviorFile(*behaviorFile)
+        if err != nil {
+            log.Fatal(err)
+        }
+        units.NPCBehavior = behavior
+    }
+
+    var world *game.World
+    if *load != "" {
+        world, err = game.LoadWorldFile(*load)
+        if err != nil {
+            log.Fatal(err)
+        }
+    } else {
+        gameMap, err := gamemap.LoadGameMapFileOrDefault(*mapFile)
+        if err != nil {
+            log.Fatal(err)
+        }
+        world = game.NewWorldFromMap(gameMap, *seed)
+        if len(world.Characters()) == 0 {
+            for i := 1; i <= *npcs; i++ {
+                world.AddCharacter(units.NewCharacter(float64(4*gamemap.TileSize), float64(4*gamemap.TileSize), fmt.Sprintf("NPC%d", i)))
+            }
+        }
+    }
+
+    world.Workers = *workers
+
+    start := time.Now()
+    for i := 0; i < *ticks; i++ {
+        world.Update()
+    }
+    elapsed := time.Since(start)
+
+    if *save != "" {
+        if err := world.SaveFile(*save); err != nil {
+            log.Fatal(err)
+        }
+    }
+
+    stats := world.Stats()
+    if *load != "" {
+        fmt.Printf("snapshot:        %s\n", *load)
+    } else {
+        fmt.Printf("map:             %s\n", world.GameMap.Name)
+    }
+    fmt.Printf("seed:            %d\n", world.Seed)
+    fmt.Printf("ticks:           %d (%v simulated, %v wall clock)\n", stats.Ticks, world.Clock.Now(), elapsed.Round(time.Millisecond))
+    fmt.Printf("NPC deaths:      %d\n", stats.NPCDeaths)
+    fmt.Printf("dens destroyed:  %d\n", stats.DensDestroyed)
+    fmt.Printf("mushrooms eaten: %d\n", stats.MushroomsEaten)
+    fmt.Printf("monsters killed: %d\n", stats.MonstersKilled)
+}
@@ -26,8 +26,54 @@ type TileType int
 const (
     TileGrass TileType = iota
     TileMountain
+    TileWater
+    TileSwamp
+    TileRoad
+    TileForest
 )
 
+// tileKeys maps the number keys to the tile they select
+var tileKeys = []struct {
+	key  ebiten.Key
+	tile TileType
+}{
+	{ebiten.Key1, TileGrass},
+	{ebiten.Key2, TileMountain},
+	{ebiten.Key3, TileWater},
+	{ebiten.Key4, TileSwamp},
+	{ebiten.Key5, TileRoad},
+	{ebiten.Key6, TileForest},
+}
+
+// tileNames are shown in the tile help line, in the same order as the constants
+var tileNames = []string{"Grass", "Mountain", "Water", "Swamp", "Road", "Forest"}
+
+// tileColors are the colors tiles are drawn with
+var tileColors = map[TileType]color.RGBA{
+	TileGrass:    {34, 139, 34, 255},
+	TileMountain: {139, 69, 19, 255},
+	TileWater:    {30, 144, 255, 255},
+	TileSwamp:    {85, 107, 47, 255},
+	TileRoad:     {210, 180, 140, 255},
+	TileForest:   {0, 100, 0, 255},
+}
+
+func (t TileType) String() string {
+	if int(t) >= 0 && int(t) < len(tileNames) {
+		return tileNames[t]
+	}
+	return strconv.Itoa(int(t))
+}
+
+// tileHelp lists the key of every tile, e.g. "1:Grass, 2:Mountain"
+func tileHelp() string {
+	help := make([]string, len(tileKeys))
+	for i, k := range tileKeys {
+		help[i] = fmt.Sprintf("%d:%v", i+1, k.tile)
+	}
+	return strings.Join(help, ", ")
+}
+
 type Editor struct {
 	tiles         [][]TileType
 	currentTile   TileType
@@ -56,10 +102,10 @@ func (e *Editor) Update() error {
 		}
 	}
 
-	if inpututil.IsKeyJustPressed(ebiten.Key1) {
-		e.currentTile = TileGrass
-	} else if inpututil.IsKeyJustPressed(ebiten.Key2) {
-		e.currentTile = TileMountain
+	for _, k := range tileKeys {
+		if inpututil.IsKeyJustPressed(k.key) {
+			e.currentTile = k.tile
+		}
 	}
 
 	if inpututil.IsKeyJustPressed(ebiten.KeyS) {
@@ -82,16 +128,11 @@ func (e *Editor) Update() error {
 func (e *Editor) Draw(screen *ebiten.Image) {
 	for y := 0; y < MapHeight; y++ {
 		for x := 0; x < MapWidth; x++ {
-			switch e.tiles[y][x] {
-			case TileGrass:
-				ebitenutil.DrawRect(screen, float64(x*TileSize), float64(y*TileSize), TileSize, TileSize, color.RGBA{34, 139, 34, 255})
-			case TileMountain:
-				ebitenutil.DrawRect(screen, float64(x*TileSize), float64(y*TileSize), TileSize, TileSize, color.RGBA{139, 69, 19, 255})
-			}
+			ebitenutil.DrawRect(screen, float64(x*TileSize), float64(y*TileSize), TileSize, TileSize, tileColors[e.tiles[y][x]])
 		}
 	}
 
-	ebitenutil.DebugPrint(screen, fmt.Sprintf("Current Tile: %v (1:Grass, 2:Mountain) | Press 'S' to save", e.currentTile))
+	ebitenutil.DebugPrint(screen, fmt.Sprintf("Current Tile: %v (%s) | Press 'S' to save", e.currentTile, tileHelp()))
 
 	if e.messageTimer > 0 {
 		ebitenutil.DebugPrintAt(screen, e.saveMessage, 10, ScreenHeight-20)
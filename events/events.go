@@ -0,0 +1,74 @@
+// Package events is a typed in-process event bus. Publishers and subscribers only share the
+// event types, so systems such as stats, logging or rendering can react to what happens in
+// the simulation without the units knowing about them.
+package events
+
+import "reflect"
+
+// Bus delivers published events to the subscribers of their type.
+// It is not safe for concurrent use: publish and subscribe from the simulation goroutine.
+type Bus struct {
+    handlers map[reflect.Type][]subscription
+    all      []subscription
+    nextID   int
+}
+
+type subscription struct {
+    id int
+    fn func(any)
+}
+
+// NewBus creates a bus without subscribers
+func NewBus() *Bus {
+    return &Bus{handlers: make(map[reflect.Type][]subscription)}
+}
+
+// Subscribe calls fn for every event of the concrete type E published on the bus.
+// It returns a function that ends the subscription.
+func Subscribe[E any](b *Bus, fn func(E)) (unsubscribe func()) {
+    t := reflect.TypeFor[E]()
+    id := b.newID()
+    b.handlers[t] = append(b.handlers[t], subscription{id: id, fn: func(event any) { fn(event.(E)) }})
+    return func() { b.handlers[t] = without(b.handlers[t], id) }
+}
+
+// SubscribeAll calls fn for every event published on the bus, after the subscribers of its type.
+// It returns a function that ends the subscription.
+func (b *Bus) SubscribeAll(fn func(event any)) (unsubscribe func()) {
+    id := b.newID()
+    b.all = append(b.all, subscription{id: id, fn: fn})
+    return func() { b.all = without(b.all, id) }
+}
+
+// newID hands out the id of a new subscription
+func (b *Bus) newID() int {
+    b.nextID++
+    return b.nextID
+}
+
+// Publish delivers an event to the subscribers of its type in the order they subscribed,
+// then to the subscribers of all events. Handlers run before Publish returns and may
+// publish further events. Publishing on a nil bus does nothing.
+func (b *Bus) Publish(event any) {
+    if b == nil {
+        return
+    }
+    for _, s := range b.handlers[reflect.TypeOf(event)] {
+        s.fn(event)
+    }
+    for _, s := range b.all {
+        s.fn(event)
+    }
+}
+
+// without returns the subscriptions except the one with the given id. The slice is copied,
+// so an unsubscribe from within a handler does not disturb a delivery in progress.
+func without(subs []subscription, id int) []subscription {
+    kept := make([]subscription, 0, len(subs))
+    for _, s := range subs {
+        if s.id != id {
+            kept = append(kept, s)
+        }
+    }
+    return kept
+}
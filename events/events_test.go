@@ -0,0 +1,55 @@
+package events
+
+import (
+    "github.com/stretchr/testify/assert"
+    "testing"
+)
+
+type hit struct{ amount int }
+
+type heal struct{ amount int }
+
+func TestBus(t *testing.T) {
+    t.Run("Events reach the subscribers of their type in order", func(t *testing.T) {
+        bus := NewBus()
+        var got []string
+        Subscribe(bus, func(e hit) { got = append(got, "first hit") })
+        Subscribe(bus, func(e hit) { got = append(got, "second hit") })
+        Subscribe(bus, func(e heal) { got = append(got, "heal") })
+        bus.SubscribeAll(func(e any) { got = append(got, "any") })
+
+        bus.Publish(hit{amount: 3})
+        assert.Equal(t, []string{"first hit", "second hit", "any"}, got)
+    })
+
+    t.Run("Unsubscribed handlers are no longer called", func(t *testing.T) {
+        bus := NewBus()
+        total := 0
+        unsubscribe := Subscribe(bus, func(e hit) { total += e.amount })
+        bus.Publish(hit{amount: 2})
+        unsubscribe()
+        bus.Publish(hit{amount: 5})
+        assert.Equal(t, 2, total)
+    })
+
+    t.Run("Handlers may unsubscribe and publish during a delivery", func(t *testing.T) {
+        bus := NewBus()
+        var got []any
+        var unsubscribe func()
+        unsubscribe = Subscribe(bus, func(e hit) {
+            unsubscribe()
+            bus.Publish(heal{amount: e.amount})
+        })
+        Subscribe(bus, func(e hit) { got = append(got, e) })
+        Subscribe(bus, func(e heal) { got = append(got, e) })
+
+        bus.Publish(hit{amount: 1})
+        bus.Publish(hit{amount: 2})
+        assert.Equal(t, []any{heal{amount: 1}, hit{amount: 1}, hit{amount: 2}}, got)
+    })
+
+    t.Run("Publishing on a nil bus does nothing", func(t *testing.T) {
+        var bus *Bus
+        assert.NotPanics(t, func() { bus.Publish(hit{}) })
+    })
+}
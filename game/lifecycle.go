@@ -0,0 +1,180 @@
+package game
+
+import (
+    "example.com/maj/events"
+    "example.com/maj/logging"
+    "example.com/maj/units"
+    "fmt"
+    "github.com/solarlune/resolv"
+    "log/slog"
+    "time"
+)
+
+// DefaultRespawnDelay is how long a dead character stays out of the world
+const DefaultRespawnDelay = 10 * time.Second
+
+// respawn is a dead character waiting to come back
+type respawn struct {
+    character *units.Character
+    at        time.Duration
+}
+
+// subscribe lets the world react to what units publish: monsters spawned by dens get the
+// world's pathfinding, picked mushrooms are announced as despawned and the stats count
+// deaths and pickups
+func (w *World) subscribe() {
+    events.Subscribe(w.Events, func(e units.MonsterSpawned) {
+        w.stats.MonstersSpawned++
+        e.Monster.Name = fmt.Sprintf("Goblin%d", w.stats.MonstersSpawned)
+        w.addMonster(e.Monster)
+    })
+    events.Subscribe(w.Events, func(e units.ItemPicked) {
+        w.stats.MushroomsEaten++
+        w.Events.Publish(units.EntityDespawned{Entity: e.Item})
+    })
+    events.Subscribe(w.Events, func(e units.DamageDealt) {
+        if e.Remaining == 0 {
+            w.killers[e.Target] = e.Source
+        }
+    })
+    events.Subscribe(w.Events, func(e units.EntityDied) {
+        switch unit := e.Entity.(type) {
+        case *units.Character:
+            if !unit.IsPlayer {
+                w.stats.NPCDeaths++
+            }
+        case *units.Monster:
+            w.stats.MonstersKilled++
+        case *units.GoblinDen:
+            w.stats.DensDestroyed++
+        }
+    })
+}
+
+// spawn adds an object to the space and announces the entity it belongs to
+func (w *World) spawn(obj *resolv.Object) {
+    if obj.Space == nil {
+        w.Space.Add(obj)
+    }
+    w.Changes.MarkObject(w.Space, obj)
+    w.Events.Publish(units.EntitySpawned{Entity: obj.Data})
+}
+
+// despawn removes an object from the space and announces it
+func (w *World) despawn(obj *resolv.Object) {
+    w.Space.Remove(obj)
+    w.Changes.MarkObject(w.Space, obj)
+    w.Events.Publish(units.EntityDespawned{Entity: obj.Data})
+}
+
+// reap handles the units and dens whose health dropped to zero: they die and leave the
+// world, dens lose the monsters they spawned, characters forget dead targets and dead
+// characters wait for their respawn. It goes through the objects of the tick, those added
+// while it ran are reaped on the next one.
+func (w *World) reap(objects []*resolv.Object) {
+    for _, obj := range objects {
+        if obj.Space == nil {
+            continue
+        }
+        switch unit := obj.Data.(type) {
+        case *units.Character:
+            if unit.Alive() {
+                continue
+            }
+            w.die(obj)
+            if w.RespawnDelay >= 0 {
+                w.respawns = append(w.respawns, respawn{character: unit, at: w.Clock.Now() + w.RespawnDelay})
+            }
+        case *units.Monster:
+            if unit.Health > 0 {
+                continue
+            }
+            w.die(obj)
+            if unit.Den != nil && unit.Den.CurrentMonsters > 0 {
+                unit.Den.CurrentMonsters--
+            }
+            w.forgetTarget(unit)
+        case *units.GoblinDen:
+            if unit.Health > 0 {
+                continue
+            }
+            w.die(obj)
+        }
+    }
+}
+
+// die announces the death of an entity and takes it out of the world
+func (w *World) die(obj *resolv.Object) {
+    killer := w.killers[obj.Data]
+    delete(w.killers, obj.Data)
+    args := []any{"kind", entityKind(obj)}
+    if name := entityName(killer); name != "" {
+        args = append(args, "killer", name)
+    }
+    w.log(slog.LevelInfo, entityName(obj.Data), "died", args...)
+    w.Events.Publish(units.EntityDied{Entity: obj.Data, Killer: killer})
+    w.despawn(obj)
+}
+
+// forgetTarget clears the target of every character going after a dead monster
+func (w *World) forgetTarget(monster *units.Monster) {
+    for _, c := range w.Characters() {
+        if c.TargetMonster == monster {
+            c.TargetMonster = nil
+        }
+    }
+}
+
+// respawnCharacters brings back the dead characters whose respawn time has come
+func (w *World) respawnCharacters() {
+    now := w.Clock.Now()
+    waiting := w.respawns[:0]
+    for _, r := range w.respawns {
+        if now < r.at {
+            waiting = append(waiting, r)
+            continue
+        }
+        r.character.Respawn()
+        w.log(slog.LevelInfo, r.character.Name, "respawned")
+        w.spawn(r.character.Object)
+    }
+    w.respawns = waiting
+}
+
+// Characters returns the characters in the world, including dead ones waiting to respawn
+func (w *World) Characters() []*units.Character {
+    var characters []*units.Character
+    for _, obj := range w.Space.Objects() {
+        if c, ok := obj.Data.(*units.Character); ok {
+            characters = append(characters, c)
+        }
+    }
+    for _, r := range w.respawns {
+        characters = append(characters, r.character)
+    }
+    return characters
+}
+
+// log writes a world record at the current tick about an entity, empty for none
+func (w *World) log(level slog.Level, entity, msg string, args ...any) {
+    logging.Log(w.Logger, level, w.Clock.Tick(), entity, logging.World, msg, args...)
+}
+
+// entityName returns the name of a character or monster, empty for other entities
+func entityName(entity any) string {
+    switch e := entity.(type) {
+    case *units.Character:
+        return e.Name
+    case *units.Monster:
+        return e.Name
+    }
+    return ""
+}
+
+// entityKind returns the first tag of an object, such as "monster"
+func entityKind(obj *resolv.Object) string {
+    if tags := obj.Tags(); len(tags) > 0 {
+        return tags[0]
+    }
+    return ""
+}
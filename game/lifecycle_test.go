@@ -0,0 +1,162 @@
+package game
+
+import (
+    "bytes"
+    gamemap "example.com/maj/map"
+    "example.com/maj/sim"
+    "example.com/maj/units"
+    "github.com/solarlune/resolv"
+    "github.com/stretchr/testify/assert"
+    "testing"
+    "time"
+)
+
+// lifecycleWorld creates a world without dens or mushrooms that records its events
+func lifecycleWorld() (*World, *[]any) {
+    w := newEmptyWorld(gamemap.MustLoadGameMapFile("../map/map1.txt"), sim.NewClock(), 1)
+    var published []any
+    w.Events.SubscribeAll(func(e any) { published = append(published, e) })
+    return w, &published
+}
+
+// lifecycleOf returns the types of the lifecycle events about an entity
+func lifecycleOf(published []any, entity any) []string {
+    var result []string
+    for _, e := range published {
+        switch e := e.(type) {
+        case units.EntitySpawned:
+            if e.Entity == entity {
+                result = append(result, "spawned")
+            }
+        case units.DamageDealt:
+            if e.Target == entity {
+                result = append(result, "damaged")
+            }
+        case units.EntityDied:
+            if e.Entity == entity {
+                result = append(result, "died")
+            }
+        case units.EntityDespawned:
+            if e.Entity == entity {
+                result = append(result, "despawned")
+            }
+        }
+    }
+    return result
+}
+
+func TestLifecycle(t *testing.T) {
+    t.Run("Killed monsters leave their den and their attackers", func(t *testing.T) {
+        w, events := lifecycleWorld()
+        player := units.NewCharacter(float64(30*gamemap.TileSize), float64(30*gamemap.TileSize), "Player")
+        npc := units.NewCharacter(float64(4*gamemap.TileSize), float64(4*gamemap.TileSize), "NPC1")
+        w.AddCharacter(player)
+        w.AddCharacter(npc)
+        den := units.NewGoblinDen(w.Space, w.Clock, w.Rand, float64(10*gamemap.TileSize), float64(4*gamemap.TileSize))
+        den.Events = w.Events
+        w.Update()
+        assert.Equal(t, 1, den.CurrentMonsters)
+
+        var monster *units.Monster
+        for _, obj := range w.Space.Objects() {
+            if m, ok := obj.Data.(*units.Monster); ok {
+                monster = m
+            }
+        }
+        assert.NotNil(t, monster)
+        assert.Equal(t, "Goblin1", monster.Name)
+        assert.Equal(t, []string{"spawned"}, lifecycleOf(*events, monster))
+
+        npc.TargetMonster = monster
+        monster.TakeDamage(npc, monster.Health)
+        w.Update()
+
+        assert.Equal(t, []string{"spawned", "damaged", "died", "despawned"}, lifecycleOf(*events, monster))
+        assert.Contains(t, *events, units.EntityDied{Entity: monster, Killer: npc})
+        assert.Nil(t, monster.Object.Space)
+        assert.Nil(t, npc.TargetMonster)
+        assert.Equal(t, 0, den.CurrentMonsters)
+        assert.Equal(t, 1, w.Stats().MonstersKilled)
+    })
+
+    t.Run("Dead characters stop acting and respawn at their spawn point", func(t *testing.T) {
+        w, events := lifecycleWorld()
+        w.RespawnDelay = time.Second
+        w.AddCharacter(units.NewCharacter(float64(30*gamemap.TileSize), float64(30*gamemap.TileSize), "Player"))
+        npc := units.NewCharacter(float64(4*gamemap.TileSize), float64(4*gamemap.TileSize), "NPC1")
+        w.AddCharacter(npc)
+        for i := 0; i < 30; i++ {
+            w.Update()
+        }
+
+        npc.TakeDamage(nil, npc.MaxHealth)
+        w.Update()
+        assert.Nil(t, npc.Object.Space)
+        assert.Equal(t, 1, w.Stats().NPCDeaths)
+        assert.Contains(t, w.Characters(), npc)
+
+        position := npc.Object.Position
+        npc.Update()
+        assert.Equal(t, position, npc.Object.Position)
+        assert.False(t, npc.Steer(resolv.NewVector(1, 0)))
+
+        respawnAt := w.Clock.Now() + w.RespawnDelay
+        for w.Clock.Now() < respawnAt {
+            w.Update()
+        }
+        assert.Equal(t, []string{"spawned", "damaged", "died", "despawned", "spawned"}, lifecycleOf(*events, npc))
+        assert.Equal(t, w.Space, npc.Object.Space)
+        assert.Equal(t, npc.MaxHealth, npc.Health)
+        assert.Equal(t, npc.SpawnPoint, npc.Object.Position)
+        assert.Empty(t, npc.Perception.Memory)
+    })
+
+    t.Run("Dead characters stay dead without a respawn delay", func(t *testing.T) {
+        w, _ := lifecycleWorld()
+        w.RespawnDelay = -1
+        npc := units.NewCharacter(float64(4*gamemap.TileSize), float64(4*gamemap.TileSize), "NPC1")
+        w.AddCharacter(npc)
+        npc.TakeDamage(nil, npc.MaxHealth)
+        for i := 0; i < 600; i++ {
+            w.Update()
+        }
+        assert.Nil(t, npc.Object.Space)
+        assert.Empty(t, w.Characters())
+    })
+
+    t.Run("Eaten mushrooms are despawned by their eater", func(t *testing.T) {
+        w, events := lifecycleWorld()
+        player := units.NewCharacter(float64(4*gamemap.TileSize), float64(4*gamemap.TileSize), "Player")
+        w.AddCharacter(player)
+        mushroom := units.NewMushroom(w.Space, float64(4*gamemap.TileSize), float64(4*gamemap.TileSize))
+        player.Take()
+
+        assert.ElementsMatch(t, []any{units.ItemPicked{By: player, Item: mushroom}, units.EntityDespawned{Entity: mushroom}}, (*events)[len(*events)-2:])
+        assert.Nil(t, mushroom.Object.Space)
+        assert.Equal(t, 1, w.Stats().MushroomsEaten)
+    })
+
+    t.Run("Characters waiting to respawn are saved", func(t *testing.T) {
+        w, _ := lifecycleWorld()
+        w.AddCharacter(units.NewCharacter(float64(30*gamemap.TileSize), float64(30*gamemap.TileSize), "Player"))
+        npc := units.NewCharacter(float64(4*gamemap.TileSize), float64(4*gamemap.TileSize), "NPC1")
+        w.AddCharacter(npc)
+        npc.TakeDamage(nil, npc.MaxHealth)
+        w.Update()
+
+        var buf bytes.Buffer
+        assert.NoError(t, w.Save(&buf))
+        loaded, err := LoadWorld(bytes.NewReader(buf.Bytes()))
+        assert.NoError(t, err)
+        assert.Equal(t, w.Snapshot(), loaded.Snapshot())
+        assert.Len(t, loaded.Characters(), 2)
+
+        for loaded.Clock.Now() <= DefaultRespawnDelay {
+            loaded.Update()
+        }
+        for _, c := range loaded.Characters() {
+            assert.True(t, c.Alive(), c.Name)
+            assert.Equal(t, loaded.Space, c.Object.Space, c.Name)
+        }
+    })
+}
@@ -0,0 +1,535 @@
+package game
+
+import (
+    "encoding/json"
+    "example.com/maj/ai"
+    gamemap "example.com/maj/map"
+    "example.com/maj/sim"
+    "example.com/maj/units"
+    "fmt"
+    "github.com/solarlune/resolv"
+    "io"
+    "os"
+    "slices"
+    "time"
+)
+
+// SnapshotVersion is the version of the save format written by World.Save
+const SnapshotVersion = 5
+
+// Snapshot is the serialized form of a World. It keeps the position in the random
+// sequence, so a loaded world continues the way the saved one does.
+// Entities refer to each other by their index in the snapshot slices, -1 meaning none.
+type Snapshot struct {
+    Version int    `json:"version"`
+    Seed    int64  `json:"seed"`
+    Tick    uint64 `json:"tick"`
+    // RandDraws is how many values the world's random source produced from Seed
+    RandDraws uint64      `json:"randDraws"`
+    Map       SnapshotMap `json:"map"`
+    Stats     Stats       `json:"stats"`
+    // RespawnDelay is how long dead characters wait before coming back
+    RespawnDelay time.Duration `json:"respawnDelay"`
+    // SpawnAnywhere lets entities spawn outside the main region of the map
+    SpawnAnywhere bool                `json:"spawnAnywhere,omitempty"`
+    SpawnRules    []SpawnRule         `json:"spawnRules"`
+    Player        int                 `json:"player"`
+    Characters    []CharacterSnapshot `json:"characters"`
+    Monsters      []MonsterSnapshot   `json:"monsters"`
+    Dens          []DenSnapshot       `json:"dens"`
+    Mushrooms     []resolv.Vector     `json:"mushrooms"`
+}
+
+// SnapshotMap holds the terrain of a saved world
+type SnapshotMap struct {
+    Width  int                  `json:"width"`
+    Height int                  `json:"height"`
+    Tiles  [][]gamemap.TileType `json:"tiles"`
+}
+
+// CharacterSnapshot holds the state of a character including its planner targets
+type CharacterSnapshot struct {
+    Name           string           `json:"name"`
+    Position       resolv.Vector    `json:"position"`
+    Velocity       resolv.Vector    `json:"velocity"`
+    Speed          float64          `json:"speed"`
+    Health         int              `json:"health"`
+    MaxHealth      int              `json:"maxHealth"`
+    Attack         units.Attack     `json:"attack"`
+    TargetMonster  int              `json:"targetMonster"`
+    WanderTarget   resolv.Vector    `json:"wanderTarget"`
+    WanderTime     time.Duration    `json:"wanderTime"`
+    SightRadius    float64          `json:"sightRadius"`
+    MemoryDuration time.Duration    `json:"memoryDuration"`
+    Memory         []MemorySnapshot `json:"memory,omitempty"`
+    CurrentGoal    string           `json:"currentGoal,omitempty"`
+    CurrentPlan    []string         `json:"currentPlan,omitempty"`
+    MushroomsEaten int              `json:"mushroomsEaten"`
+    SpawnPoint     resolv.Vector    `json:"spawnPoint"`
+    // RespawnAt is when a dead character comes back into the world
+    RespawnAt time.Duration `json:"respawnAt,omitempty"`
+}
+
+// MemorySnapshot holds a remembered object. Object is its index in the snapshot slice
+// for its tag, or -1 when it is no longer in the world.
+type MemorySnapshot struct {
+    Tag      string        `json:"tag"`
+    Object   int           `json:"object"`
+    Position resolv.Vector `json:"position"`
+    LastSeen time.Duration `json:"lastSeen"`
+    Visible  bool          `json:"visible,omitempty"`
+}
+
+// MonsterSnapshot holds the state of a monster
+type MonsterSnapshot struct {
+    Name         string        `json:"name"`
+    Position     resolv.Vector `json:"position"`
+    Direction    resolv.Vector `json:"direction"`
+    Velocity     resolv.Vector `json:"velocity"`
+    Speed        float64       `json:"speed"`
+    Health       int           `json:"health"`
+    MaxHealth    int           `json:"maxHealth"`
+    Attack       units.Attack  `json:"attack"`
+    Den          int           `json:"den"`
+    WanderRadius float64       `json:"wanderRadius"`
+    // State is the monster's behavior state, entered at StateSince
+    State      units.MonsterState `json:"state"`
+    StateSince time.Duration      `json:"stateSince"`
+    // Target is the index of the character the monster goes after, or -1
+    Target         int           `json:"target"`
+    TargetPosition resolv.Vector `json:"targetPosition"`
+    TargetLastSeen time.Duration `json:"targetLastSeen"`
+}
+
+// DenSnapshot holds the state of a goblin den including its spawn timer
+type DenSnapshot struct {
+    Position        resolv.Vector `json:"position"`
+    SpawnCooldown   time.Duration `json:"spawnCooldown"`
+    LastSpawnTime   time.Duration `json:"lastSpawnTime"`
+    MaxMonsters     int           `json:"maxMonsters"`
+    CurrentMonsters int           `json:"currentMonsters"`
+    Health          int           `json:"health"`
+    MaxHealth       int           `json:"maxHealth"`
+    // Destroyed dens are no longer in the space but are kept while their monsters are
+    // alive or a character remembers them
+    Destroyed bool `json:"destroyed,omitempty"`
+}
+
+// Snapshot captures the complete state of the world
+func (w *World) Snapshot() *Snapshot {
+    s := &Snapshot{
+        Version:   SnapshotVersion,
+        Seed:      w.Seed,
+        Tick:      w.Clock.Tick(),
+        RandDraws: w.source.Draws(),
+        Map: SnapshotMap{
+            Width:  w.GameMap.Width,
+            Height: w.GameMap.Height,
+            Tiles:  w.GameMap.Tiles,
+        },
+        Stats:         w.stats,
+        RespawnDelay:  w.RespawnDelay,
+        SpawnAnywhere: w.SpawnAnywhere,
+        SpawnRules:    slices.Clone(w.SpawnRules),
+        Player:        -1,
+    }
+
+    var characters []*units.Character
+    var monsters []*units.Monster
+    denIndex := make(map[*units.GoblinDen]int)
+    monsterIndex := make(map[*units.Monster]int)
+    mushroomIndex := make(map[*units.Mushroom]int)
+    addDen := func(den *units.GoblinDen) int {
+        if den == nil {
+            return -1
+        }
+        if i, ok := denIndex[den]; ok {
+            return i
+        }
+        denIndex[den] = len(s.Dens)
+        s.Dens = append(s.Dens, DenSnapshot{
+            Position:        den.Object.Position,
+            SpawnCooldown:   den.SpawnCooldown,
+            LastSpawnTime:   den.LastSpawnTime,
+            MaxMonsters:     den.MaxMonsters,
+            CurrentMonsters: den.CurrentMonsters,
+            Health:          den.Health,
+            MaxHealth:       den.MaxHealth,
+            Destroyed:       den.Object.Space == nil,
+        })
+        return denIndex[den]
+    }
+
+    for _, obj := range w.Space.Objects() {
+        switch unit := obj.Data.(type) {
+        case *units.Character:
+            characters = append(characters, unit)
+        case *units.Monster:
+            monsterIndex[unit] = len(monsters)
+            monsters = append(monsters, unit)
+        case *units.GoblinDen:
+            addDen(unit)
+        case *units.Mushroom:
+            mushroomIndex[unit] = len(s.Mushrooms)
+            s.Mushrooms = append(s.Mushrooms, unit.Object.Position)
+        }
+    }
+
+    respawnAt := make(map[*units.Character]time.Duration)
+    for _, r := range w.respawns {
+        respawnAt[r.character] = r.at
+        characters = append(characters, r.character)
+    }
+
+    characterIndex := make(map[*units.Character]int)
+    for i, c := range characters {
+        characterIndex[c] = i
+    }
+    for _, m := range monsters {
+        target := -1
+        if idx, ok := characterIndex[m.Target]; ok {
+            target = idx
+        }
+        s.Monsters = append(s.Monsters, MonsterSnapshot{
+            Name:           m.Name,
+            Position:       m.Object.Position,
+            Direction:      resolv.NewVector(m.Direction.X, m.Direction.Y),
+            Velocity:       m.Velocity,
+            Speed:          m.Speed,
+            Health:         m.Health,
+            MaxHealth:      m.MaxHealth,
+            Attack:         m.Attack,
+            Den:            addDen(m.Den),
+            WanderRadius:   m.WanderRadius,
+            State:          m.State,
+            StateSince:     m.StateSince,
+            Target:         target,
+            TargetPosition: m.TargetPosition,
+            TargetLastSeen: m.TargetLastSeen,
+        })
+    }
+
+    for i, c := range characters {
+        if c == w.Player {
+            s.Player = i
+        }
+        target := -1
+        if idx, ok := monsterIndex[c.TargetMonster]; ok {
+            target = idx
+        }
+        var plan []string
+        for _, action := range c.Executor.Plan {
+            plan = append(plan, action.Name)
+        }
+        var memory []MemorySnapshot
+        for _, entry := range c.Perception.Memory {
+            index := -1
+            switch unit := entry.Object.Data.(type) {
+            case *units.Monster:
+                if idx, ok := monsterIndex[unit]; ok {
+                    index = idx
+                }
+            case *units.GoblinDen:
+                index = addDen(unit)
+            case *units.Mushroom:
+                if idx, ok := mushroomIndex[unit]; ok {
+                    index = idx
+                }
+            }
+            memory = append(memory, MemorySnapshot{
+                Tag:      entry.Tag,
+                Object:   index,
+                Position: entry.Position,
+                LastSeen: entry.LastSeen,
+                Visible:  entry.Visible,
+            })
+        }
+        s.Characters = append(s.Characters, CharacterSnapshot{
+            Name:           c.Name,
+            Position:       c.Object.Position,
+            Velocity:       c.Velocity,
+            Speed:          c.Speed,
+            Health:         c.Health,
+            MaxHealth:      c.MaxHealth,
+            Attack:         c.Attack,
+            TargetMonster:  target,
+            WanderTarget:   c.WanderTarget,
+            WanderTime:     c.WanderTime,
+            SightRadius:    c.Perception.SightRadius,
+            MemoryDuration: c.Perception.MemoryDuration,
+            Memory:         memory,
+            CurrentGoal:    c.Executor.Goal.Name,
+            CurrentPlan:    plan,
+            MushroomsEaten: c.MushroomsEaten,
+            SpawnPoint:     c.SpawnPoint,
+            RespawnAt:      respawnAt[c],
+        })
+    }
+
+    return s
+}
+
+// Save writes the world state as JSON
+func (w *World) Save(wr io.Writer) error {
+    encoder := json.NewEncoder(wr)
+    return encoder.Encode(w.Snapshot())
+}
+
+// SaveFile writes the world state to a file
+func (w *World) SaveFile(filename string) error {
+    f, err := os.Create(filename)
+    if err != nil {
+        return err
+    }
+    if err := w.Save(f); err != nil {
+        f.Close()
+        return err
+    }
+    return f.Close()
+}
+
+// LoadWorld restores a world written by World.Save into a fresh collision space
+func LoadWorld(r io.Reader) (*World, error) {
+    var s Snapshot
+    if err := json.NewDecoder(r).Decode(&s); err != nil {
+        return nil, fmt.Errorf("decode snapshot: %w", err)
+    }
+    return NewWorldFromSnapshot(&s)
+}
+
+// LoadWorldFile restores a world from a file written by World.SaveFile
+func LoadWorldFile(filename string) (*World, error) {
+    f, err := os.Open(filename)
+    if err != nil {
+        return nil, err
+    }
+    defer f.Close()
+    return LoadWorld(f)
+}
+
+// NewWorldFromSnapshot rebuilds a world from a snapshot.
+// The random source is reseeded from the snapshot seed and tick, so loading the
+// same snapshot always continues the same way.
+func NewWorldFromSnapshot(s *Snapshot) (*World, error) {
+    if s.Version != SnapshotVersion {
+        return nil, fmt.Errorf("unsupported snapshot version %d, expected %d", s.Version, SnapshotVersion)
+    }
+    if s.Map.Width <= 0 || s.Map.Height <= 0 {
+        return nil, fmt.Errorf("snapshot map has invalid size %dx%d", s.Map.Width, s.Map.Height)
+    }
+    if len(s.Map.Tiles) != s.Map.Height {
+        return nil, fmt.Errorf("snapshot map has %d rows, expected %d", len(s.Map.Tiles), s.Map.Height)
+    }
+
+    for y, row := range s.Map.Tiles {
+        if len(row) != s.Map.Width {
+            return nil, fmt.Errorf("snapshot map row %d has %d tiles, expected %d", y, len(row), s.Map.Width)
+        }
+        for x, tile := range row {
+            if !tile.Valid() {
+                return nil, fmt.Errorf("snapshot map has unknown tile type %d at %d,%d", int(tile), x, y)
+            }
+        }
+    }
+
+    gameMap := &gamemap.GameMap{Tiles: s.Map.Tiles, Width: s.Map.Width, Height: s.Map.Height}
+    w := newEmptyWorld(gameMap, sim.NewClockAt(s.Tick), s.Seed)
+    w.stats = s.Stats
+    w.RespawnDelay = s.RespawnDelay
+    w.SpawnAnywhere = s.SpawnAnywhere
+    for i, rule := range s.SpawnRules {
+        if _, ok := spawnKinds[rule.Kind]; !ok {
+            return nil, fmt.Errorf("spawn rule %d spawns unknown kind %q", i, rule.Kind)
+        }
+    }
+    w.SpawnRules = s.SpawnRules
+
+    dens := make([]*units.GoblinDen, len(s.Dens))
+    for i, ds := range s.Dens {
+        den := units.NewGoblinDen(w.Space, w.Clock, w.Rand, ds.Position.X, ds.Position.Y)
+        den.SpawnCooldown = ds.SpawnCooldown
+        den.LastSpawnTime = ds.LastSpawnTime
+        den.MaxMonsters = ds.MaxMonsters
+        den.CurrentMonsters = ds.CurrentMonsters
+        den.Health = ds.Health
+        den.MaxHealth = ds.MaxHealth
+        den.Events = w.Events
+        if ds.Destroyed {
+            w.Space.Remove(den.Object)
+        } else {
+            w.Changes.MarkObject(w.Space, den.Object)
+        }
+        dens[i] = den
+    }
+
+    monsters := make([]*units.Monster, len(s.Monsters))
+    for i, ms := range s.Monsters {
+        if ms.Den < -1 || ms.Den >= len(dens) {
+            return nil, fmt.Errorf("monster %d refers to unknown den %d", i, ms.Den)
+        }
+        var den *units.GoblinDen
+        if ms.Den >= 0 {
+            den = dens[ms.Den]
+        }
+        m := units.NewMonster(ms.Position.X, ms.Position.Y, den)
+        m.Name = ms.Name
+        m.Clock = w.Clock
+        m.Rand = w.Rand
+        m.Direction.X, m.Direction.Y = ms.Direction.X, ms.Direction.Y
+        m.Velocity = ms.Velocity
+        m.Speed = ms.Speed
+        m.Health = ms.Health
+        m.MaxHealth = ms.MaxHealth
+        m.Attack = ms.Attack
+        m.WanderRadius = ms.WanderRadius
+        m.State = ms.State
+        m.StateSince = ms.StateSince
+        m.TargetPosition = ms.TargetPosition
+        m.TargetLastSeen = ms.TargetLastSeen
+        w.addMonster(m)
+        monsters[i] = m
+    }
+
+    mushrooms := make([]*units.Mushroom, len(s.Mushrooms))
+    for i, position := range s.Mushrooms {
+        mushrooms[i] = units.NewMushroom(w.Space, position.X, position.Y)
+    }
+
+    characters := make([]*units.Character, len(s.Characters))
+    for i, cs := range s.Characters {
+        // The saved player is the only character without an NPC planner, whatever its name
+        c := units.NewPlayer(cs.Position.X, cs.Position.Y, cs.Name)
+        if i != s.Player {
+            c.IsPlayer = false
+            units.InitNPCGOAP(c)
+        }
+        c.Speed = cs.Speed
+        c.Velocity = cs.Velocity
+        c.Health = cs.Health
+        c.MaxHealth = cs.MaxHealth
+        c.Attack = cs.Attack
+        c.WanderTarget = cs.WanderTarget
+        c.WanderTime = cs.WanderTime
+        c.MushroomsEaten = cs.MushroomsEaten
+        c.SpawnPoint = cs.SpawnPoint
+        if cs.SightRadius > 0 {
+            c.Perception.SightRadius = cs.SightRadius
+            c.Perception.MemoryDuration = cs.MemoryDuration
+        }
+        for _, ms := range cs.Memory {
+            obj, err := rememberedObject(ms, monsters, dens, mushrooms)
+            if err != nil {
+                return nil, fmt.Errorf("character %q: %w", cs.Name, err)
+            }
+            c.Perception.Memory = append(c.Perception.Memory, units.MemoryEntry{
+                Object:   obj,
+                Tag:      ms.Tag,
+                Position: ms.Position,
+                LastSeen: ms.LastSeen,
+                Visible:  ms.Visible,
+            })
+        }
+        if cs.TargetMonster < -1 || cs.TargetMonster >= len(monsters) {
+            return nil, fmt.Errorf("character %q targets unknown monster %d", cs.Name, cs.TargetMonster)
+        }
+        if cs.TargetMonster >= 0 {
+            c.TargetMonster = monsters[cs.TargetMonster]
+        }
+        plan, err := restorePlan(c.Planner, cs.CurrentPlan)
+        if err != nil {
+            return nil, fmt.Errorf("character %q: %w", cs.Name, err)
+        }
+        c.Executor.Plan = plan
+        c.Executor.Goal = restoreGoal(c.Behavior, cs.CurrentGoal)
+
+        w.AddCharacter(c)
+        if !c.Alive() {
+            // Dead characters wait outside of the space for their respawn
+            w.Space.Remove(c.Object)
+            w.respawns = append(w.respawns, respawn{character: c, at: cs.RespawnAt})
+        }
+        characters[i] = c
+    }
+    for i, ms := range s.Monsters {
+        if ms.Target < -1 || ms.Target >= len(characters) {
+            return nil, fmt.Errorf("monster %d targets unknown character %d", i, ms.Target)
+        }
+        if ms.Target >= 0 {
+            monsters[i].Target = characters[ms.Target]
+        }
+    }
+
+    w.Player = nil
+    if s.Player >= 0 && s.Player < len(characters) {
+        w.Player = characters[s.Player]
+    }
+    // Restoring the units drew values of their own, the saved world continues after RandDraws
+    w.source.Seek(s.RandDraws)
+    return w, nil
+}
+
+// restorePlan looks up saved action names in the character's planner
+func restorePlan(planner *ai.GOAPPlanner, names []string) ([]ai.GOAPAction, error) {
+    if len(names) == 0 {
+        return nil, nil
+    }
+    if planner == nil {
+        return nil, fmt.Errorf("plan saved for a character without a planner")
+    }
+    plan := make([]ai.GOAPAction, 0, len(names))
+    for _, name := range names {
+        found := false
+        for _, action := range planner.Actions {
+            if action.Name == name {
+                plan = append(plan, action)
+                found = true
+                break
+            }
+        }
+        if !found {
+            return nil, fmt.Errorf("unknown plan action %q", name)
+        }
+    }
+    return plan, nil
+}
+
+// rememberedObject resolves the object of a memory entry. Objects that are gone from the
+// world are replaced by a detached object, so they are forgotten once their spot is seen.
+func rememberedObject(ms MemorySnapshot, monsters []*units.Monster, dens []*units.GoblinDen, mushrooms []*units.Mushroom) (*resolv.Object, error) {
+    var count int
+    var object func(i int) *resolv.Object
+    switch ms.Tag {
+    case "monster":
+        count, object = len(monsters), func(i int) *resolv.Object { return monsters[i].Object }
+    case "goblin_den":
+        count, object = len(dens), func(i int) *resolv.Object { return dens[i].Object }
+    case "mushroom":
+        count, object = len(mushrooms), func(i int) *resolv.Object { return mushrooms[i].Object }
+    default:
+        return nil, fmt.Errorf("memory of unknown kind %q", ms.Tag)
+    }
+    if ms.Object < -1 || ms.Object >= count {
+        return nil, fmt.Errorf("memory refers to unknown %s %d", ms.Tag, ms.Object)
+    }
+    if ms.Object >= 0 {
+        return object(ms.Object), nil
+    }
+    size := float64(gamemap.TileSize)
+    obj := resolv.NewObject(ms.Position.X-size/2, ms.Position.Y-size/2, size, size)
+    obj.AddTags(ms.Tag)
+    return obj, nil
+}
+
+// restoreGoal looks up a saved goal name in the character's behavior.
+// Unknown goals are dropped and the character plans again on its next update.
+func restoreGoal(behavior *ai.Behavior, name string) ai.ScoredGoal {
+    if behavior == nil {
+        return ai.ScoredGoal{}
+    }
+    for _, goal := range behavior.Goals {
+        if goal.Name == name {
+            return ai.ScoredGoal{Name: goal.Name, State: goal.Goal}
+        }
+    }
+    return ai.ScoredGoal{}
+}
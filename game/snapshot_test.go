@@ -0,0 +1,72 @@
+package game
+
+import (
+    "bytes"
+    gamemap "example.com/maj/map"
+    "example.com/maj/units"
+    "github.com/stretchr/testify/assert"
+    "testing"
+)
+
+func TestSnapshotRoundTrip(t *testing.T) {
+    world := NewWorldFromMap(gamemap.MustLoadGameMapFile("../map/map1.txt"), 3)
+    world.AddCharacter(units.NewPlayer(float64(3*gamemap.TileSize), float64(3*gamemap.TileSize), "Hero"))
+    world.AddCharacter(units.NewCharacter(float64(4*gamemap.TileSize), float64(4*gamemap.TileSize), "NPC1"))
+    world.AddCharacter(units.NewCharacter(float64(5*gamemap.TileSize), float64(4*gamemap.TileSize), "NPC2"))
+    for i := 0; i < 600; i++ {
+        world.Update()
+    }
+
+    var buf bytes.Buffer
+    assert.NoError(t, world.Save(&buf))
+    loaded, err := LoadWorld(bytes.NewReader(buf.Bytes()))
+    assert.NoError(t, err)
+
+    assert.Equal(t, world.Snapshot(), loaded.Snapshot())
+    assert.Equal(t, world.Stats(), loaded.Stats())
+    assert.Equal(t, "Hero", loaded.GetPlayerCharacter().Name)
+    for _, c := range loaded.Characters() {
+        assert.Equal(t, c == loaded.Player, c.IsPlayer, c.Name)
+        assert.Equal(t, c.IsPlayer, c.Planner == nil, c.Name)
+    }
+
+    t.Run("A loaded world continues like the saved one", func(t *testing.T) {
+        saved, err := LoadWorld(bytes.NewReader(buf.Bytes()))
+        if !assert.NoError(t, err) {
+            return
+        }
+        for i := 0; i < 300; i++ {
+            world.Update()
+            saved.Update()
+        }
+        assert.Equal(t, world.Snapshot(), saved.Snapshot())
+    })
+
+    t.Run("Loading the same snapshot twice continues the same way", func(t *testing.T) {
+        other, err := LoadWorld(bytes.NewReader(buf.Bytes()))
+        assert.NoError(t, err)
+        for i := 0; i < 300; i++ {
+            loaded.Update()
+            other.Update()
+        }
+        assert.Equal(t, loaded.Snapshot(), other.Snapshot())
+    })
+
+    t.Run("Unknown version", func(t *testing.T) {
+        _, err := LoadWorld(bytes.NewReader([]byte(`{"version": 99}`)))
+        assert.Error(t, err)
+    })
+
+    t.Run("Maps with a short row or no tiles are rejected", func(t *testing.T) {
+        snapshot := world.Snapshot()
+        snapshot.Map.Tiles = append([][]gamemap.TileType{}, snapshot.Map.Tiles...)
+        snapshot.Map.Tiles[3] = snapshot.Map.Tiles[3][:len(snapshot.Map.Tiles[3])-1]
+        _, err := NewWorldFromSnapshot(snapshot)
+        assert.ErrorContains(t, err, "row 3")
+
+        snapshot = world.Snapshot()
+        snapshot.Map.Width, snapshot.Map.Height, snapshot.Map.Tiles = 0, 0, nil
+        _, err = NewWorldFromSnapshot(snapshot)
+        assert.ErrorContains(t, err, "invalid size")
+    })
+}
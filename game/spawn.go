@@ -0,0 +1,165 @@
+package game
+
+import (
+    gamemap "example.com/maj/map"
+    "example.com/maj/units"
+    "fmt"
+    "github.com/solarlune/resolv"
+    "log/slog"
+    "slices"
+    "time"
+)
+
+// spawnAttempts is how many random tiles a spawn rule tries before skipping a spawn
+const spawnAttempts = 100
+
+// spawnKinds creates the object of an entity a spawn rule can spawn, keyed by kind
+var spawnKinds = map[string]func(w *World, x, y float64) *resolv.Object{
+    "mushroom": func(w *World, x, y float64) *resolv.Object {
+        return units.NewMushroom(w.Space, x, y).Object
+    },
+    "goblin_den": func(w *World, x, y float64) *resolv.Object {
+        den := units.NewGoblinDen(w.Space, w.Clock, w.Rand, x, y)
+        den.Events = w.Events
+        return den.Object
+    },
+}
+
+// SpawnRule spawns entities of a kind on the simulation clock. Rules run in World.Update,
+// so spawning never races with the units or the renderer reading the space.
+type SpawnRule struct {
+    // Kind is the tag of the spawned entities, "mushroom" or "goblin_den"
+    Kind string `json:"kind"`
+    // Every is the simulated time between two spawns and Count the entities spawned each time
+    Every time.Duration `json:"every"`
+    Count int           `json:"count"`
+    // Cap is the most entities of the kind in the world at once, zero for no limit
+    Cap int `json:"cap,omitempty"`
+    // Tiles are the tile types entities spawn on, any spawnable tile when empty
+    Tiles []gamemap.TileType `json:"tiles,omitempty"`
+    // Next is when the rule spawns next
+    Next time.Duration `json:"next"`
+}
+
+// DefaultSpawnRules returns the rules of a new world: a mushroom grows every three seconds
+// until there are 60 of them
+func DefaultSpawnRules() []SpawnRule {
+    return []SpawnRule{
+        {Kind: "mushroom", Every: 3 * time.Second, Count: 1, Cap: 60, Next: 3 * time.Second},
+    }
+}
+
+// runSpawnRules spawns the entities of the rules that are due
+func (w *World) runSpawnRules() {
+    now := w.Clock.Now()
+    for i := range w.SpawnRules {
+        rule := &w.SpawnRules[i]
+        if rule.Every <= 0 || now < rule.Next {
+            continue
+        }
+        rule.Next = now + rule.Every
+        count := rule.Count
+        if rule.Cap > 0 {
+            count = min(count, rule.Cap-w.countTagged(rule.Kind))
+        }
+        for n := 0; n < count; n++ {
+            x, y, ok := w.findRuleSpawnPoint(rule)
+            if !ok {
+                break
+            }
+            w.spawnKind(rule.Kind, x, y)
+        }
+    }
+}
+
+// countTagged returns the number of objects in the space with a tag
+func (w *World) countTagged(tag string) int {
+    count := 0
+    for _, obj := range w.Space.Objects() {
+        if obj.HasTags(tag) {
+            count++
+        }
+    }
+    return count
+}
+
+// findRuleSpawnPoint picks a random free tile allowed by a rule, giving up after spawnAttempts tries
+func (w *World) findRuleSpawnPoint(rule *SpawnRule) (int, int, bool) {
+    for i := 0; i < spawnAttempts; i++ {
+        x, y := w.Rand.Intn(w.GameMap.Width), w.Rand.Intn(w.GameMap.Height)
+        if len(rule.Tiles) > 0 && !slices.Contains(rule.Tiles, w.GameMap.Tiles[y][x]) {
+            continue
+        }
+        if w.IsSpawnPointValid(x, y) && len(w.Space.CheckCells(x, y, 1, 1, rule.Kind)) == 0 {
+            return x, y, true
+        }
+    }
+    return 0, 0, false
+}
+
+// spawnKind creates an entity of a kind on a tile and announces it
+func (w *World) spawnKind(kind string, x, y int) {
+    w.spawn(spawnKinds[kind](w, float64(x*gamemap.TileSize), float64(y*gamemap.TileSize)))
+}
+
+// spawnMapPoints spawns the entities of the map's spawn points of a kind and reports
+// whether the map declares any. Points cut off from the main region are skipped unless
+// SpawnAnywhere is set.
+func (w *World) spawnMapPoints(kind string) bool {
+    found, npcs := false, 0
+    for _, point := range w.GameMap.Spawns {
+        if point.Kind != kind {
+            continue
+        }
+        found = true
+        if !w.SpawnAnywhere && !w.mapReport.Reaches(point) {
+            w.log(slog.LevelWarn, "", "skipped spawn point cut off from the main region", "kind", kind, "x", point.X, "y", point.Y)
+            continue
+        }
+        x, y := float64(point.X*gamemap.TileSize), float64(point.Y*gamemap.TileSize)
+        switch kind {
+        case gamemap.SpawnPlayer:
+            w.AddCharacter(units.NewPlayer(x, y, point.StringProperty("name", "Player")))
+        case gamemap.SpawnNPC:
+            npcs++
+            name := point.StringProperty("name", fmt.Sprintf("NPC%d", npcs))
+            w.AddCharacter(units.NewCharacter(x, y, name))
+        case gamemap.SpawnGoblinDen:
+            den := units.NewGoblinDen(w.Space, w.Clock, w.Rand, x, y)
+            den.Events = w.Events
+            den.MaxMonsters = point.IntProperty("maxMonsters", den.MaxMonsters)
+            den.Health = point.IntProperty("health", den.Health)
+            den.MaxHealth = den.Health
+            den.SpawnCooldown = time.Duration(point.IntProperty("cooldown", int(den.SpawnCooldown/time.Second))) * time.Second
+            den.LastSpawnTime = w.Clock.Now() - den.SpawnCooldown
+            w.spawn(den.Object)
+        case gamemap.SpawnMushroomField:
+            w.spawnMushroomField(point)
+        }
+    }
+    return found
+}
+
+// spawnDefaultPlayer adds a player on the first NPC spawn point that spawnMapPoints used,
+// for maps whose player spawn is missing or was skipped
+func (w *World) spawnDefaultPlayer() {
+    for _, point := range w.GameMap.Spawns {
+        if point.Kind == gamemap.SpawnNPC && (w.SpawnAnywhere || w.mapReport.Reaches(point)) {
+            w.AddCharacter(units.NewPlayer(float64(point.X*gamemap.TileSize), float64(point.Y*gamemap.TileSize), "Player"))
+            return
+        }
+    }
+}
+
+// spawnMushroomField grows the "count" mushrooms of a field, three by default, on its free tiles
+func (w *World) spawnMushroomField(field gamemap.SpawnPoint) {
+    width, height := field.Size()
+    count := field.IntProperty("count", 3)
+    for i := 0; i < count*spawnAttempts && count > 0; i++ {
+        x, y := field.X+w.Rand.Intn(width), field.Y+w.Rand.Intn(height)
+        if w.IsSpawnPointValid(x, y) && len(w.Space.CheckCells(x, y, 1, 1, "mushroom")) == 0 {
+            w.spawnKind("mushroom", x, y)
+            count--
+        }
+    }
+}
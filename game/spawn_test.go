@@ -0,0 +1,189 @@
+package game
+
+import (
+    gamemap "example.com/maj/map"
+    "example.com/maj/sim"
+    "example.com/maj/units"
+    "github.com/solarlune/resolv"
+    "github.com/stretchr/testify/assert"
+    "runtime"
+    "testing"
+    "time"
+)
+
+// mushrooms returns the mushrooms in the world
+func mushrooms(w *World) []*units.Mushroom {
+    var result []*units.Mushroom
+    for _, obj := range w.Space.Objects() {
+        if m, ok := obj.Data.(*units.Mushroom); ok {
+            result = append(result, m)
+        }
+    }
+    return result
+}
+
+// TestSpawnRules is meant to be run with -race as well: spawning must happen on the
+// goroutine that calls Update, never concurrently with readers of the space.
+func TestSpawnRules(t *testing.T) {
+    t.Run("Mushrooms grow on the simulation clock without a goroutine", func(t *testing.T) {
+        goroutines := runtime.NumGoroutine()
+        w := NewWorldFromMap(gamemap.MustLoadGameMapFile("../map/map1.txt"), 1)
+        w.SpawnRules = []SpawnRule{{Kind: "mushroom", Every: time.Second, Count: 2, Next: time.Second}}
+        initial := len(mushrooms(w))
+
+        for i := 0; i < 3*sim.TicksPerSecond; i++ {
+            w.Update()
+            // Read the space between ticks like the renderer does
+            mushrooms(w)
+        }
+        assert.Equal(t, goroutines, runtime.NumGoroutine())
+        assert.GreaterOrEqual(t, len(mushrooms(w))+w.Stats().MushroomsEaten, initial+6)
+    })
+
+    t.Run("Spawns stop at the cap and stay on the allowed tiles", func(t *testing.T) {
+        w := newEmptyWorld(gamemap.MustLoadGameMapFile("../map/map1.txt"), sim.NewClock(), 1)
+        road := gamemap.TileRoad
+        for x := 1; x < 20; x++ {
+            w.GameMap.Tiles[2][x] = road
+        }
+        w.SpawnRules = []SpawnRule{{Kind: "mushroom", Every: time.Second / 10, Count: 3, Cap: 5, Tiles: []gamemap.TileType{road}}}
+
+        for i := 0; i < 5*sim.TicksPerSecond; i++ {
+            w.Update()
+        }
+        spawned := mushrooms(w)
+        assert.Len(t, spawned, 5)
+        for _, m := range spawned {
+            x, y := w.Space.WorldToSpaceVec(m.Object.Position)
+            assert.Equal(t, road, w.GameMap.Tiles[y][x])
+        }
+    })
+
+    t.Run("Rules without an allowed free tile skip their spawn", func(t *testing.T) {
+        w := newEmptyWorld(gamemap.MustLoadGameMapFile("../map/map1.txt"), sim.NewClock(), 1)
+        w.SpawnRules = []SpawnRule{{Kind: "mushroom", Every: time.Second, Count: 1, Tiles: []gamemap.TileType{gamemap.TileWater}}}
+        for i := 0; i < 2*sim.TicksPerSecond; i++ {
+            w.Update()
+        }
+        assert.Empty(t, mushrooms(w))
+    })
+}
+
+func TestSpawnsStayInTheMainRegion(t *testing.T) {
+    // A sealed pocket in the top left corner of an open field
+    gameMap := gamemap.MustLoadGameMapFile("../map/map1.txt")
+    for i := 1; i <= 8; i++ {
+        gameMap.Tiles[8][i] = gamemap.TileMountain
+        gameMap.Tiles[i][8] = gamemap.TileMountain
+    }
+    inPocket := func(w *World) int {
+        count := 0
+        for _, obj := range w.Space.Objects() {
+            x, y := w.Space.WorldToSpaceVec(obj.Position)
+            if obj.HasTags("mushroom", "goblin_den") && x < 8 && y < 8 {
+                count++
+            }
+        }
+        return count
+    }
+
+    t.Run("Nothing spawns in sealed regions", func(t *testing.T) {
+        w := NewWorldFromMap(gameMap, 1)
+        w.SpawnRules = []SpawnRule{{Kind: "mushroom", Every: time.Second / 10, Count: 20}}
+        for i := 0; i < 3*sim.TicksPerSecond; i++ {
+            w.Update()
+        }
+        assert.NotEmpty(t, mushrooms(w))
+        assert.Zero(t, inPocket(w))
+        for y := 1; y < 8; y++ {
+            for x := 1; x < 8; x++ {
+                assert.False(t, w.IsSpawnPointValid(x, y))
+            }
+        }
+    })
+
+    t.Run("Map spawn points in sealed regions are skipped", func(t *testing.T) {
+        pocketMap := *gameMap
+        pocketMap.Spawns = []gamemap.SpawnPoint{
+            {Kind: gamemap.SpawnNPC, X: 3, Y: 3},
+            {Kind: gamemap.SpawnGoblinDen, X: 4, Y: 4},
+            {Kind: gamemap.SpawnNPC, X: 20, Y: 20},
+        }
+        w := NewWorldFromMap(&pocketMap, 1)
+        if !assert.Len(t, w.Characters(), 2) || !assert.NotNil(t, w.Player) {
+            return
+        }
+        assert.Zero(t, inPocket(w))
+        assert.True(t, w.Player.IsPlayer)
+        assert.Equal(t, resolv.NewVector(float64(20*gamemap.TileSize), float64(20*gamemap.TileSize)), w.Player.Object.Position)
+    })
+
+    t.Run("Maps with only NPC spawns get a player", func(t *testing.T) {
+        npcMap := *gameMap
+        npcMap.Spawns = []gamemap.SpawnPoint{
+            {Kind: gamemap.SpawnNPC, X: 20, Y: 20, Properties: map[string]any{"name": "Scout"}},
+            {Kind: gamemap.SpawnNPC, X: 22, Y: 20},
+        }
+        w := NewWorldFromMap(&npcMap, 1)
+        if !assert.NotNil(t, w.Player) {
+            return
+        }
+        assert.Equal(t, "Player", w.Player.Name)
+        assert.True(t, w.Player.IsPlayer)
+        assert.Nil(t, w.Player.Planner)
+        players := 0
+        for _, c := range w.Characters() {
+            if c.IsPlayer {
+                players++
+            } else {
+                assert.NotNil(t, c.Planner, c.Name)
+            }
+        }
+        assert.Equal(t, 1, players)
+        assert.Len(t, w.Characters(), 3)
+    })
+
+    t.Run("A named player spawn does not plan like an NPC", func(t *testing.T) {
+        heroMap := *gameMap
+        heroMap.Spawns = []gamemap.SpawnPoint{
+            {Kind: gamemap.SpawnPlayer, X: 20, Y: 20, Properties: map[string]any{"name": "Hero"}},
+            {Kind: gamemap.SpawnNPC, X: 22, Y: 20},
+        }
+        w := NewWorldFromMap(&heroMap, 1)
+        if !assert.NotNil(t, w.Player) {
+            return
+        }
+        assert.Equal(t, "Hero", w.Player.Name)
+        assert.Nil(t, w.Player.Planner)
+        assert.Len(t, w.Characters(), 2)
+    })
+
+    t.Run("SpawnAnywhere lifts the restriction", func(t *testing.T) {
+        w := newEmptyWorld(gameMap, sim.NewClock(), 1)
+        w.SpawnAnywhere = true
+        assert.True(t, w.IsSpawnPointValid(3, 3))
+
+        w.GameMap = &gamemap.GameMap{Tiles: gameMap.Tiles, Width: gameMap.Width, Height: gameMap.Height,
+            Spawns: []gamemap.SpawnPoint{{Kind: gamemap.SpawnGoblinDen, X: 4, Y: 4}}}
+        w.spawnMapPoints(gamemap.SpawnGoblinDen)
+        assert.Equal(t, 1, inPocket(w))
+    })
+
+    t.Run("Maps without spawnable tiles do not hang", func(t *testing.T) {
+        rock := &gamemap.GameMap{Width: 12, Height: 12}
+        for y := 0; y < rock.Height; y++ {
+            rock.Tiles = append(rock.Tiles, make([]gamemap.TileType, rock.Width))
+            for x := range rock.Tiles[y] {
+                rock.Tiles[y][x] = gamemap.TileMountain
+            }
+        }
+        rock.Tiles[6][6] = gamemap.TileWater
+        w := NewWorldFromMap(rock, 1)
+        assert.Empty(t, mushrooms(w))
+        assert.Zero(t, w.countTagged("goblin_den"))
+
+        tiny := &gamemap.GameMap{Width: 3, Height: 3, Tiles: [][]gamemap.TileType{{1, 1, 1}, {1, 0, 1}, {1, 1, 1}}}
+        w = NewWorldFromMap(tiny, 1)
+        assert.Equal(t, 1, w.countTagged("goblin_den")+w.countTagged("mushroom"))
+    })
+}
@@ -1,100 +1,249 @@
 package game
 
 import (
+    "example.com/maj/events"
     gamemap "example.com/maj/map"
+    "example.com/maj/map/analysis"
+    "example.com/maj/pathfinding"
+    "example.com/maj/sim"
     "example.com/maj/units"
-    "github.com/hajimehoshi/ebiten/v2"
     "github.com/solarlune/resolv"
+    "log/slog"
     "math/rand"
+    "runtime"
+    "sync"
     "time"
 )
 
 type World struct {
-    GameMap         *gamemap.GameMap
-    MonsterSprite   *ebiten.Image
-    GoblinDenSprite *ebiten.Image
-    Space           *resolv.Space
-    Player          *units.Character
+    GameMap *gamemap.GameMap
+    Space   *resolv.Space
+    Player  *units.Character
+    Clock   *sim.Clock
+    Seed    int64
+    Rand    *rand.Rand
+    // Changes logs the cells whose obstacles or terrain changed for the pathfinding caches
+    Changes *pathfinding.Changes
+    // FlowFields caches the flow fields NPCs follow towards shared targets
+    FlowFields *pathfinding.FlowFields
+    // Hierarchy is the clustered abstraction of the map routes to distant targets are searched on
+    Hierarchy *pathfinding.Hierarchy
+    // RespawnDelay is how long dead characters wait before coming back at their spawn point.
+    // Dead characters do not come back when it is negative.
+    RespawnDelay time.Duration
+    // Workers is the number of goroutines NPCs think on during Update, one when not positive.
+    // The simulation runs the same for any number of workers.
+    Workers int
+    // SpawnRules keep spawning entities such as mushrooms as the simulation runs
+    SpawnRules []SpawnRule
+    // Events carries what happens in the world to whoever subscribes, see the units event types
+    Events *events.Bus
+    // SpawnAnywhere lets entities spawn in regions of the map cut off from the main one
+    SpawnAnywhere bool
+    // Logger writes the records of the world and its units, the default logger when nil
+    Logger *slog.Logger
+
+    // mapReport is the connectivity of the map, telling the main region spawns are kept to
+    mapReport *analysis.Report
+    // source is the random source of Rand, counting its draws for snapshots
+    source   *sim.Source
+    stats    Stats
+    respawns []respawn
+    // killers holds the source of the fatal damage of units that die this tick
+    killers map[any]any
+}
+
+// Stats summarizes what happened in the world so far
+type Stats struct {
+    Ticks          uint64
+    NPCDeaths      int
+    DensDestroyed  int
+    MushroomsEaten int
+    // MonstersSpawned numbers the monsters' names
+    MonstersSpawned int
+    MonstersKilled  int
+}
+
+// NewWorld creates a world for the given map with a seed taken from the current time
+func NewWorld(gameMap *gamemap.GameMap) *World {
+    return NewWorldFromMap(gameMap, time.Now().UnixNano())
+}
+
+// NewWorldFromMap creates a world for the given map with the characters, dens and mushroom
+// fields it declares. Maps without dens or mushroom fields get them at random places, and
+// maps with NPCs but no usable player spawn start the player with the first NPC.
+// The same seed and map always produce the same spawns and unit movement.
+func NewWorldFromMap(gameMap *gamemap.GameMap, seed int64) *World {
+    w := newEmptyWorld(gameMap, sim.NewClock(), seed)
+    if !w.spawnMapPoints(gamemap.SpawnGoblinDen) {
+        w.spawnGoblinDens(10)
+    }
+    if !w.spawnMapPoints(gamemap.SpawnMushroomField) {
+        w.spawnMushrooms(30, w.Rand)
+    }
+    w.spawnMapPoints(gamemap.SpawnPlayer)
+    w.spawnMapPoints(gamemap.SpawnNPC)
+    if w.Player == nil {
+        w.spawnDefaultPlayer()
+    }
+    return w
 }
 
-func NewWorld() *World {
-    gameMap := gamemap.NewGameMap()
+// newEmptyWorld creates a world with only the map terrain in its collision space
+func newEmptyWorld(gameMap *gamemap.GameMap, clock *sim.Clock, seed int64) *World {
+    source := sim.NewSource(seed)
     w := &World{
-        GameMap: gameMap,
-        Space:   resolv.NewSpace(gameMap.Width*gamemap.TileSize, gameMap.Height*gamemap.TileSize, gamemap.TileSize, gamemap.TileSize),
+        GameMap:      gameMap,
+        Space:        resolv.NewSpace(gameMap.Width*gamemap.TileSize, gameMap.Height*gamemap.TileSize, gamemap.TileSize, gamemap.TileSize),
+        Clock:        clock,
+        Seed:         seed,
+        Rand:         rand.New(source),
+        source:       source,
+        RespawnDelay: DefaultRespawnDelay,
+        SpawnRules:   DefaultSpawnRules(),
+        Workers:      runtime.GOMAXPROCS(0),
+        Events:       events.NewBus(),
+        killers:      make(map[any]any),
     }
+    w.mapReport = analysis.Analyze(gameMap)
+    w.subscribe()
     w.initializeCollisionSpace()
-    w.spawnGoblinDens(10)
-    w.spawnMushrooms(30)
-    go w.mushroomSpawnRoutine()
+    w.Changes = pathfinding.NewChanges()
+    w.FlowFields = pathfinding.NewFlowFields(w.Space, w.Changes)
+    w.Hierarchy = pathfinding.NewHierarchy(w.Space, w.Changes, pathfinding.DefaultClusterSize)
     return w
 }
 
+// Update advances the simulation clock by one tick and runs the spawn rules. NPCs then
+// think in parallel against the unchanged world before every unit acts in turn, and
+// finally deaths and respawns are handled.
 func (w *World) Update() {
-    for _, obj := range w.Space.Objects() {
+    w.Clock.Advance()
+    w.runSpawnRules()
+    w.FlowFields.Sync()
+    w.Hierarchy.Sync()
+    objects := w.Space.Objects()
+    w.think(objects)
+    for _, obj := range objects {
         switch obj.Data.(type) {
         case *units.Character:
             character := obj.Data.(*units.Character)
-            character.Update()
+            character.Act()
         case *units.Monster:
             monster := obj.Data.(*units.Monster)
             monster.Update()
-            if monster.Health <= 0 {
-                w.Space.Remove(monster.Object)
-            }
         case *units.GoblinDen:
             den := obj.Data.(*units.GoblinDen)
-            newMonsters := den.Update()
-            for _, m := range newMonsters {
-                w.Space.Add(m.Object)
-            }
-            if den.Health <= 0 {
-                w.Space.Remove(den.Object)
-            }
+            den.Update()
         }
     }
+    w.reap(objects)
+    w.respawnCharacters()
 }
 
-func (w *World) spawnMushrooms(count int) {
-    for i := 0; i < count; i++ {
-        x, y := w.findValidSpawnPoint()
-        units.NewMushroom(w.Space, float64(x*gamemap.TileSize), float64(y*gamemap.TileSize))
+// think runs the think phase of the NPCs among objects on up to Workers goroutines.
+// Thinking only reads the world, so the outcome does not depend on the order.
+func (w *World) think(objects []*resolv.Object) {
+    var npcs []*units.Character
+    for _, obj := range objects {
+        if c, ok := obj.Data.(*units.Character); ok && !c.IsPlayer && c.Alive() {
+            npcs = append(npcs, c)
+        }
+    }
+    workers := min(w.Workers, len(npcs))
+    if workers <= 1 {
+        for _, c := range npcs {
+            c.Think()
+        }
+        return
+    }
+
+    queue := make(chan *units.Character)
+    var wg sync.WaitGroup
+    wg.Add(workers)
+    for i := 0; i < workers; i++ {
+        go func() {
+            defer wg.Done()
+            for c := range queue {
+                c.Think()
+            }
+        }()
     }
+    for _, c := range npcs {
+        queue <- c
+    }
+    close(queue)
+    wg.Wait()
+}
+
+// Stats returns the counters collected since the world was created
+func (w *World) Stats() Stats {
+    stats := w.stats
+    stats.Ticks = w.Clock.Tick()
+    return stats
 }
 
-func (w *World) mushroomSpawnRoutine() {
-    ticker := time.NewTicker(3 * time.Second)
-    for range ticker.C {
-        w.spawnMushrooms(1)
+func (w *World) spawnMushrooms(count int, rng *rand.Rand) {
+    for i := 0; i < count; i++ {
+        x, y, ok := w.findValidSpawnPoint(rng)
+        if !ok {
+            return
+        }
+        w.spawnKind("mushroom", x, y)
     }
 }
 
 func (w *World) spawnGoblinDens(count int) {
     for i := 0; i < count; i++ {
-        x, y := w.findValidSpawnPoint()
-        units.NewGoblinDen(w.Space, float64(x*gamemap.TileSize), float64(y*gamemap.TileSize))
+        x, y, ok := w.findValidSpawnPoint(w.Rand)
+        if !ok {
+            return
+        }
+        w.spawnKind("goblin_den", x, y)
     }
 }
 
-func (w *World) findValidSpawnPoint() (int, int) {
+// findValidSpawnPoint picks a random valid spawn tile away from the edges of the map. When
+// random tiles keep failing it picks among all the valid tiles, and reports false if there are none.
+func (w *World) findValidSpawnPoint(rng *rand.Rand) (int, int, bool) {
     offset := 5
-    for {
-        x := rand.Intn(w.GameMap.Width-2*offset) + offset
-        y := rand.Intn(w.GameMap.Height-2*offset) + offset
-        if w.IsSpawnPointValid(x, y) {
-            return x, y
+    if w.GameMap.Width > 2*offset && w.GameMap.Height > 2*offset {
+        for i := 0; i < spawnAttempts; i++ {
+            x := rng.Intn(w.GameMap.Width-2*offset) + offset
+            y := rng.Intn(w.GameMap.Height-2*offset) + offset
+            if w.IsSpawnPointValid(x, y) {
+                return x, y, true
+            }
         }
     }
+    var valid [][2]int
+    for y := 0; y < w.GameMap.Height; y++ {
+        for x := 0; x < w.GameMap.Width; x++ {
+            if w.IsSpawnPointValid(x, y) {
+                valid = append(valid, [2]int{x, y})
+            }
+        }
+    }
+    if len(valid) == 0 {
+        return 0, 0, false
+    }
+    tile := valid[rng.Intn(len(valid))]
+    return tile[0], tile[1], true
 }
 
 func (w *World) initializeCollisionSpace() {
     for y := 0; y < w.GameMap.Height; y++ {
         for x := 0; x < w.GameMap.Width; x++ {
-            if w.GameMap.Tiles[y][x] == gamemap.TileMountain {
+            tile := w.GameMap.Tiles[y][x]
+            switch {
+            case !tile.Terrain().Walkable:
                 obj := resolv.NewObject(float64(x*gamemap.TileSize), float64(y*gamemap.TileSize), float64(gamemap.TileSize), float64(gamemap.TileSize))
                 obj.SetShape(resolv.NewRectangle(0, 0, float64(gamemap.TileSize), float64(gamemap.TileSize)))
                 obj.AddTags("mountain")
                 w.Space.Add(obj)
+            case tile != gamemap.TileGrass:
+                // Grass is the default, only other terrain is marked in the space
+                w.Space.Add(pathfinding.NewTerrainObject(x, y, tile))
             }
         }
     }
@@ -104,6 +253,12 @@ func (w *World) IsSpawnPointValid(xTile, yTile int) bool {
     if xTile < 0 || xTile >= w.GameMap.Width || yTile < 0 || yTile >= w.GameMap.Height {
         return false
     }
+    if !w.GameMap.Tiles[yTile][xTile].Terrain().Spawnable {
+        return false
+    }
+    if !w.SpawnAnywhere && !w.mapReport.InMain(xTile, yTile) {
+        return false
+    }
     collision := w.Space.CheckCells(xTile, yTile, 1, 1, "mountain", "character", "monster")
     if len(collision) == 0 {
         return true
@@ -111,11 +266,32 @@ func (w *World) IsSpawnPointValid(xTile, yTile int) bool {
     return false
 }
 
+// AddCharacter spawns a character into the world, making it the Player when IsPlayer is set.
+// Characters without a spawn point respawn where they were added.
 func (w *World) AddCharacter(c *units.Character) {
-    if w.Player == nil {
+    if c.IsPlayer {
         w.Player = c
     }
-    w.Space.Add(c.Object)
+    c.Clock = w.Clock
+    c.Rand = w.Rand
+    c.FlowFields = w.FlowFields
+    c.Hierarchy = w.Hierarchy
+    c.Changes = w.Changes
+    c.Events = w.Events
+    c.Logger = w.Logger
+    if c.SpawnPoint.IsZero() {
+        c.SpawnPoint = c.Object.Position
+    }
+    w.spawn(c.Object)
+}
+
+// addMonster spawns a monster and lets it use the world's pathfinding
+func (w *World) addMonster(m *units.Monster) {
+    m.Hierarchy = w.Hierarchy
+    m.Changes = w.Changes
+    m.Events = w.Events
+    m.Logger = w.Logger
+    w.spawn(m.Object)
 }
 
 func (w *World) GetPlayerCharacter() *units.Character {
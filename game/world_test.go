@@ -0,0 +1,98 @@
+package game
+
+import (
+    gamemap "example.com/maj/map"
+    "example.com/maj/units"
+    "fmt"
+    "github.com/stretchr/testify/assert"
+    "testing"
+    "time"
+)
+
+func runSeededWorld(seed int64, ticks int) []string {
+    world := NewWorldFromMap(gamemap.MustLoadGameMapFile("../map/map1.txt"), seed)
+    for i := 1; i <= 5; i++ {
+        world.AddCharacter(units.NewCharacter(float64(4*gamemap.TileSize), float64(4*gamemap.TileSize), fmt.Sprintf("NPC%d", i)))
+    }
+    for i := 0; i < ticks; i++ {
+        world.Update()
+    }
+
+    var positions []string
+    for _, obj := range world.Space.Objects() {
+        switch obj.Data.(type) {
+        case *units.Character, *units.Monster, *units.GoblinDen:
+            positions = append(positions, fmt.Sprintf("%T %v", obj.Data, obj.Position))
+        }
+    }
+    return positions
+}
+
+func TestSeededWorldIsReproducible(t *testing.T) {
+    first := runSeededWorld(42, 600)
+    second := runSeededWorld(42, 600)
+    assert.NotEmpty(t, first)
+    assert.Equal(t, first, second, "Same seed should produce identical entity positions")
+
+    other := runSeededWorld(7, 600)
+    assert.NotEqual(t, first, other, "Different seeds should produce different worlds")
+}
+
+// TestWorkersDoNotChangeTheSimulation is meant to be run with -race as well, since NPCs
+// think on several goroutines
+func TestWorkersDoNotChangeTheSimulation(t *testing.T) {
+    run := func(workers int) *Snapshot {
+        world := NewWorldFromMap(gamemap.MustLoadGameMapFile("../map/map1.txt"), 42)
+        world.Workers = workers
+        for i := 1; i <= 8; i++ {
+            world.AddCharacter(units.NewCharacter(float64((3+i)*gamemap.TileSize), float64(4*gamemap.TileSize), fmt.Sprintf("NPC%d", i)))
+        }
+        for i := 0; i < 600; i++ {
+            world.Update()
+        }
+        return world.Snapshot()
+    }
+
+    sequential := run(1)
+    assert.Equal(t, sequential, run(4))
+    assert.Equal(t, sequential, run(16))
+}
+
+func TestWorldFromMapSpawnPoints(t *testing.T) {
+    w := NewWorldFromMap(gamemap.MustLoadGameMapFile("../map/meadow.json"), 1)
+
+    if !assert.NotNil(t, w.Player) {
+        return
+    }
+    assert.Equal(t, "Player", w.Player.Name)
+    assert.True(t, w.Player.IsPlayer)
+    var names []string
+    for _, c := range w.Characters() {
+        names = append(names, c.Name)
+    }
+    assert.Equal(t, []string{"Player", "NPC1", "NPC2", "NPC3"}, names)
+
+    dens := make(map[[2]int]*units.GoblinDen)
+    for _, obj := range w.Space.Objects() {
+        if den, ok := obj.Data.(*units.GoblinDen); ok {
+            x, y := w.Space.WorldToSpaceVec(obj.Position)
+            dens[[2]int{x, y}] = den
+        }
+    }
+    assert.Len(t, dens, 3)
+    if assert.Contains(t, dens, [2]int{33, 4}) && assert.Contains(t, dens, [2]int{8, 12}) {
+        assert.Equal(t, 3, dens[[2]int{33, 4}].MaxMonsters)
+        assert.Equal(t, 45*time.Second, dens[[2]int{8, 12}].SpawnCooldown)
+    }
+
+    field := w.GameMap.Spawns[7]
+    inField := 0
+    for _, m := range mushrooms(w) {
+        x, y := w.Space.WorldToSpaceVec(m.Object.Position)
+        if x >= field.X && x < field.X+field.Width && y >= field.Y && y < field.Y+field.Height {
+            inField++
+        }
+    }
+    assert.Equal(t, 6, inField)
+    assert.Len(t, mushrooms(w), 10)
+}
@@ -0,0 +1,297 @@
+// Package logging sets up the structured logs of the game. Records carry the tick, the
+// entity and the subsystem they are about. The level and the traced entities can be
+// changed while the game runs, and NPC decisions can be written to a JSON-lines file.
+package logging
+
+import (
+    "context"
+    "errors"
+    "io"
+    "log/slog"
+    "os"
+    "slices"
+    "strings"
+    "sync"
+)
+
+// Attribute keys shared by all records
+const (
+    TickKey      = "tick"
+    EntityKey    = "entity"
+    SubsystemKey = "subsystem"
+)
+
+// Subsystems the records come from
+const (
+    // Decision records hold what an NPC sensed, planned and did in a tick
+    Decision = "decision"
+    GOAP     = "goap"
+    Monster  = "monster"
+    World    = "world"
+)
+
+// Levels are the console levels CycleLevel goes through, from the most verbose
+var Levels = []slog.Level{slog.LevelDebug, slog.LevelInfo, slog.LevelWarn, slog.LevelError}
+
+// Log writes a record with the tick, the subsystem and the entity it is about, left out
+// when empty. A nil logger writes to the default one.
+func Log(logger *slog.Logger, level slog.Level, tick uint64, entity, subsystem, msg string, args ...any) {
+    if logger == nil {
+        logger = slog.Default()
+    }
+    ctx := context.Background()
+    if !logger.Enabled(ctx, level) {
+        return
+    }
+    attrs := []any{TickKey, tick, SubsystemKey, subsystem}
+    if entity != "" {
+        attrs = append(attrs, EntityKey, entity)
+    }
+    logger.Log(ctx, level, msg, append(attrs, args...)...)
+}
+
+// Logger is the game's logger together with the settings that can be changed while it runs
+type Logger struct {
+    *slog.Logger
+    // Level is the lowest level written to the console
+    Level *slog.LevelVar
+    // Filter chooses the entities whose records are written
+    Filter *Filter
+
+    decisions io.Closer
+}
+
+// New creates a logger writing text records at or above level to w. When decisions is
+// not nil, the decision records of the entities the filter allows are also written to it
+// as JSON lines, whatever the level.
+func New(w io.Writer, level slog.Level, decisions io.Writer) *Logger {
+    l := &Logger{Level: new(slog.LevelVar), Filter: &Filter{}}
+    l.Level.Set(level)
+    handler := slog.Handler(slog.NewTextHandler(w, &slog.HandlerOptions{Level: l.Level}))
+    if decisions != nil {
+        decisionHandler := &subsystemHandler{
+            Handler:   slog.NewJSONHandler(decisions, &slog.HandlerOptions{Level: slog.LevelDebug}),
+            subsystem: Decision,
+        }
+        handler = Tee(handler, decisionHandler)
+    }
+    l.Logger = slog.New(&filterHandler{Handler: handler, filter: l.Filter})
+    return l
+}
+
+// Open is like New, writing to stderr and the decision records to a file when a name is given
+func Open(level slog.Level, decisionFile string) (*Logger, error) {
+    if decisionFile == "" {
+        return New(os.Stderr, level, nil), nil
+    }
+    f, err := os.Create(decisionFile)
+    if err != nil {
+        return nil, err
+    }
+    l := New(os.Stderr, level, f)
+    l.decisions = f
+    return l, nil
+}
+
+// Close closes the decision file opened by Open
+func (l *Logger) Close() error {
+    if l.decisions == nil {
+        return nil
+    }
+    return l.decisions.Close()
+}
+
+// CycleLevel moves the console to the next of Levels, back to the first after the last,
+// and returns the new level
+func (l *Logger) CycleLevel() slog.Level {
+    next := Levels[0]
+    if i := slices.Index(Levels, l.Level.Level()); i >= 0 && i+1 < len(Levels) {
+        next = Levels[i+1]
+    }
+    l.Level.Set(next)
+    return next
+}
+
+// Filter keeps the records of traced entities. It traces every entity until Trace is
+// given names. It can be changed while other goroutines log.
+type Filter struct {
+    mu       sync.RWMutex
+    entities []string
+}
+
+// Trace restricts the logs to the named entities, or traces every entity again without names.
+// While tracing, records that are not about an entity are only kept from the warning level.
+func (f *Filter) Trace(entities ...string) {
+    f.mu.Lock()
+    defer f.mu.Unlock()
+    f.entities = slices.Clone(entities)
+}
+
+// Traced returns the entities the logs are restricted to, none when every entity is traced
+func (f *Filter) Traced() []string {
+    f.mu.RLock()
+    defer f.mu.RUnlock()
+    return slices.Clone(f.entities)
+}
+
+// TraceNext traces the entity after the one traced alone among names, the first one when
+// none is, and every entity again after the last one. It returns the traced entity, empty
+// when every entity is traced.
+func (f *Filter) TraceNext(names []string) string {
+    f.mu.Lock()
+    defer f.mu.Unlock()
+    next := 0
+    if len(f.entities) == 1 {
+        if i := slices.Index(names, f.entities[0]); i >= 0 {
+            next = i + 1
+        }
+    }
+    if next >= len(names) {
+        f.entities = nil
+        return ""
+    }
+    f.entities = []string{names[next]}
+    return names[next]
+}
+
+// SplitNames splits a comma separated list of entity names, such as a -trace flag
+func SplitNames(list string) []string {
+    var names []string
+    for _, name := range strings.Split(list, ",") {
+        if name = strings.TrimSpace(name); name != "" {
+            names = append(names, name)
+        }
+    }
+    return names
+}
+
+// allows reports whether a record of a level about an entity, empty for none, is kept
+func (f *Filter) allows(level slog.Level, entity string) bool {
+    f.mu.RLock()
+    defer f.mu.RUnlock()
+    if len(f.entities) == 0 {
+        return true
+    }
+    if entity == "" {
+        return level >= slog.LevelWarn
+    }
+    return slices.Contains(f.entities, entity)
+}
+
+// filterHandler drops the records of entities its filter does not allow
+type filterHandler struct {
+    slog.Handler
+    filter *Filter
+    // entity is the entity set with WithAttrs, if any
+    entity string
+}
+
+func (h *filterHandler) Handle(ctx context.Context, r slog.Record) error {
+    entity := h.entity
+    r.Attrs(func(a slog.Attr) bool {
+        if a.Key == EntityKey {
+            entity = a.Value.String()
+            return false
+        }
+        return true
+    })
+    if !h.filter.allows(r.Level, entity) {
+        return nil
+    }
+    return h.Handler.Handle(ctx, r)
+}
+
+func (h *filterHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+    entity := h.entity
+    for _, a := range attrs {
+        if a.Key == EntityKey {
+            entity = a.Value.String()
+        }
+    }
+    return &filterHandler{Handler: h.Handler.WithAttrs(attrs), filter: h.filter, entity: entity}
+}
+
+func (h *filterHandler) WithGroup(name string) slog.Handler {
+    return &filterHandler{Handler: h.Handler.WithGroup(name), filter: h.filter, entity: h.entity}
+}
+
+// subsystemHandler only passes on the records of one subsystem
+type subsystemHandler struct {
+    slog.Handler
+    subsystem string
+    // current is the subsystem set with WithAttrs, if any
+    current string
+}
+
+func (h *subsystemHandler) Handle(ctx context.Context, r slog.Record) error {
+    subsystem := h.current
+    r.Attrs(func(a slog.Attr) bool {
+        if a.Key == SubsystemKey {
+            subsystem = a.Value.String()
+            return false
+        }
+        return true
+    })
+    if subsystem != h.subsystem {
+        return nil
+    }
+    return h.Handler.Handle(ctx, r)
+}
+
+func (h *subsystemHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+    current := h.current
+    for _, a := range attrs {
+        if a.Key == SubsystemKey {
+            current = a.Value.String()
+        }
+    }
+    return &subsystemHandler{Handler: h.Handler.WithAttrs(attrs), subsystem: h.subsystem, current: current}
+}
+
+func (h *subsystemHandler) WithGroup(name string) slog.Handler {
+    return &subsystemHandler{Handler: h.Handler.WithGroup(name), subsystem: h.subsystem, current: h.current}
+}
+
+// Tee returns a handler passing every record to each of the handlers enabled for its level
+func Tee(handlers ...slog.Handler) slog.Handler {
+    return tee(handlers)
+}
+
+type tee []slog.Handler
+
+func (t tee) Enabled(ctx context.Context, level slog.Level) bool {
+    for _, h := range t {
+        if h.Enabled(ctx, level) {
+            return true
+        }
+    }
+    return false
+}
+
+func (t tee) Handle(ctx context.Context, r slog.Record) error {
+    var errs []error
+    for _, h := range t {
+        if h.Enabled(ctx, r.Level) {
+            if err := h.Handle(ctx, r.Clone()); err != nil {
+                errs = append(errs, err)
+            }
+        }
+    }
+    return errors.Join(errs...)
+}
+
+func (t tee) WithAttrs(attrs []slog.Attr) slog.Handler {
+    handlers := make(tee, len(t))
+    for i, h := range t {
+        handlers[i] = h.WithAttrs(attrs)
+    }
+    return handlers
+}
+
+func (t tee) WithGroup(name string) slog.Handler {
+    handlers := make(tee, len(t))
+    for i, h := range t {
+        handlers[i] = h.WithGroup(name)
+    }
+    return handlers
+}
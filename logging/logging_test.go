@@ -0,0 +1,84 @@
+package logging
+
+import (
+    "bytes"
+    "encoding/json"
+    "github.com/stretchr/testify/assert"
+    "log/slog"
+    "strings"
+    "testing"
+)
+
+func TestLogger(t *testing.T) {
+    t.Run("The level can be changed while logging", func(t *testing.T) {
+        var out bytes.Buffer
+        l := New(&out, slog.LevelInfo, nil)
+        l.Debug("hidden")
+        l.Level.Set(slog.LevelDebug)
+        l.Debug("shown")
+        assert.NotContains(t, out.String(), "hidden")
+        assert.Contains(t, out.String(), "shown")
+
+        l.Level.Set(slog.LevelError)
+        assert.Equal(t, slog.LevelDebug, l.CycleLevel())
+        assert.Equal(t, slog.LevelInfo, l.CycleLevel())
+    })
+
+    t.Run("Tracing keeps the records of the traced entities", func(t *testing.T) {
+        var out bytes.Buffer
+        l := New(&out, slog.LevelDebug, nil)
+        l.Filter.Trace("NPC1")
+        l.Info("about npc1", EntityKey, "NPC1")
+        l.Info("about npc2", EntityKey, "NPC2")
+        l.With(EntityKey, "NPC2").Info("about npc2 again")
+        l.Info("info without entity")
+        l.Warn("warning about nobody")
+        assert.Contains(t, out.String(), "about npc1")
+        assert.NotContains(t, out.String(), "about npc2")
+        assert.NotContains(t, out.String(), "info without entity")
+        assert.Contains(t, out.String(), "warning about nobody")
+
+        l.Filter.Trace()
+        l.Info("about npc2 untraced", EntityKey, "NPC2")
+        assert.Contains(t, out.String(), "about npc2 untraced")
+    })
+
+    t.Run("TraceNext cycles through the names and back to every entity", func(t *testing.T) {
+        f := &Filter{}
+        names := []string{"NPC1", "NPC2"}
+        assert.Equal(t, "NPC1", f.TraceNext(names))
+        assert.Equal(t, "NPC2", f.TraceNext(names))
+        assert.Equal(t, "", f.TraceNext(names))
+        assert.Empty(t, f.Traced())
+        assert.Equal(t, []string{"NPC1", "NPC3"}, SplitNames(" NPC1,,NPC3 "))
+    })
+
+    t.Run("Decisions are written as JSON lines whatever the level", func(t *testing.T) {
+        var out, decisions bytes.Buffer
+        l := New(&out, slog.LevelWarn, &decisions)
+        l.Debug("decision", TickKey, 7, SubsystemKey, Decision, EntityKey, "NPC1", "action", "Wander")
+        l.With(SubsystemKey, Decision).Debug("decision", TickKey, 8, EntityKey, "NPC1")
+        l.Warn("not a decision", SubsystemKey, World)
+
+        assert.Contains(t, out.String(), "not a decision")
+        assert.NotContains(t, out.String(), "Wander")
+        lines := strings.Split(strings.TrimSpace(decisions.String()), "\n")
+        assert.Len(t, lines, 2)
+        var record map[string]any
+        assert.NoError(t, json.Unmarshal([]byte(lines[0]), &record))
+        assert.Equal(t, float64(7), record[TickKey])
+        assert.Equal(t, "NPC1", record[EntityKey])
+        assert.Equal(t, Decision, record[SubsystemKey])
+        assert.Equal(t, "Wander", record["action"])
+    })
+
+    t.Run("Log tags records with the tick, the entity and the subsystem", func(t *testing.T) {
+        var out bytes.Buffer
+        l := New(&out, slog.LevelDebug, nil)
+        l.Filter.Trace("Goblin1")
+        Log(l.Logger, slog.LevelDebug, 3, "Goblin1", Monster, "chasing", "target", "NPC1")
+        Log(l.Logger, slog.LevelInfo, 4, "", World, "spawned")
+        assert.Equal(t, "level=DEBUG msg=chasing tick=3 subsystem=monster entity=Goblin1 target=NPC1",
+            strings.TrimSpace(strings.SplitN(out.String(), " ", 2)[1]))
+    })
+}
@@ -2,34 +2,44 @@ package main
 
 import (
     "example.com/maj/game"
+    "example.com/maj/logging"
     gamemap "example.com/maj/map"
+    "example.com/maj/ui"
     "example.com/maj/units"
+    "flag"
     "github.com/hajimehoshi/ebiten/v2/ebitenutil"
     "github.com/hajimehoshi/ebiten/v2/inpututil"
     "github.com/solarlune/resolv"
     "log"
+    "log/slog"
 
     "github.com/hajimehoshi/ebiten/v2"
 )
 
+const quickSaveFile = "quicksave.json"
+
 type Game struct {
     world        *game.World
-    camera       *game.Camera
-    renderer     *game.Renderer
-    inputHandler *game.InputHandler
+    camera       *ui.Camera
+    renderer     *ui.Renderer
+    inputHandler *ui.InputHandler
     space        *resolv.Space
+    logger       *logging.Logger
     isPaused     bool
 }
 
-func NewGame() *Game {
+func NewGame(gameMap *gamemap.GameMap, logger *logging.Logger) *Game {
 
-    world := game.NewWorld()
-    world.AddCharacter(units.NewCharacter(float64(3*gamemap.TileSize), float64(3*gamemap.TileSize), "Player"))
-    world.AddCharacter(units.NewCharacter(float64(4*gamemap.TileSize), float64(4*gamemap.TileSize), "NPC1"))
-    world.AddCharacter(units.NewCharacter(float64(4*gamemap.TileSize), float64(4*gamemap.TileSize), "NPC2"))
-    world.AddCharacter(units.NewCharacter(float64(4*gamemap.TileSize), float64(4*gamemap.TileSize), "NPC3"))
-    world.AddCharacter(units.NewCharacter(float64(4*gamemap.TileSize), float64(4*gamemap.TileSize), "NPC4"))
-    world.AddCharacter(units.NewCharacter(float64(4*gamemap.TileSize), float64(4*gamemap.TileSize), "NPC5"))
+    world := game.NewWorld(gameMap)
+    if len(world.Characters()) == 0 {
+        // Maps without spawn points get the player and NPCs in the top left corner
+        world.AddCharacter(units.NewCharacter(float64(3*gamemap.TileSize), float64(3*gamemap.TileSize), "Player"))
+        world.AddCharacter(units.NewCharacter(float64(4*gamemap.TileSize), float64(4*gamemap.TileSize), "NPC1"))
+        world.AddCharacter(units.NewCharacter(float64(4*gamemap.TileSize), float64(4*gamemap.TileSize), "NPC2"))
+        world.AddCharacter(units.NewCharacter(float64(4*gamemap.TileSize), float64(4*gamemap.TileSize), "NPC3"))
+        world.AddCharacter(units.NewCharacter(float64(4*gamemap.TileSize), float64(4*gamemap.TileSize), "NPC4"))
+        world.AddCharacter(units.NewCharacter(float64(4*gamemap.TileSize), float64(4*gamemap.TileSize), "NPC5"))
+    }
 
     chars, _, err := ebitenutil.NewImageFromFile("assets/rogues.png")
     if err != nil {
@@ -44,15 +54,19 @@ func NewGame() *Game {
         log.Fatal(err)
     }
 
+    renderer := ui.NewRenderer(ui.Sprites{
+        monsters,
+        chars,
+        tiles,
+    })
+    renderer.Watch(world)
+
     return &Game{
-        world:  world,
-        camera: game.NewCamera(),
-        renderer: game.NewRenderer(game.Sprites{
-            monsters,
-            chars,
-            tiles,
-        }),
-        inputHandler: game.NewInputHandler(),
+        world:        world,
+        camera:       ui.NewCamera(),
+        renderer:     renderer,
+        inputHandler: ui.NewInputHandler(),
+        logger:       logger,
     }
 }
 
@@ -75,6 +89,45 @@ func (g *Game) Update() error {
         ebiten.SetTPS(120)
     }
 
+    if inpututil.IsKeyJustPressed(ebiten.KeyF3) {
+        g.renderer.ShowGoalScores = !g.renderer.ShowGoalScores
+    }
+
+    if inpututil.IsKeyJustPressed(ebiten.KeyF4) {
+        log.Println("log level", g.logger.CycleLevel())
+    }
+
+    if inpututil.IsKeyJustPressed(ebiten.KeyF6) {
+        var names []string
+        for _, c := range g.world.Characters() {
+            names = append(names, c.Name)
+        }
+        if name := g.logger.Filter.TraceNext(names); name != "" {
+            log.Println("tracing", name)
+        } else {
+            log.Println("tracing every entity")
+        }
+    }
+
+    if inpututil.IsKeyJustPressed(ebiten.KeyF5) {
+        if err := g.world.SaveFile(quickSaveFile); err != nil {
+            log.Println("quick save failed:", err)
+        } else {
+            log.Println("saved world to", quickSaveFile)
+        }
+    }
+
+    if inpututil.IsKeyJustPressed(ebiten.KeyF9) {
+        world, err := game.LoadWorldFile(quickSaveFile)
+        if err != nil {
+            log.Println("quick load failed:", err)
+        } else {
+            g.world = world
+            g.renderer.Watch(world)
+            log.Println("loaded world from", quickSaveFile)
+        }
+    }
+
     return nil
 }
 
@@ -87,9 +140,38 @@ func (g *Game) Layout(outsideWidth, outsideHeight int) (screenWidth, screenHeigh
 }
 
 func main() {
+    mapFile := flag.String("map", "", "map file to load, JSON, Tiled or a CSV grid of tile types, defaults to the built-in map")
+    behaviorFile := flag.String("behavior", "", "JSON file with NPC actions and goals, defaults to the built-in behavior")
+    var level slog.Level
+    flag.TextVar(&level, "log-level", slog.LevelInfo, "lowest level of the console logs, F4 cycles it")
+    trace := flag.String("trace", "", "comma separated names of the entities to log, F6 cycles through the characters")
+    decisions := flag.String("decisions", "", "write the NPC decisions to this JSON-lines file")
+    flag.Parse()
+
+    logger, err := logging.Open(level, *decisions)
+    if err != nil {
+        log.Fatal(err)
+    }
+    defer logger.Close()
+    logger.Filter.Trace(logging.SplitNames(*trace)...)
+    slog.SetDefault(logger.Logger)
+
+    gameMap, err := gamemap.LoadGameMapFileOrDefault(*mapFile)
+    if err != nil {
+        log.Fatal(err)
+    }
+
+    if *behaviorFile != "" {
+        behavior, err := units.LoadNPCBehaviorFile(*behaviorFile)
+        if err != nil {
+            log.Fatal(err)
+        }
+        units.NPCBehavior = behavior
+    }
+
     ebiten.SetWindowSize(1280, 960)
     ebiten.SetWindowTitle("My 2D Top-Down Game")
-    if err := ebiten.RunGame(NewGame()); err != nil {
+    if err := ebiten.RunGame(NewGame(gameMap, logger)); err != nil {
         log.Fatal(err)
     }
 }
@@ -0,0 +1,222 @@
+// Package analysis checks the connectivity and validity of game maps: which walkable tiles
+// can reach each other, which tiles the main region hangs on and whether units can walk
+// off the grid.
+package analysis
+
+import (
+    gamemap "example.com/maj/map"
+    "fmt"
+    "image"
+    "sort"
+)
+
+// Report is the result of analysing a map
+type Report struct {
+    Width  int
+    Height int
+    // Components are the 4-connected regions of walkable tiles, largest first. The first
+    // one is the main region units are meant to live in.
+    Components [][]image.Point
+    // Regions holds the index in Components of every tile, by row, -1 for tiles that are not walkable
+    Regions [][]int
+    // Unreachable are the walkable tiles outside the main region
+    Unreachable []image.Point
+    // Chokepoints are the tiles of the main region that split it in two when blocked
+    Chokepoints []image.Point
+    // BorderGaps are walkable tiles on the edge of the map, where units can walk off the grid
+    BorderGaps []image.Point
+    // CutOffSpawns are the spawn points of the map with no tile in the main region
+    CutOffSpawns []gamemap.SpawnPoint
+}
+
+// Analyze reports the connectivity of a map
+func Analyze(m *gamemap.GameMap) *Report {
+    r := &Report{Width: m.Width, Height: m.Height}
+    r.label(m)
+    for _, component := range r.Components[min(1, len(r.Components)):] {
+        r.Unreachable = append(r.Unreachable, component...)
+    }
+    sortPoints(r.Unreachable)
+    r.Chokepoints = r.articulationPoints()
+    for y := 0; y < m.Height; y++ {
+        for x := 0; x < m.Width; x++ {
+            edge := x == 0 || y == 0 || x == m.Width-1 || y == m.Height-1
+            if edge && r.Regions[y][x] >= 0 {
+                r.BorderGaps = append(r.BorderGaps, image.Pt(x, y))
+            }
+        }
+    }
+    for _, spawn := range m.Spawns {
+        if !r.Reaches(spawn) {
+            r.CutOffSpawns = append(r.CutOffSpawns, spawn)
+        }
+    }
+    return r
+}
+
+// InMain reports whether a tile is in the main region
+func (r *Report) InMain(x, y int) bool {
+    return x >= 0 && y >= 0 && x < r.Width && y < r.Height && r.Regions[y][x] == 0
+}
+
+// Reaches reports whether a spawn point has a tile in the main region. Areas such as
+// mushroom fields may cover some rock.
+func (r *Report) Reaches(spawn gamemap.SpawnPoint) bool {
+    width, height := spawn.Size()
+    for y := spawn.Y; y < spawn.Y+height; y++ {
+        for x := spawn.X; x < spawn.X+width; x++ {
+            if r.InMain(x, y) {
+                return true
+            }
+        }
+    }
+    return false
+}
+
+// Problems describes what makes the map unfit to play on, nothing for a valid map
+func (r *Report) Problems() []string {
+    var problems []string
+    if len(r.Components) == 0 {
+        return []string{"no walkable tiles"}
+    }
+    if len(r.Components) > 1 {
+        problems = append(problems, fmt.Sprintf("%d walkable tiles are cut off from the main region, regions: %d", len(r.Unreachable), len(r.Components)))
+    }
+    for _, gap := range r.BorderGaps {
+        problems = append(problems, fmt.Sprintf("walkable border tile at %d,%d lets units walk off the map", gap.X, gap.Y))
+    }
+    for _, spawn := range r.CutOffSpawns {
+        problems = append(problems, fmt.Sprintf("%s at %d,%d is cut off from the main region", spawn.Kind, spawn.X, spawn.Y))
+    }
+    return problems
+}
+
+// label fills Regions and Components with a flood fill of the walkable tiles
+func (r *Report) label(m *gamemap.GameMap) {
+    r.Regions = make([][]int, m.Height)
+    for y := range r.Regions {
+        r.Regions[y] = make([]int, m.Width)
+        for x := range r.Regions[y] {
+            r.Regions[y][x] = -1
+        }
+    }
+    for y := 0; y < m.Height; y++ {
+        for x := 0; x < m.Width; x++ {
+            if r.Regions[y][x] >= 0 || !m.Tiles[y][x].Terrain().Walkable {
+                continue
+            }
+            index := len(r.Components)
+            r.Regions[y][x] = index
+            component := []image.Point{image.Pt(x, y)}
+            for i := 0; i < len(component); i++ {
+                for _, n := range neighbours(component[i]) {
+                    if r.inside(n) && r.Regions[n.Y][n.X] < 0 && m.Tiles[n.Y][n.X].Terrain().Walkable {
+                        r.Regions[n.Y][n.X] = index
+                        component = append(component, n)
+                    }
+                }
+            }
+            r.Components = append(r.Components, component)
+        }
+    }
+
+    // Order the components by size, keeping the map order between equal ones
+    order := make([]int, len(r.Components))
+    for i := range order {
+        order[i] = i
+    }
+    sort.SliceStable(order, func(a, b int) bool {
+        return len(r.Components[order[a]]) > len(r.Components[order[b]])
+    })
+    index := make([]int, len(order))
+    components := make([][]image.Point, len(order))
+    for i, old := range order {
+        index[old] = i
+        components[i] = r.Components[old]
+        sortPoints(components[i])
+    }
+    r.Components = components
+    for y := range r.Regions {
+        for x, region := range r.Regions[y] {
+            if region >= 0 {
+                r.Regions[y][x] = index[region]
+            }
+        }
+    }
+}
+
+// articulationPoints returns the tiles whose removal splits the main region, found with
+// an iterative depth-first search keeping the discovery and low-link time of every tile
+func (r *Report) articulationPoints() []image.Point {
+    if len(r.Components) == 0 {
+        return nil
+    }
+    type frame struct {
+        tile     image.Point
+        parent   image.Point
+        next     int
+        children int
+    }
+    discovered := make(map[image.Point]int)
+    low := make(map[image.Point]int)
+    points := make(map[image.Point]bool)
+    root := r.Components[0][0]
+    discovered[root], low[root] = 1, 1
+    stack := []frame{{tile: root, parent: image.Pt(-1, -1)}}
+    for len(stack) > 0 {
+        top := &stack[len(stack)-1]
+        if top.next < 4 {
+            n := neighbours(top.tile)[top.next]
+            top.next++
+            if !r.InMain(n.X, n.Y) || n == top.parent {
+                continue
+            }
+            if time, seen := discovered[n]; seen {
+                low[top.tile] = min(low[top.tile], time)
+                continue
+            }
+            top.children++
+            discovered[n] = len(discovered) + 1
+            low[n] = discovered[n]
+            stack = append(stack, frame{tile: n, parent: top.tile})
+            continue
+        }
+        done := *top
+        stack = stack[:len(stack)-1]
+        if len(stack) == 0 {
+            if done.children > 1 {
+                points[done.tile] = true
+            }
+            continue
+        }
+        parent := &stack[len(stack)-1]
+        low[parent.tile] = min(low[parent.tile], low[done.tile])
+        if len(stack) > 1 && low[done.tile] >= discovered[parent.tile] {
+            points[parent.tile] = true
+        }
+    }
+    result := make([]image.Point, 0, len(points))
+    for p := range points {
+        result = append(result, p)
+    }
+    sortPoints(result)
+    return result
+}
+
+func (r *Report) inside(p image.Point) bool {
+    return p.X >= 0 && p.Y >= 0 && p.X < r.Width && p.Y < r.Height
+}
+
+func neighbours(p image.Point) [4]image.Point {
+    return [4]image.Point{{p.X + 1, p.Y}, {p.X - 1, p.Y}, {p.X, p.Y + 1}, {p.X, p.Y - 1}}
+}
+
+// sortPoints orders tiles by row and then column
+func sortPoints(points []image.Point) {
+    sort.Slice(points, func(i, j int) bool {
+        if points[i].Y != points[j].Y {
+            return points[i].Y < points[j].Y
+        }
+        return points[i].X < points[j].X
+    })
+}
@@ -0,0 +1,85 @@
+package analysis
+
+import (
+    gamemap "example.com/maj/map"
+    "github.com/stretchr/testify/assert"
+    "image"
+    "strings"
+    "testing"
+)
+
+// parse reads a map drawn with # for mountains, ~ for water and . for grass
+func parse(rows ...string) *gamemap.GameMap {
+    m := &gamemap.GameMap{Width: len(rows[0]), Height: len(rows)}
+    for _, row := range rows {
+        tiles := make([]gamemap.TileType, len(row))
+        for x, c := range row {
+            switch c {
+            case '#':
+                tiles[x] = gamemap.TileMountain
+            case '~':
+                tiles[x] = gamemap.TileWater
+            }
+        }
+        m.Tiles = append(m.Tiles, tiles)
+    }
+    return m
+}
+
+func TestAnalyze(t *testing.T) {
+    t.Run("Regions, unreachable tiles and chokepoints", func(t *testing.T) {
+        m := parse(
+            "##########",
+            "#...#....#",
+            "#...~....#",
+            "#...#....#",
+            "######.###",
+            "#..#######",
+            "##########",
+        )
+        m.Spawns = []gamemap.SpawnPoint{
+            {Kind: gamemap.SpawnGoblinDen, X: 1, Y: 5},
+            {Kind: gamemap.SpawnNPC, X: 2, Y: 2},
+            // Fields only need some of their tiles in the main region
+            {Kind: gamemap.SpawnMushroomField, X: 0, Y: 0, Width: 2, Height: 2},
+        }
+        r := Analyze(m)
+
+        assert.Len(t, r.Components, 2)
+        assert.Len(t, r.Components[0], 3*3+1+3*4+1)
+        assert.Equal(t, []image.Point{{1, 5}, {2, 5}}, r.Unreachable)
+        assert.True(t, r.InMain(4, 2))
+        assert.False(t, r.InMain(1, 5))
+        assert.False(t, r.InMain(0, 0))
+        // The water tile joins the two rooms and the dead end hangs on the room it leaves
+        assert.Equal(t, []image.Point{{3, 2}, {4, 2}, {5, 2}, {6, 3}}, r.Chokepoints)
+        assert.Empty(t, r.BorderGaps)
+        assert.Equal(t, []gamemap.SpawnPoint{m.Spawns[0]}, r.CutOffSpawns)
+
+        problems := r.Problems()
+        assert.Len(t, problems, 2)
+        assert.Contains(t, problems[0], "2 walkable tiles are cut off from the main region, regions: 2")
+        assert.Contains(t, problems[1], "goblin_den at 1,5")
+    })
+
+    t.Run("Walkable border tiles are flagged", func(t *testing.T) {
+        r := Analyze(parse(
+            "####",
+            "#..~",
+            "####",
+        ))
+        assert.Equal(t, []image.Point{{3, 1}}, r.BorderGaps)
+        if assert.Len(t, r.Problems(), 1) {
+            assert.True(t, strings.HasPrefix(r.Problems()[0], "walkable border tile at 3,1"))
+        }
+    })
+
+    t.Run("Open fields have no chokepoints", func(t *testing.T) {
+        r := Analyze(gamemap.MustLoadGameMapFile("../map1.txt"))
+        assert.Len(t, r.Components, 1)
+        assert.Empty(t, r.Chokepoints)
+        assert.Empty(t, r.Problems())
+
+        assert.Equal(t, []string{"no walkable tiles"}, Analyze(parse("##", "##")).Problems())
+    })
+}
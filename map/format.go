@@ -0,0 +1,185 @@
+package gamemap
+
+import (
+    "bytes"
+    "encoding/json"
+    "fmt"
+    "slices"
+)
+
+// FormatVersion is the newest version of the JSON map format
+const FormatVersion = 1
+
+// TileNone marks the cells of a layer that leave the layers below visible
+const TileNone TileType = -1
+
+// Kinds of spawn points a map declares
+const (
+    SpawnPlayer        = "player"
+    SpawnNPC           = "npc"
+    SpawnGoblinDen     = "goblin_den"
+    SpawnMushroomField = "mushroom_field"
+)
+
+var spawnKinds = []string{SpawnPlayer, SpawnNPC, SpawnGoblinDen, SpawnMushroomField}
+
+// Layer is one of the tile layers a map is drawn in
+type Layer struct {
+    Name string `json:"name"`
+    // Tiles are the rows of the layer, TileNone where the layer is empty
+    Tiles [][]TileType `json:"tiles"`
+}
+
+// SpawnPoint is an entity the map places, in tiles. Areas such as mushroom fields cover
+// Width by Height tiles from X, Y, a single tile when they are zero.
+type SpawnPoint struct {
+    Kind   string `json:"kind"`
+    X      int    `json:"x"`
+    Y      int    `json:"y"`
+    Width  int    `json:"width,omitempty"`
+    Height int    `json:"height,omitempty"`
+    // Properties configure the spawned entity, such as the name of an NPC
+    Properties map[string]any `json:"properties,omitempty"`
+}
+
+// Size returns the area of the spawn point in tiles
+func (s SpawnPoint) Size() (int, int) {
+    return max(s.Width, 1), max(s.Height, 1)
+}
+
+// IntProperty returns a number property, fallback when it is missing or not a whole number
+func (s SpawnPoint) IntProperty(name string, fallback int) int {
+    value, ok := s.Properties[name].(float64)
+    if !ok || value != float64(int(value)) {
+        return fallback
+    }
+    return int(value)
+}
+
+// StringProperty returns a text property, fallback when it is missing or not text
+func (s SpawnPoint) StringProperty(name, fallback string) string {
+    value, ok := s.Properties[name].(string)
+    if !ok {
+        return fallback
+    }
+    return value
+}
+
+// mapFile is a map in the JSON format
+type mapFile struct {
+    Name     string       `json:"name"`
+    Version  int          `json:"version"`
+    TileSize int          `json:"tileSize"`
+    Width    int          `json:"width"`
+    Height   int          `json:"height"`
+    Layers   []Layer      `json:"layers"`
+    Objects  []SpawnPoint `json:"objects"`
+}
+
+// isJSONMap reports whether a map file is in the JSON format rather than the CSV grid
+func isJSONMap(content []byte) bool {
+    return bytes.HasPrefix(bytes.TrimSpace(content), []byte("{"))
+}
+
+// decodeJSONMap reads a map in the JSON format and merges its layers
+func decodeJSONMap(content []byte) (*GameMap, error) {
+    var file mapFile
+    if err := json.Unmarshal(content, &file); err != nil {
+        return nil, err
+    }
+    if file.Version < 1 || file.Version > FormatVersion {
+        return nil, fmt.Errorf("unsupported map version %d", file.Version)
+    }
+    if file.TileSize != 0 && file.TileSize != TileSize {
+        return nil, fmt.Errorf("tile size %d, only %d is supported", file.TileSize, TileSize)
+    }
+    if file.Width <= 0 || file.Height <= 0 {
+        return nil, fmt.Errorf("invalid map size %dx%d", file.Width, file.Height)
+    }
+    if len(file.Layers) == 0 {
+        return nil, fmt.Errorf("map has no tile layers")
+    }
+    gameMap := &GameMap{
+        Name:   file.Name,
+        Width:  file.Width,
+        Height: file.Height,
+        Layers: file.Layers,
+        Spawns: file.Objects,
+    }
+    if err := gameMap.mergeLayers(); err != nil {
+        return nil, err
+    }
+    for i, spawn := range gameMap.Spawns {
+        if err := gameMap.checkSpawn(spawn); err != nil {
+            return nil, fmt.Errorf("object %d: %w", i, err)
+        }
+    }
+    if err := gameMap.checkPlayers(); err != nil {
+        return nil, err
+    }
+    return gameMap, nil
+}
+
+// mergeLayers sets Tiles to the top non-empty tile of the layers in every cell
+func (m *GameMap) mergeLayers() error {
+    m.Tiles = make([][]TileType, m.Height)
+    for y := range m.Tiles {
+        m.Tiles[y] = make([]TileType, m.Width)
+        for x := range m.Tiles[y] {
+            m.Tiles[y][x] = TileNone
+        }
+    }
+    for _, layer := range m.Layers {
+        if len(layer.Tiles) != m.Height {
+            return fmt.Errorf("layer %q has %d rows, want %d", layer.Name, len(layer.Tiles), m.Height)
+        }
+        for y, row := range layer.Tiles {
+            if len(row) != m.Width {
+                return fmt.Errorf("layer %q row %d has %d tiles, want %d", layer.Name, y, len(row), m.Width)
+            }
+            for x, tile := range row {
+                switch {
+                case tile == TileNone:
+                case !tile.Valid():
+                    return fmt.Errorf("layer %q: unknown tile type %d at %d,%d", layer.Name, int(tile), x, y)
+                default:
+                    m.Tiles[y][x] = tile
+                }
+            }
+        }
+    }
+    for y, row := range m.Tiles {
+        for x, tile := range row {
+            if tile == TileNone {
+                return fmt.Errorf("no layer has a tile at %d,%d", x, y)
+            }
+        }
+    }
+    return nil
+}
+
+// checkPlayers reports maps with more than one player spawn point
+func (m *GameMap) checkPlayers() error {
+    players := 0
+    for _, spawn := range m.Spawns {
+        if spawn.Kind == SpawnPlayer {
+            players++
+        }
+    }
+    if players > 1 {
+        return fmt.Errorf("map has %d player spawn points, at most one is allowed", players)
+    }
+    return nil
+}
+
+// checkSpawn reports spawn points of unknown kinds or outside the map
+func (m *GameMap) checkSpawn(spawn SpawnPoint) error {
+    if !slices.Contains(spawnKinds, spawn.Kind) {
+        return fmt.Errorf("unknown kind %q", spawn.Kind)
+    }
+    width, height := spawn.Size()
+    if spawn.X < 0 || spawn.Y < 0 || spawn.X+width > m.Width || spawn.Y+height > m.Height {
+        return fmt.Errorf("%s at %d,%d is outside the map", spawn.Kind, spawn.X, spawn.Y)
+    }
+    return nil
+}
@@ -0,0 +1,178 @@
+package gamemap
+
+import (
+    "embed"
+    "errors"
+    "fmt"
+    "io"
+    "io/fs"
+    "os"
+    "path/filepath"
+    "strconv"
+    "strings"
+)
+
+// Maps holds the maps built into the game
+//
+//go:embed map1.txt meadow.json
+var Maps embed.FS
+
+// DefaultMap is the map of Maps the game starts on
+const DefaultMap = "map1.txt"
+
+// Loader loads maps in any of the supported formats. The zero Loader is ready to use.
+type Loader struct {
+    // Tilesets maps the tiles of Tiled maps to tile types, DefaultTilesetMapping when nil
+    Tilesets TilesetMapping
+}
+
+// Load reads a map in the JSON format, a Tiled TMX or TMJ map or a CSV grid of tile types,
+// telling them apart by their content
+func (l Loader) Load(r io.Reader) (*GameMap, error) {
+    content, err := io.ReadAll(r)
+    if err != nil {
+        return nil, err
+    }
+    tilesets := l.Tilesets
+    if tilesets == nil {
+        tilesets = DefaultTilesetMapping()
+    }
+    switch {
+    case isTMX(content):
+        return DecodeTMX(content, tilesets)
+    case isJSONMap(content) && isTMJ(content):
+        return DecodeTMJ(content, tilesets)
+    case isJSONMap(content):
+        return decodeJSONMap(content)
+    default:
+        return decodeCSVMap(content)
+    }
+}
+
+// LoadFile loads a map file like Load. Maps without a name are named after the file.
+func (l Loader) LoadFile(path string) (*GameMap, error) {
+    f, err := os.Open(path)
+    if err != nil {
+        return nil, err
+    }
+    defer f.Close()
+    return l.loadNamed(f, path)
+}
+
+// LoadFS loads a map file of a file system, such as Maps, like LoadFile
+func (l Loader) LoadFS(fsys fs.FS, name string) (*GameMap, error) {
+    f, err := fsys.Open(name)
+    if err != nil {
+        return nil, err
+    }
+    defer f.Close()
+    return l.loadNamed(f, name)
+}
+
+func (l Loader) loadNamed(r io.Reader, name string) (*GameMap, error) {
+    gameMap, err := l.Load(r)
+    if err != nil {
+        return nil, fmt.Errorf("%s: %w", name, err)
+    }
+    if gameMap.Name == "" {
+        base := filepath.Base(name)
+        gameMap.Name = strings.TrimSuffix(base, filepath.Ext(base))
+    }
+    return gameMap, nil
+}
+
+// LoadGameMap reads a map with the default Loader
+func LoadGameMap(r io.Reader) (*GameMap, error) {
+    return Loader{}.Load(r)
+}
+
+// LoadGameMapFile loads a map file with the default Loader
+func LoadGameMapFile(path string) (*GameMap, error) {
+    return Loader{}.LoadFile(path)
+}
+
+// LoadGameMapFS loads a map file of a file system with the default Loader
+func LoadGameMapFS(fsys fs.FS, name string) (*GameMap, error) {
+    return Loader{}.LoadFS(fsys, name)
+}
+
+// DefaultGameMap loads the DefaultMap built into the game
+func DefaultGameMap() (*GameMap, error) {
+    return LoadGameMapFS(Maps, DefaultMap)
+}
+
+// LoadGameMapFileOrDefault loads a map file like LoadGameMapFile, or the DefaultGameMap
+// when the path is empty, such as a -map flag that was not given
+func LoadGameMapFileOrDefault(path string) (*GameMap, error) {
+    if path == "" {
+        return DefaultGameMap()
+    }
+    return LoadGameMapFile(path)
+}
+
+// MustLoadGameMapFile is like LoadGameMapFile but panics when the map cannot be loaded.
+// It is meant for tests and tools with fixed maps.
+func MustLoadGameMapFile(path string) *GameMap {
+    gameMap, err := LoadGameMapFile(path)
+    if err != nil {
+        panic(err)
+    }
+    return gameMap
+}
+
+// decodeCSVMap reads a grid of comma separated tile types, one row per line. Blank lines
+// are only allowed after the last row.
+func decodeCSVMap(content []byte) (*GameMap, error) {
+    var tiles [][]TileType
+    blank := 0
+    for i, line := range strings.Split(string(content), "\n") {
+        number := i + 1
+        line = strings.TrimSuffix(line, "\r")
+        if strings.TrimSpace(line) == "" {
+            if blank == 0 {
+                blank = number
+            }
+            continue
+        }
+        if blank != 0 {
+            return nil, fmt.Errorf("line %d: blank line inside the map", blank)
+        }
+        row, err := decodeCSVRow(line, number)
+        if err != nil {
+            return nil, err
+        }
+        if len(tiles) > 0 && len(row) != len(tiles[0]) {
+            return nil, fmt.Errorf("line %d: row has %d tiles, the first row has %d", number, len(row), len(tiles[0]))
+        }
+        tiles = append(tiles, row)
+    }
+    if len(tiles) == 0 {
+        return nil, errors.New("map has no rows")
+    }
+    return &GameMap{
+        Tiles:  tiles,
+        Width:  len(tiles[0]),
+        Height: len(tiles),
+        Layers: []Layer{{Name: "ground", Tiles: tiles}},
+    }, nil
+}
+
+// decodeCSVRow reads the tile types of a line, reporting bad tokens by their line and column
+func decodeCSVRow(line string, number int) ([]TileType, error) {
+    var row []TileType
+    column := 1
+    for _, token := range strings.Split(line, ",") {
+        value := strings.TrimSpace(token)
+        at := column + len(token) - len(strings.TrimLeft(token, " \t"))
+        id, err := strconv.Atoi(value)
+        if err != nil {
+            return nil, fmt.Errorf("line %d, column %d: invalid tile %q", number, at, value)
+        }
+        if !TileType(id).Valid() {
+            return nil, fmt.Errorf("line %d, column %d: unknown tile type %d", number, at, id)
+        }
+        row = append(row, TileType(id))
+        column += len(token) + 1
+    }
+    return row, nil
+}
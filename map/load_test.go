@@ -0,0 +1,66 @@
+package gamemap
+
+import (
+    "github.com/stretchr/testify/assert"
+    "strings"
+    "testing"
+    "testing/fstest"
+)
+
+func TestLoadGameMap(t *testing.T) {
+    t.Run("CSV maps may end with blank lines and use CRLF", func(t *testing.T) {
+        m, err := LoadGameMap(strings.NewReader("1,1,1\r\n1,0,1\r\n1,1,1\r\n\r\n"))
+        if assert.NoError(t, err) {
+            assert.Equal(t, 3, m.Width)
+            assert.Equal(t, 3, m.Height)
+            assert.Equal(t, TileGrass, m.Tiles[1][1])
+        }
+    })
+
+    t.Run("Bad CSV maps are reported with their position", func(t *testing.T) {
+        for content, message := range map[string]string{
+            "0,0\n0, x\n":  `line 2, column 4: invalid tile "x"`,
+            "0,0\n0,9\n":   "line 2, column 3: unknown tile type 9",
+            "0,0\n0\n":     "line 2: row has 1 tiles, the first row has 2",
+            "0,0\n\n0,0\n": "line 2: blank line inside the map",
+            "\n\n":         "map has no rows",
+        } {
+            _, err := LoadGameMap(strings.NewReader(content))
+            assert.EqualError(t, err, message, content)
+        }
+    })
+
+    t.Run("Maps load from files and file systems", func(t *testing.T) {
+        _, err := LoadGameMapFile("missing.txt")
+        assert.Error(t, err)
+
+        fsys := fstest.MapFS{
+            "maps/tiny.txt": {Data: []byte("0,4\n4,0\n")},
+            "maps/bad.txt":  {Data: []byte("0,4\n4,-2\n")},
+        }
+        m, err := LoadGameMapFS(fsys, "maps/tiny.txt")
+        if assert.NoError(t, err) {
+            assert.Equal(t, "tiny", m.Name)
+            assert.Equal(t, TileRoad, m.Tiles[0][1])
+        }
+        _, err = LoadGameMapFS(fsys, "maps/bad.txt")
+        assert.EqualError(t, err, "maps/bad.txt: line 2, column 3: unknown tile type -2")
+
+        m, err = DefaultGameMap()
+        if assert.NoError(t, err) {
+            assert.Equal(t, "map1", m.Name)
+            assert.Equal(t, 70, m.Width)
+        }
+        m, err = LoadGameMapFS(Maps, "meadow.json")
+        if assert.NoError(t, err) {
+            assert.Equal(t, "Meadow", m.Name)
+        }
+
+        m, err = LoadGameMapFileOrDefault("")
+        if assert.NoError(t, err) {
+            assert.Equal(t, "map1", m.Name)
+        }
+        _, err = LoadGameMapFileOrDefault("missing.txt")
+        assert.Error(t, err)
+    })
+}
@@ -1,10 +1,8 @@
 package gamemap
 
 import (
-    "log"
-    "os"
-    "strconv"
-    "strings"
+    "fmt"
+    "math"
 )
 
 const (
@@ -16,51 +14,81 @@ type TileType int
 const (
     TileGrass TileType = iota
     TileMountain
+    TileWater
+    TileSwamp
+    TileRoad
+    TileForest
 )
 
-type GameMap struct {
-    Tiles  [][]TileType
-    Width  int
-    Height int
+// Terrain describes how units move over a tile type
+type Terrain struct {
+    Name string
+    // Walkable is false for tiles nothing can enter
+    Walkable bool
+    // Cost multiplies the pathfinding cost of steps over the tile
+    Cost float64
+    // Speed multiplies the movement speed of units standing on the tile
+    Speed float64
+    // Spawnable is false for tiles nothing is spawned on
+    Spawnable bool
 }
 
-func NewGameMap() *GameMap {
-    tiles, width, height := loadMapFromFile("map/map1.txt")
-    return &GameMap{
-        Tiles:  tiles,
-        Width:  width,
-        Height: height,
+var terrains = map[TileType]Terrain{
+    TileGrass:    {Name: "grass", Walkable: true, Cost: 1, Speed: 1, Spawnable: true},
+    TileMountain: {Name: "mountain"},
+    TileWater:    {Name: "water", Walkable: true, Cost: 5, Speed: 0.35},
+    TileSwamp:    {Name: "swamp", Walkable: true, Cost: 3, Speed: 0.5, Spawnable: true},
+    TileRoad:     {Name: "road", Walkable: true, Cost: 0.5, Speed: 1.5, Spawnable: true},
+    TileForest:   {Name: "forest", Walkable: true, Cost: 1.5, Speed: 0.75, Spawnable: true},
+}
+
+// minTerrainCost is the lowest Cost of any walkable tile, worked out once from terrains
+var minTerrainCost = func() float64 {
+    lowest := math.Inf(1)
+    for _, terrain := range terrains {
+        if terrain.Walkable {
+            lowest = min(lowest, terrain.Cost)
+        }
     }
+    return lowest
+}()
+
+// MinTerrainCost returns the lowest Cost of any walkable tile. Path heuristics are scaled
+// by it so that they never overestimate a route along the cheapest tiles.
+func MinTerrainCost() float64 {
+    return minTerrainCost
 }
 
-func loadMapFromFile(filename string) ([][]TileType, int, int) {
-    content, err := os.ReadFile(filename)
-    if err != nil {
-        log.Fatal(err)
+// Terrain returns the movement properties of the tile type. Unknown types are treated as grass.
+func (t TileType) Terrain() Terrain {
+    if terrain, ok := terrains[t]; ok {
+        return terrain
     }
+    return terrains[TileGrass]
+}
 
-    lines := strings.Split(string(content), "\n")
-    height := len(lines)
-    width := 0
-    tiles := make([][]TileType, height)
+// Valid reports whether the tile type is known
+func (t TileType) Valid() bool {
+    _, ok := terrains[t]
+    return ok
+}
 
-    for y, line := range lines {
-        if line == "" {
-            continue
-        }
-        values := strings.Split(line, ",")
-        if width == 0 {
-            width = len(values)
-        }
-        tiles[y] = make([]TileType, width)
-        for x, value := range values {
-            tileType, err := strconv.Atoi(value)
-            if err != nil {
-                log.Fatal(err)
-            }
-            tiles[y][x] = TileType(tileType)
-        }
+func (t TileType) String() string {
+    if terrain, ok := terrains[t]; ok {
+        return terrain.Name
     }
+    return fmt.Sprintf("TileType(%d)", int(t))
+}
 
-    return tiles, width, height
+type GameMap struct {
+    // Name is the title of the map, the file name for CSV maps
+    Name string
+    // Tiles are the rows of the map with its layers merged
+    Tiles  [][]TileType
+    Width  int
+    Height int
+    // Layers are the tile layers the map was drawn in, from the bottom
+    Layers []Layer
+    // Spawns are the players, NPCs, dens and mushroom fields the map places
+    Spawns []SpawnPoint
 }
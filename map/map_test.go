@@ -0,0 +1,87 @@
+package gamemap
+
+import (
+    "bytes"
+    "github.com/stretchr/testify/assert"
+    "testing"
+)
+
+func TestMapFormats(t *testing.T) {
+    t.Run("JSON maps merge their layers and declare spawn points", func(t *testing.T) {
+        m := MustLoadGameMapFile("meadow.json")
+        assert.Equal(t, "Meadow", m.Name)
+        assert.Equal(t, 40, m.Width)
+        assert.Equal(t, 30, m.Height)
+        if !assert.Len(t, m.Layers, 2) {
+            return
+        }
+        assert.Equal(t, TileMountain, m.Tiles[0][0])
+        assert.Equal(t, TileRoad, m.Tiles[15][20])
+        assert.Equal(t, TileForest, m.Tiles[20][6])
+        assert.Equal(t, TileNone, m.Layers[1].Tiles[20][6])
+
+        if !assert.NotEmpty(t, m.Spawns) {
+            return
+        }
+        assert.Equal(t, SpawnPlayer, m.Spawns[0].Kind)
+        den := m.Spawns[4]
+        assert.Equal(t, SpawnGoblinDen, den.Kind)
+        assert.Equal(t, 3, den.IntProperty("maxMonsters", 5))
+        assert.Equal(t, 5, den.IntProperty("missing", 5))
+        assert.Equal(t, "NPC1", m.Spawns[1].StringProperty("name", ""))
+    })
+
+    t.Run("Written maps load back the same", func(t *testing.T) {
+        m := MustLoadGameMapFile("meadow.json")
+        var text, json bytes.Buffer
+        assert.NoError(t, WriteJSON(&json, m))
+        assert.NoError(t, WriteCSV(&text, m))
+
+        fromJSON, err := LoadGameMap(&json)
+        if assert.NoError(t, err) {
+            assert.Equal(t, m, fromJSON)
+        }
+        fromText, err := LoadGameMap(&text)
+        if assert.NoError(t, err) {
+            assert.Equal(t, m.Tiles, fromText.Tiles)
+            assert.Empty(t, fromText.Spawns)
+        }
+    })
+
+    t.Run("CSV maps are detected and keep loading", func(t *testing.T) {
+        m := MustLoadGameMapFile("map1.txt")
+        assert.Equal(t, "map1", m.Name)
+        assert.Equal(t, 70, m.Width)
+        assert.Equal(t, 70, m.Height)
+        assert.Len(t, m.Layers, 1)
+        assert.Empty(t, m.Spawns)
+    })
+
+    t.Run("Invalid JSON maps are reported", func(t *testing.T) {
+        for name, content := range map[string]string{
+            "newer version": `{"version": 2, "width": 1, "height": 1, "layers": [{"tiles": [[0]]}]}`,
+            "tile size":     `{"version": 1, "tileSize": 16, "width": 1, "height": 1, "layers": [{"tiles": [[0]]}]}`,
+            "no layers":     `{"version": 1, "width": 1, "height": 1}`,
+            "ragged row":    `{"version": 1, "width": 2, "height": 1, "layers": [{"tiles": [[0]]}]}`,
+            "unknown tile":  `{"version": 1, "width": 1, "height": 1, "layers": [{"tiles": [[42]]}]}`,
+            "empty cell":    `{"version": 1, "width": 2, "height": 1, "layers": [{"tiles": [[0, -1]]}]}`,
+            "unknown kind":  `{"version": 1, "width": 1, "height": 1, "layers": [{"tiles": [[0]]}], "objects": [{"kind": "dragon"}]}`,
+            "outside":       `{"version": 1, "width": 1, "height": 1, "layers": [{"tiles": [[0]]}], "objects": [{"kind": "npc", "x": 1}]}`,
+            "two players":   `{"version": 1, "width": 1, "height": 1, "layers": [{"tiles": [[0]]}], "objects": [{"kind": "player"}, {"kind": "player"}]}`,
+        } {
+            assert.True(t, isJSONMap([]byte(content)), name)
+            _, err := decodeJSONMap([]byte(content))
+            assert.Error(t, err, name)
+        }
+    })
+}
+
+func TestMinTerrainCost(t *testing.T) {
+    assert.Equal(t, TileRoad.Terrain().Cost, MinTerrainCost())
+    for tile, terrain := range terrains {
+        if terrain.Walkable {
+            assert.GreaterOrEqual(t, terrain.Cost, MinTerrainCost(), terrain.Name)
+        }
+        assert.Equal(t, terrain, tile.Terrain())
+    }
+}
@@ -0,0 +1,559 @@
+// Package mapgen generates playable game maps from a seed. Maps are carved as caves, rooms
+// joined by corridors or noise-based terrain, every walkable region is joined to the others
+// and goblin dens and mushroom fields are placed as spawn points.
+package mapgen
+
+import (
+    gamemap "example.com/maj/map"
+    "example.com/maj/map/analysis"
+    "example.com/maj/sim"
+    "fmt"
+    "math"
+    "math/rand"
+)
+
+// Layout is the way the walkable part of a map is shaped
+type Layout string
+
+const (
+    // Caves grows cave systems with a cellular automaton
+    Caves Layout = "caves"
+    // Rooms places rectangular rooms and joins them with road corridors
+    Rooms Layout = "rooms"
+    // Terrain turns noise into water, swamp, grass, forest and mountains
+    Terrain Layout = "terrain"
+)
+
+// Layouts are the layouts Generate supports
+var Layouts = []Layout{Caves, Rooms, Terrain}
+
+// startRadius is how far the clearing around the start reaches
+const startRadius = 2
+
+// placeAttempts is how many random tiles are tried for each den or mushroom field
+const placeAttempts = 200
+
+// Config controls a generated map
+type Config struct {
+    Layout Layout
+    Width  int
+    Height int
+    Seed   int64
+    // Name is the name of the map, derived from the layout and seed when empty
+    Name string
+
+    // FillRatio is the share of rock the caves start from and CaveSteps how often they are smoothed
+    FillRatio float64
+    CaveSteps int
+    // RoomCount rooms between MinRoomSize and MaxRoomSize tiles wide are tried
+    RoomCount   int
+    MinRoomSize int
+    MaxRoomSize int
+    // NoiseScale is the size in tiles of the largest terrain features
+    NoiseScale int
+
+    // StartX, StartY is the tile the player starts on. It is cleared and joined to the rest
+    // of the map, and the NPCs start next to it.
+    StartX int
+    StartY int
+    NPCs   int
+    // DenDensity is the number of goblin dens per 1000 spawnable tiles. Dens stay
+    // DenDistance tiles away from the start and each other.
+    DenDensity  float64
+    DenDistance int
+    // MushroomDensity is the number of mushroom fields per 1000 spawnable tiles. Fields are
+    // FieldSize tiles wide and grow FieldMushrooms mushrooms.
+    MushroomDensity float64
+    FieldSize       int
+    FieldMushrooms  int
+}
+
+// DefaultConfig returns the settings of a 70 by 70 map of a layout
+func DefaultConfig(layout Layout, seed int64) Config {
+    return Config{
+        Layout:          layout,
+        Width:           70,
+        Height:          70,
+        Seed:            seed,
+        FillRatio:       0.45,
+        CaveSteps:       5,
+        RoomCount:       14,
+        MinRoomSize:     5,
+        MaxRoomSize:     12,
+        NoiseScale:      16,
+        StartX:          3,
+        StartY:          3,
+        NPCs:            5,
+        DenDensity:      3,
+        DenDistance:     10,
+        MushroomDensity: 2,
+        FieldSize:       5,
+        FieldMushrooms:  3,
+    }
+}
+
+// generator holds the map being generated
+type generator struct {
+    Config
+    rng   *rand.Rand
+    tiles [][]gamemap.TileType
+}
+
+// Generate creates a map from the config. The same config always creates the same map.
+func Generate(config Config) (*gamemap.GameMap, error) {
+    if config.Width < 2*startRadius+4 || config.Height < 2*startRadius+4 {
+        return nil, fmt.Errorf("map size %dx%d is too small", config.Width, config.Height)
+    }
+    if config.StartX-startRadius < 1 || config.StartY-startRadius < 1 ||
+        config.StartX+startRadius >= config.Width-1 || config.StartY+startRadius >= config.Height-1 {
+        return nil, fmt.Errorf("start %d,%d is too close to the border", config.StartX, config.StartY)
+    }
+    if config.Layout == Rooms && (config.MinRoomSize < 1 || config.MaxRoomSize < config.MinRoomSize) {
+        return nil, fmt.Errorf("invalid room sizes %d to %d", config.MinRoomSize, config.MaxRoomSize)
+    }
+    g := &generator{Config: config, rng: sim.NewRand(config.Seed)}
+    g.tiles = make([][]gamemap.TileType, config.Height)
+    for y := range g.tiles {
+        g.tiles[y] = make([]gamemap.TileType, config.Width)
+    }
+
+    switch config.Layout {
+    case Caves:
+        g.caves()
+    case Rooms:
+        g.rooms()
+    case Terrain:
+        g.terrain()
+    default:
+        return nil, fmt.Errorf("unknown layout %q", config.Layout)
+    }
+    g.clearStart()
+    g.border()
+    g.connect()
+
+    name := config.Name
+    if name == "" {
+        name = fmt.Sprintf("%s-%d", config.Layout, config.Seed)
+    }
+    gameMap := &gamemap.GameMap{
+        Name:   name,
+        Tiles:  g.tiles,
+        Width:  config.Width,
+        Height: config.Height,
+        Layers: []gamemap.Layer{{Name: "ground", Tiles: g.tiles}},
+    }
+    spawns, err := g.spawns()
+    if err != nil {
+        return nil, err
+    }
+    gameMap.Spawns = spawns
+    return gameMap, nil
+}
+
+// caves fills the map with random rock and smooths it into caves: a tile becomes rock when
+// most of its neighbours are rock
+func (g *generator) caves() {
+    for y := range g.tiles {
+        for x := range g.tiles[y] {
+            g.tiles[y][x] = gamemap.TileGrass
+            if g.rng.Float64() < g.FillRatio {
+                g.tiles[y][x] = gamemap.TileMountain
+            }
+        }
+    }
+    for step := 0; step < g.CaveSteps; step++ {
+        next := make([][]gamemap.TileType, g.Height)
+        for y := range next {
+            next[y] = make([]gamemap.TileType, g.Width)
+            for x := range next[y] {
+                switch rock := g.rockAround(x, y); {
+                case rock >= 5:
+                    next[y][x] = gamemap.TileMountain
+                case rock <= 3:
+                    next[y][x] = gamemap.TileGrass
+                default:
+                    next[y][x] = g.tiles[y][x]
+                }
+            }
+        }
+        g.tiles = next
+    }
+    // Damp hollows turn into swamps
+    noise := g.noise(g.NoiseScale)
+    for y := range g.tiles {
+        for x := range g.tiles[y] {
+            if g.tiles[y][x] == gamemap.TileGrass && noise[y][x] < 0.3 {
+                g.tiles[y][x] = gamemap.TileSwamp
+            }
+        }
+    }
+}
+
+// rockAround counts the rock among the eight neighbours of a tile, outside the map included
+func (g *generator) rockAround(x, y int) int {
+    rock := 0
+    for dy := -1; dy <= 1; dy++ {
+        for dx := -1; dx <= 1; dx++ {
+            if dx == 0 && dy == 0 {
+                continue
+            }
+            if !g.inside(x+dx, y+dy) || g.tiles[y+dy][x+dx] == gamemap.TileMountain {
+                rock++
+            }
+        }
+    }
+    return rock
+}
+
+// room is a rectangle of floor tiles
+type room struct {
+    x, y, width, height int
+}
+
+func (r room) center() (int, int) {
+    return r.x + r.width/2, r.y + r.height/2
+}
+
+// overlaps reports whether two rooms are closer than a tile of wall
+func (r room) overlaps(o room) bool {
+    return r.x-1 < o.x+o.width && o.x-1 < r.x+r.width && r.y-1 < o.y+o.height && o.y-1 < r.y+r.height
+}
+
+// rooms carves rooms out of rock and joins each one to the previous with a road
+func (g *generator) rooms() {
+    g.fill(gamemap.TileMountain)
+    var rooms []room
+    for i := 0; i < g.RoomCount*placeAttempts/10 && len(rooms) < g.RoomCount; i++ {
+        width := g.MinRoomSize + g.rng.Intn(g.MaxRoomSize-g.MinRoomSize+1)
+        height := g.MinRoomSize + g.rng.Intn(g.MaxRoomSize-g.MinRoomSize+1)
+        if width > g.Width-2 || height > g.Height-2 {
+            continue
+        }
+        r := room{x: 1 + g.rng.Intn(g.Width-width-1), y: 1 + g.rng.Intn(g.Height-height-1), width: width, height: height}
+        free := true
+        for _, other := range rooms {
+            free = free && !r.overlaps(other)
+        }
+        if !free {
+            continue
+        }
+        for y := r.y; y < r.y+r.height; y++ {
+            for x := r.x; x < r.x+r.width; x++ {
+                g.tiles[y][x] = gamemap.TileGrass
+                if g.rng.Float64() < 0.08 {
+                    g.tiles[y][x] = gamemap.TileForest
+                }
+            }
+        }
+        if len(rooms) > 0 {
+            x0, y0 := rooms[len(rooms)-1].center()
+            x1, y1 := r.center()
+            g.corridor(x0, y0, x1, y1, gamemap.TileRoad)
+        }
+        rooms = append(rooms, r)
+    }
+}
+
+// corridor carves an L shaped path between two tiles, turning rock into the given tile
+func (g *generator) corridor(x0, y0, x1, y1 int, tile gamemap.TileType) {
+    horizontalFirst := g.rng.Intn(2) == 0
+    carve := func(x, y int) {
+        if !g.walkable(x, y) {
+            g.tiles[y][x] = tile
+        }
+    }
+    x, y := x0, y0
+    for x != x1 || y != y1 {
+        carve(x, y)
+        if (horizontalFirst && x != x1) || y == y1 {
+            x += sign(x1 - x)
+        } else {
+            y += sign(y1 - y)
+        }
+    }
+    carve(x, y)
+}
+
+// terrain turns a height noise into terrain: water in the lows, mountains on the highs
+func (g *generator) terrain() {
+    height := g.noise(g.NoiseScale)
+    for y := range g.tiles {
+        for x := range g.tiles[y] {
+            switch h := height[y][x]; {
+            case h < 0.3:
+                g.tiles[y][x] = gamemap.TileWater
+            case h < 0.37:
+                g.tiles[y][x] = gamemap.TileSwamp
+            case h < 0.6:
+                g.tiles[y][x] = gamemap.TileGrass
+            case h < 0.7:
+                g.tiles[y][x] = gamemap.TileForest
+            default:
+                g.tiles[y][x] = gamemap.TileMountain
+            }
+        }
+    }
+}
+
+// noise returns smooth value noise in [0, 1] for every tile, with features up to scale
+// tiles wide
+func (g *generator) noise(scale int) [][]float64 {
+    result := make([][]float64, g.Height)
+    for y := range result {
+        result[y] = make([]float64, g.Width)
+    }
+    amplitude, total := 1.0, 0.0
+    for cell := max(scale, 2); cell >= 2; cell /= 2 {
+        lattice := make([][]float64, g.Height/cell+2)
+        for i := range lattice {
+            lattice[i] = make([]float64, g.Width/cell+2)
+            for j := range lattice[i] {
+                lattice[i][j] = g.rng.Float64()
+            }
+        }
+        for y := range result {
+            for x := range result[y] {
+                cx, cy := x/cell, y/cell
+                fx, fy := smooth(float64(x%cell)/float64(cell)), smooth(float64(y%cell)/float64(cell))
+                top := lerp(lattice[cy][cx], lattice[cy][cx+1], fx)
+                bottom := lerp(lattice[cy+1][cx], lattice[cy+1][cx+1], fx)
+                result[y][x] += amplitude * lerp(top, bottom, fy)
+            }
+        }
+        total += amplitude
+        amplitude /= 2
+    }
+    for y := range result {
+        for x := range result[y] {
+            result[y][x] /= total
+        }
+    }
+    return result
+}
+
+// clearStart turns the tiles around the start into grass
+func (g *generator) clearStart() {
+    for y := g.StartY - startRadius; y <= g.StartY+startRadius; y++ {
+        for x := g.StartX - startRadius; x <= g.StartX+startRadius; x++ {
+            g.tiles[y][x] = gamemap.TileGrass
+        }
+    }
+}
+
+// border surrounds the map with mountains so nothing can walk off it
+func (g *generator) border() {
+    for x := 0; x < g.Width; x++ {
+        g.tiles[0][x] = gamemap.TileMountain
+        g.tiles[g.Height-1][x] = gamemap.TileMountain
+    }
+    for y := 0; y < g.Height; y++ {
+        g.tiles[y][0] = gamemap.TileMountain
+        g.tiles[y][g.Width-1] = gamemap.TileMountain
+    }
+}
+
+// connect joins every walkable region to the region of the start by carving the shortest
+// tunnel through rock between them
+func (g *generator) connect() {
+    for {
+        report := analysis.Analyze(&gamemap.GameMap{Tiles: g.tiles, Width: g.Width, Height: g.Height})
+        if len(report.Components) <= 1 {
+            return
+        }
+        start := report.Regions[g.StartY][g.StartX]
+        other := 0
+        if start == 0 {
+            other = 1
+        }
+        g.tunnel(report.Regions, other, start)
+    }
+}
+
+// tunnel searches from every tile of a region through the inside of the map to the
+// nearest tile of the target region and turns the rock on the way into grass
+func (g *generator) tunnel(regions [][]int, from, to int) {
+    previous := make(map[[2]int][2]int)
+    var queue [][2]int
+    for y := range regions {
+        for x := range regions[y] {
+            if regions[y][x] == from {
+                queue = append(queue, [2]int{x, y})
+                previous[[2]int{x, y}] = [2]int{x, y}
+            }
+        }
+    }
+    for len(queue) > 0 {
+        tile := queue[0]
+        queue = queue[1:]
+        if regions[tile[1]][tile[0]] == to {
+            for tile != previous[tile] {
+                if !g.walkable(tile[0], tile[1]) {
+                    g.tiles[tile[1]][tile[0]] = gamemap.TileGrass
+                }
+                tile = previous[tile]
+            }
+            return
+        }
+        for _, n := range neighbours(tile[0], tile[1]) {
+            if _, seen := previous[n]; seen || n[0] < 1 || n[1] < 1 || n[0] >= g.Width-1 || n[1] >= g.Height-1 {
+                continue
+            }
+            previous[n] = tile
+            queue = append(queue, n)
+        }
+    }
+}
+
+// spawns places the player and NPCs at the start and goblin dens and mushroom fields on
+// spawnable tiles, which connect guarantees are all reachable. NPCs that do not fit the
+// clearing below the start take the nearest free spawnable tiles.
+func (g *generator) spawns() ([]gamemap.SpawnPoint, error) {
+    spawns := []gamemap.SpawnPoint{{Kind: gamemap.SpawnPlayer, X: g.StartX, Y: g.StartY}}
+    taken := map[[2]int]bool{{g.StartX, g.StartY}: true}
+    row := 2*startRadius + 1
+    for i := 0; i < min(g.NPCs, row*startRadius); i++ {
+        x, y := g.StartX-startRadius+i%row, g.StartY+1+i/row
+        taken[[2]int{x, y}] = true
+        spawns = append(spawns, gamemap.SpawnPoint{Kind: gamemap.SpawnNPC, X: x, Y: y})
+    }
+    if extra := g.NPCs - row*startRadius; extra > 0 {
+        tiles := g.nearStart(extra, taken)
+        if len(tiles) < extra {
+            return nil, fmt.Errorf("only %d of %d NPCs fit the start region", g.NPCs-extra+len(tiles), g.NPCs)
+        }
+        for _, tile := range tiles {
+            spawns = append(spawns, gamemap.SpawnPoint{Kind: gamemap.SpawnNPC, X: tile[0], Y: tile[1]})
+        }
+    }
+
+    spawnable := 0
+    for y := range g.tiles {
+        for x := range g.tiles[y] {
+            if g.tiles[y][x].Terrain().Spawnable {
+                spawnable++
+            }
+        }
+    }
+    var dens []gamemap.SpawnPoint
+    for i := 0; i < densityCount(g.DenDensity, spawnable); i++ {
+        for attempt := 0; attempt < placeAttempts; attempt++ {
+            x, y := 1+g.rng.Intn(g.Width-2), 1+g.rng.Intn(g.Height-2)
+            if !g.tiles[y][x].Terrain().Spawnable || distance(x, y, g.StartX, g.StartY) < g.DenDistance {
+                continue
+            }
+            apart := true
+            for _, den := range dens {
+                apart = apart && distance(x, y, den.X, den.Y) >= g.DenDistance
+            }
+            if apart {
+                dens = append(dens, gamemap.SpawnPoint{Kind: gamemap.SpawnGoblinDen, X: x, Y: y})
+                break
+            }
+        }
+    }
+    spawns = append(spawns, dens...)
+
+    size := max(g.FieldSize, 1)
+    for i := 0; i < densityCount(g.MushroomDensity, spawnable); i++ {
+        for attempt := 0; attempt < placeAttempts; attempt++ {
+            x, y := 1+g.rng.Intn(max(g.Width-1-size, 1)), 1+g.rng.Intn(max(g.Height-1-size, 1))
+            // Fields go where at least half the tiles can grow mushrooms
+            fertile := 0
+            for fy := y; fy < y+size; fy++ {
+                for fx := x; fx < x+size; fx++ {
+                    if g.inside(fx, fy) && g.tiles[fy][fx].Terrain().Spawnable {
+                        fertile++
+                    }
+                }
+            }
+            if 2*fertile >= size*size {
+                spawns = append(spawns, gamemap.SpawnPoint{
+                    Kind: gamemap.SpawnMushroomField, X: x, Y: y, Width: size, Height: size,
+                    Properties: map[string]any{"count": float64(g.FieldMushrooms)},
+                })
+                break
+            }
+        }
+    }
+    return spawns, nil
+}
+
+// nearStart returns up to n spawnable tiles that are not taken, the nearest to the start
+// by walking distance first
+func (g *generator) nearStart(n int, taken map[[2]int]bool) [][2]int {
+    start := [2]int{g.StartX, g.StartY}
+    seen := map[[2]int]bool{start: true}
+    queue := [][2]int{start}
+    var tiles [][2]int
+    for len(queue) > 0 && len(tiles) < n {
+        tile := queue[0]
+        queue = queue[1:]
+        if !taken[tile] && g.tiles[tile[1]][tile[0]].Terrain().Spawnable {
+            tiles = append(tiles, tile)
+        }
+        for _, next := range neighbours(tile[0], tile[1]) {
+            if seen[next] || !g.inside(next[0], next[1]) || !g.walkable(next[0], next[1]) {
+                continue
+            }
+            seen[next] = true
+            queue = append(queue, next)
+        }
+    }
+    return tiles
+}
+
+func (g *generator) fill(tile gamemap.TileType) {
+    for y := range g.tiles {
+        for x := range g.tiles[y] {
+            g.tiles[y][x] = tile
+        }
+    }
+}
+
+func (g *generator) inside(x, y int) bool {
+    return x >= 0 && y >= 0 && x < g.Width && y < g.Height
+}
+
+func (g *generator) walkable(x, y int) bool {
+    return g.tiles[y][x].Terrain().Walkable
+}
+
+// densityCount returns the number of entities a density per 1000 tiles asks for
+func densityCount(density float64, tiles int) int {
+    return int(math.Round(density * float64(tiles) / 1000))
+}
+
+func neighbours(x, y int) [4][2]int {
+    return [4][2]int{{x + 1, y}, {x - 1, y}, {x, y + 1}, {x, y - 1}}
+}
+
+// distance is the Chebyshev distance between two tiles
+func distance(x0, y0, x1, y1 int) int {
+    return max(abs(x1-x0), abs(y1-y0))
+}
+
+func abs(v int) int {
+    if v < 0 {
+        return -v
+    }
+    return v
+}
+
+func sign(v int) int {
+    switch {
+    case v > 0:
+        return 1
+    case v < 0:
+        return -1
+    }
+    return 0
+}
+
+// smooth eases the interpolation between noise lattice points
+func smooth(t float64) float64 {
+    return t * t * (3 - 2*t)
+}
+
+func lerp(a, b, t float64) float64 {
+    return a + (b-a)*t
+}
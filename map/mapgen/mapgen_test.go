@@ -0,0 +1,134 @@
+package mapgen
+
+import (
+    "bytes"
+    gamemap "example.com/maj/map"
+    "example.com/maj/map/analysis"
+    "github.com/stretchr/testify/assert"
+    "testing"
+)
+
+func TestGenerate(t *testing.T) {
+    for _, layout := range Layouts {
+        t.Run(string(layout), func(t *testing.T) {
+            for seed := int64(1); seed <= 5; seed++ {
+                config := DefaultConfig(layout, seed)
+                m, err := Generate(config)
+                if !assert.NoError(t, err) {
+                    return
+                }
+                again, _ := Generate(config)
+                assert.Equal(t, m.Tiles, again.Tiles, "seed %d", seed)
+                assert.Equal(t, m.Spawns, again.Spawns, "seed %d", seed)
+
+                for x := 0; x < m.Width; x++ {
+                    assert.Equal(t, gamemap.TileMountain, m.Tiles[0][x])
+                    assert.Equal(t, gamemap.TileMountain, m.Tiles[m.Height-1][x])
+                }
+                for y := 0; y < m.Height; y++ {
+                    assert.Equal(t, gamemap.TileMountain, m.Tiles[y][0])
+                    assert.Equal(t, gamemap.TileMountain, m.Tiles[y][m.Width-1])
+                }
+                report := analysis.Analyze(m)
+                assert.Empty(t, report.Problems(), "seed %d", seed)
+                assert.True(t, report.InMain(config.StartX, config.StartY))
+
+                kinds := make(map[string]int)
+                for _, spawn := range m.Spawns {
+                    kinds[spawn.Kind]++
+                    switch spawn.Kind {
+                    case gamemap.SpawnMushroomField:
+                        fertile := 0
+                        for y := spawn.Y; y < spawn.Y+spawn.Height; y++ {
+                            for x := spawn.X; x < spawn.X+spawn.Width; x++ {
+                                if m.Tiles[y][x].Terrain().Spawnable {
+                                    fertile++
+                                }
+                            }
+                        }
+                        assert.GreaterOrEqual(t, 2*fertile, spawn.Width*spawn.Height, "field at %d,%d", spawn.X, spawn.Y)
+                    case gamemap.SpawnGoblinDen:
+                        assert.True(t, m.Tiles[spawn.Y][spawn.X].Terrain().Spawnable, "den at %d,%d", spawn.X, spawn.Y)
+                    default:
+                        assert.True(t, m.Tiles[spawn.Y][spawn.X].Terrain().Walkable, "%s at %d,%d", spawn.Kind, spawn.X, spawn.Y)
+                    }
+                }
+                assert.Equal(t, 1, kinds[gamemap.SpawnPlayer])
+                assert.Equal(t, config.NPCs, kinds[gamemap.SpawnNPC])
+                assert.Positive(t, kinds[gamemap.SpawnGoblinDen], "seed %d", seed)
+                assert.Positive(t, kinds[gamemap.SpawnMushroomField], "seed %d", seed)
+            }
+        })
+    }
+
+    t.Run("Densities scale the dens and mushroom fields", func(t *testing.T) {
+        config := DefaultConfig(Terrain, 3)
+        config.DenDensity, config.MushroomDensity, config.DenDistance = 0, 6, 1
+        m, err := Generate(config)
+        if !assert.NoError(t, err) {
+            return
+        }
+        kinds := make(map[string]int)
+        for _, spawn := range m.Spawns {
+            kinds[spawn.Kind]++
+        }
+        assert.Zero(t, kinds[gamemap.SpawnGoblinDen])
+        assert.Greater(t, kinds[gamemap.SpawnMushroomField], 10)
+    })
+
+    t.Run("Generated maps load back from both formats", func(t *testing.T) {
+        m, err := Generate(DefaultConfig(Rooms, 9))
+        if !assert.NoError(t, err) {
+            return
+        }
+        var text, json bytes.Buffer
+        assert.NoError(t, gamemap.WriteCSV(&text, m))
+        assert.NoError(t, gamemap.WriteJSON(&json, m))
+
+        fromText, err := gamemap.LoadGameMap(&text)
+        if assert.NoError(t, err) {
+            assert.Equal(t, m.Tiles, fromText.Tiles)
+        }
+        fromJSON, err := gamemap.LoadGameMap(&json)
+        if assert.NoError(t, err) {
+            assert.Equal(t, m.Name, fromJSON.Name)
+            assert.Equal(t, m.Tiles, fromJSON.Tiles)
+            assert.Equal(t, m.Spawns, fromJSON.Spawns)
+        }
+    })
+
+    t.Run("NPCs beyond the clearing start on the nearest free tiles", func(t *testing.T) {
+        config := DefaultConfig(Caves, 1)
+        config.NPCs = 40
+        m, err := Generate(config)
+        if !assert.NoError(t, err) {
+            return
+        }
+        report := analysis.Analyze(m)
+        tiles := make(map[[2]int]bool)
+        for _, spawn := range m.Spawns {
+            if spawn.Kind == gamemap.SpawnNPC || spawn.Kind == gamemap.SpawnPlayer {
+                tiles[[2]int{spawn.X, spawn.Y}] = true
+                assert.True(t, m.Tiles[spawn.Y][spawn.X].Terrain().Spawnable, "%s at %d,%d", spawn.Kind, spawn.X, spawn.Y)
+                assert.True(t, report.InMain(spawn.X, spawn.Y), "%s at %d,%d", spawn.Kind, spawn.X, spawn.Y)
+            }
+        }
+        assert.Len(t, tiles, config.NPCs+1)
+
+        config.Width, config.Height, config.StartX, config.StartY = 9, 9, 4, 4
+        config.NPCs = 100
+        _, err = Generate(config)
+        assert.ErrorContains(t, err, "of 100 NPCs fit the start region")
+    })
+
+    t.Run("Invalid configs are reported", func(t *testing.T) {
+        config := DefaultConfig("maze", 1)
+        _, err := Generate(config)
+        assert.ErrorContains(t, err, "unknown layout")
+
+        config = DefaultConfig(Caves, 1)
+        config.Width = 4
+        _, err = Generate(config)
+        assert.Error(t, err)
+    })
+}
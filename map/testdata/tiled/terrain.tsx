@@ -0,0 +1,4 @@
+<?xml version="1.0" encoding="UTF-8"?>
+<tileset version="1.10" tiledversion="1.10.2" name="terrain" tilewidth="32" tileheight="32" tilecount="6" columns="6">
+ <image source="../../../assets/tiles.png" width="192" height="32"/>
+</tileset>
@@ -0,0 +1,515 @@
+package gamemap
+
+import (
+    "bytes"
+    "compress/gzip"
+    "compress/zlib"
+    "encoding/base64"
+    "encoding/binary"
+    "encoding/json"
+    "encoding/xml"
+    "fmt"
+    "io"
+    "math"
+    "path"
+    "strconv"
+    "strings"
+)
+
+// TilesetMapping maps the tiles of Tiled tilesets to tile types. It is keyed by the tileset
+// name, the file name without extension for external tilesets, and then by the tile id
+// within the tileset.
+type TilesetMapping map[string]map[int]TileType
+
+// DefaultTilesetMapping returns a new mapping for a "terrain" tileset with one tile per
+// tile type, in the order of the TileType values
+func DefaultTilesetMapping() TilesetMapping {
+    return TilesetMapping{
+        "terrain": {
+            0: TileGrass,
+            1: TileMountain,
+            2: TileWater,
+            3: TileSwamp,
+            4: TileRoad,
+            5: TileForest,
+        },
+    }
+}
+
+// tiledFlipFlags are the bits of a Tiled GID that flip or rotate the tile
+const tiledFlipFlags = 0xF0000000
+
+// tiledMap is a Tiled map read from either of its file formats
+type tiledMap struct {
+    orientation string
+    width       int
+    height      int
+    tileWidth   int
+    tileHeight  int
+    infinite    bool
+    properties  map[string]any
+    tilesets    []tiledTileset
+    layers      []tiledLayer
+    objects     []tiledObject
+}
+
+type tiledTileset struct {
+    firstGID int
+    name     string
+}
+
+type tiledLayer struct {
+    name string
+    gids []uint32
+}
+
+// tiledObject is an object of an object layer, placed in pixels
+type tiledObject struct {
+    name       string
+    class      string
+    gid        uint32
+    x, y       float64
+    width      float64
+    height     float64
+    properties map[string]any
+}
+
+// isTMX reports whether a map file is a Tiled XML map
+func isTMX(content []byte) bool {
+    return bytes.HasPrefix(bytes.TrimSpace(content), []byte("<"))
+}
+
+// isTMJ reports whether a JSON map file was saved by Tiled rather than in our own format
+func isTMJ(content []byte) bool {
+    var header struct {
+        Type string `json:"type"`
+    }
+    return json.Unmarshal(content, &header) == nil && header.Type == "map"
+}
+
+// DecodeTMX reads a Tiled map in the TMX format, turning its tiles into tile types with
+// the mapping and the objects of its object layers into spawn points
+func DecodeTMX(content []byte, mapping TilesetMapping) (*GameMap, error) {
+    var file tmxMap
+    if err := xml.Unmarshal(content, &file); err != nil {
+        return nil, err
+    }
+    tiled := &tiledMap{
+        orientation: file.Orientation,
+        width:       file.Width,
+        height:      file.Height,
+        tileWidth:   file.TileWidth,
+        tileHeight:  file.TileHeight,
+        infinite:    file.Infinite != 0,
+        properties:  tmxProperties(file.Properties),
+    }
+    for _, tileset := range file.Tilesets {
+        tiled.tilesets = append(tiled.tilesets, tiledTileset{firstGID: tileset.FirstGID, name: tilesetName(tileset.Name, tileset.Source)})
+    }
+    for _, layer := range file.Layers {
+        gids, err := tmxLayerGIDs(layer)
+        if err != nil {
+            return nil, fmt.Errorf("layer %q: %w", layer.Name, err)
+        }
+        tiled.layers = append(tiled.layers, tiledLayer{name: layer.Name, gids: gids})
+    }
+    for _, group := range file.ObjectGroups {
+        for _, object := range group.Objects {
+            class := object.Class
+            if class == "" {
+                class = object.Type
+            }
+            tiled.objects = append(tiled.objects, tiledObject{
+                name:       object.Name,
+                class:      class,
+                gid:        object.GID,
+                x:          object.X,
+                y:          object.Y,
+                width:      object.Width,
+                height:     object.Height,
+                properties: tmxProperties(object.Properties),
+            })
+        }
+    }
+    return tiled.gameMap(mapping)
+}
+
+// DecodeTMJ reads a Tiled map in the JSON format like DecodeTMX
+func DecodeTMJ(content []byte, mapping TilesetMapping) (*GameMap, error) {
+    var file tmjMap
+    if err := json.Unmarshal(content, &file); err != nil {
+        return nil, err
+    }
+    tiled := &tiledMap{
+        orientation: file.Orientation,
+        width:       file.Width,
+        height:      file.Height,
+        tileWidth:   file.TileWidth,
+        tileHeight:  file.TileHeight,
+        infinite:    file.Infinite,
+        properties:  tmjProperties(file.Properties),
+    }
+    for _, tileset := range file.Tilesets {
+        tiled.tilesets = append(tiled.tilesets, tiledTileset{firstGID: tileset.FirstGID, name: tilesetName(tileset.Name, tileset.Source)})
+    }
+    for _, layer := range file.Layers {
+        switch layer.Type {
+        case "tilelayer":
+            gids, err := tmjLayerGIDs(layer)
+            if err != nil {
+                return nil, fmt.Errorf("layer %q: %w", layer.Name, err)
+            }
+            tiled.layers = append(tiled.layers, tiledLayer{name: layer.Name, gids: gids})
+        case "objectgroup":
+            for _, object := range layer.Objects {
+                class := object.Class
+                if class == "" {
+                    class = object.Type
+                }
+                tiled.objects = append(tiled.objects, tiledObject{
+                    name:       object.Name,
+                    class:      class,
+                    gid:        object.GID,
+                    x:          object.X,
+                    y:          object.Y,
+                    width:      object.Width,
+                    height:     object.Height,
+                    properties: tmjProperties(object.Properties),
+                })
+            }
+        }
+    }
+    return tiled.gameMap(mapping)
+}
+
+// gameMap converts the Tiled map into a game map
+func (t *tiledMap) gameMap(mapping TilesetMapping) (*GameMap, error) {
+    if t.orientation != "orthogonal" {
+        return nil, fmt.Errorf("%s maps are not supported, only orthogonal ones", t.orientation)
+    }
+    if t.infinite {
+        return nil, fmt.Errorf("infinite maps are not supported")
+    }
+    if t.width <= 0 || t.height <= 0 || t.tileWidth <= 0 || t.tileHeight <= 0 {
+        return nil, fmt.Errorf("invalid map size %dx%d with %dx%d tiles", t.width, t.height, t.tileWidth, t.tileHeight)
+    }
+    if len(t.layers) == 0 {
+        return nil, fmt.Errorf("map has no tile layers")
+    }
+    gameMap := &GameMap{Width: t.width, Height: t.height}
+    if name, ok := t.properties["name"].(string); ok {
+        gameMap.Name = name
+    }
+    for _, layer := range t.layers {
+        if len(layer.gids) != t.width*t.height {
+            return nil, fmt.Errorf("layer %q has %d tiles, want %d", layer.name, len(layer.gids), t.width*t.height)
+        }
+        tiles := make([][]TileType, t.height)
+        for y := range tiles {
+            tiles[y] = make([]TileType, t.width)
+            for x := range tiles[y] {
+                tile, err := t.tileType(layer.gids[y*t.width+x], mapping)
+                if err != nil {
+                    return nil, fmt.Errorf("layer %q at %d,%d: %w", layer.name, x, y, err)
+                }
+                tiles[y][x] = tile
+            }
+        }
+        gameMap.Layers = append(gameMap.Layers, Layer{Name: layer.name, Tiles: tiles})
+    }
+    if err := gameMap.mergeLayers(); err != nil {
+        return nil, err
+    }
+    for i, object := range t.objects {
+        spawn := t.spawnPoint(object)
+        if err := gameMap.checkSpawn(spawn); err != nil {
+            return nil, fmt.Errorf("object %d %q: %w", i, object.name, err)
+        }
+        gameMap.Spawns = append(gameMap.Spawns, spawn)
+    }
+    if err := gameMap.checkPlayers(); err != nil {
+        return nil, err
+    }
+    return gameMap, nil
+}
+
+// tileType returns the tile type of a GID, TileNone for the empty GID 0
+func (t *tiledMap) tileType(gid uint32, mapping TilesetMapping) (TileType, error) {
+    gid &^= tiledFlipFlags
+    if gid == 0 {
+        return TileNone, nil
+    }
+    // Tilesets are sorted by their first GID, the tile belongs to the last one starting before it
+    var tileset *tiledTileset
+    for i := range t.tilesets {
+        if t.tilesets[i].firstGID <= int(gid) {
+            tileset = &t.tilesets[i]
+        }
+    }
+    if tileset == nil {
+        return 0, fmt.Errorf("GID %d is in no tileset", gid)
+    }
+    tiles, ok := mapping[tileset.name]
+    if !ok {
+        return 0, fmt.Errorf("tileset %q has no mapping", tileset.name)
+    }
+    id := int(gid) - tileset.firstGID
+    tile, ok := tiles[id]
+    if !ok {
+        return 0, fmt.Errorf("tile %d of tileset %q has no mapping", id, tileset.name)
+    }
+    return tile, nil
+}
+
+// spawnPoint converts an object placed in pixels into a spawn point in tiles. The class of
+// the object is the kind of spawn point and its name the "name" property unless one is set.
+func (t *tiledMap) spawnPoint(object tiledObject) SpawnPoint {
+    y := object.y
+    if object.gid != 0 {
+        // Tile objects are placed by their bottom left corner
+        y -= object.height
+    }
+    x0, y0 := int(math.Floor(object.x/float64(t.tileWidth))), int(math.Floor(y/float64(t.tileHeight)))
+    spawn := SpawnPoint{Kind: object.class, X: x0, Y: y0, Properties: object.properties}
+    if object.width > 0 && object.height > 0 {
+        spawn.Width = int(math.Ceil((object.x+object.width)/float64(t.tileWidth))) - x0
+        spawn.Height = int(math.Ceil((y+object.height)/float64(t.tileHeight))) - y0
+        if spawn.Width == 1 && spawn.Height == 1 {
+            spawn.Width, spawn.Height = 0, 0
+        }
+    }
+    if object.name != "" {
+        if _, ok := spawn.Properties["name"]; !ok {
+            if spawn.Properties == nil {
+                spawn.Properties = make(map[string]any)
+            }
+            spawn.Properties["name"] = object.name
+        }
+    }
+    return spawn
+}
+
+// tilesetName returns the name of an embedded tileset or the file name of an external one
+func tilesetName(name, source string) string {
+    if source == "" {
+        return name
+    }
+    base := path.Base(strings.ReplaceAll(source, "\\", "/"))
+    return strings.TrimSuffix(base, path.Ext(base))
+}
+
+// decodeBase64GIDs reads the little-endian GIDs of a base64 layer, compressed with zlib,
+// gzip or not at all
+func decodeBase64GIDs(data, compression string) ([]uint32, error) {
+    raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(data))
+    if err != nil {
+        return nil, err
+    }
+    var reader io.Reader = bytes.NewReader(raw)
+    switch compression {
+    case "":
+    case "zlib":
+        if reader, err = zlib.NewReader(reader); err != nil {
+            return nil, err
+        }
+    case "gzip":
+        if reader, err = gzip.NewReader(reader); err != nil {
+            return nil, err
+        }
+    default:
+        return nil, fmt.Errorf("unsupported compression %q", compression)
+    }
+    raw, err = io.ReadAll(reader)
+    if err != nil {
+        return nil, err
+    }
+    if len(raw)%4 != 0 {
+        return nil, fmt.Errorf("layer data is %d bytes, not a whole number of GIDs", len(raw))
+    }
+    gids := make([]uint32, len(raw)/4)
+    for i := range gids {
+        gids[i] = binary.LittleEndian.Uint32(raw[4*i:])
+    }
+    return gids, nil
+}
+
+// tiledPropertyValue converts the text value of a TMX property of a type. Numbers become
+// float64 like in JSON.
+func tiledPropertyValue(kind, value string) any {
+    switch kind {
+    case "int", "float", "object":
+        if number, err := strconv.ParseFloat(value, 64); err == nil {
+            return number
+        }
+    case "bool":
+        return value == "true"
+    }
+    return value
+}
+
+type tmxMap struct {
+    Orientation  string           `xml:"orientation,attr"`
+    Width        int              `xml:"width,attr"`
+    Height       int              `xml:"height,attr"`
+    TileWidth    int              `xml:"tilewidth,attr"`
+    TileHeight   int              `xml:"tileheight,attr"`
+    Infinite     int              `xml:"infinite,attr"`
+    Properties   []tmxProperty    `xml:"properties>property"`
+    Tilesets     []tmxTileset     `xml:"tileset"`
+    Layers       []tmxLayer       `xml:"layer"`
+    ObjectGroups []tmxObjectGroup `xml:"objectgroup"`
+}
+
+type tmxProperty struct {
+    Name  string `xml:"name,attr"`
+    Type  string `xml:"type,attr"`
+    Value string `xml:"value,attr"`
+    Text  string `xml:",chardata"`
+}
+
+type tmxTileset struct {
+    FirstGID int    `xml:"firstgid,attr"`
+    Name     string `xml:"name,attr"`
+    Source   string `xml:"source,attr"`
+}
+
+type tmxLayer struct {
+    Name string `xml:"name,attr"`
+    Data struct {
+        Encoding    string `xml:"encoding,attr"`
+        Compression string `xml:"compression,attr"`
+        Text        string `xml:",chardata"`
+        Tiles       []struct {
+            GID uint32 `xml:"gid,attr"`
+        } `xml:"tile"`
+    } `xml:"data"`
+}
+
+type tmxObjectGroup struct {
+    Objects []tmxObject `xml:"object"`
+}
+
+type tmxObject struct {
+    Name       string        `xml:"name,attr"`
+    Class      string        `xml:"class,attr"`
+    Type       string        `xml:"type,attr"`
+    GID        uint32        `xml:"gid,attr"`
+    X          float64       `xml:"x,attr"`
+    Y          float64       `xml:"y,attr"`
+    Width      float64       `xml:"width,attr"`
+    Height     float64       `xml:"height,attr"`
+    Properties []tmxProperty `xml:"properties>property"`
+}
+
+// tmxLayerGIDs reads the GIDs of a TMX layer in any of its encodings
+func tmxLayerGIDs(layer tmxLayer) ([]uint32, error) {
+    switch layer.Data.Encoding {
+    case "":
+        gids := make([]uint32, len(layer.Data.Tiles))
+        for i, tile := range layer.Data.Tiles {
+            gids[i] = tile.GID
+        }
+        return gids, nil
+    case "csv":
+        var gids []uint32
+        for _, value := range strings.Split(layer.Data.Text, ",") {
+            value = strings.TrimSpace(value)
+            if value == "" {
+                continue
+            }
+            gid, err := strconv.ParseUint(value, 10, 32)
+            if err != nil {
+                return nil, err
+            }
+            gids = append(gids, uint32(gid))
+        }
+        return gids, nil
+    case "base64":
+        return decodeBase64GIDs(layer.Data.Text, layer.Data.Compression)
+    default:
+        return nil, fmt.Errorf("unsupported encoding %q", layer.Data.Encoding)
+    }
+}
+
+func tmxProperties(properties []tmxProperty) map[string]any {
+    if len(properties) == 0 {
+        return nil
+    }
+    values := make(map[string]any, len(properties))
+    for _, property := range properties {
+        value := property.Value
+        if value == "" {
+            // Multiline strings are kept in the element text
+            value = property.Text
+        }
+        values[property.Name] = tiledPropertyValue(property.Type, value)
+    }
+    return values
+}
+
+type tmjMap struct {
+    Orientation string        `json:"orientation"`
+    Width       int           `json:"width"`
+    Height      int           `json:"height"`
+    TileWidth   int           `json:"tilewidth"`
+    TileHeight  int           `json:"tileheight"`
+    Infinite    bool          `json:"infinite"`
+    Properties  []tmjProperty `json:"properties"`
+    Tilesets    []struct {
+        FirstGID int    `json:"firstgid"`
+        Name     string `json:"name"`
+        Source   string `json:"source"`
+    } `json:"tilesets"`
+    Layers []tmjLayer `json:"layers"`
+}
+
+type tmjProperty struct {
+    Name  string `json:"name"`
+    Value any    `json:"value"`
+}
+
+type tmjLayer struct {
+    Type        string          `json:"type"`
+    Name        string          `json:"name"`
+    Encoding    string          `json:"encoding"`
+    Compression string          `json:"compression"`
+    Data        json.RawMessage `json:"data"`
+    Objects     []struct {
+        Name       string        `json:"name"`
+        Class      string        `json:"class"`
+        Type       string        `json:"type"`
+        GID        uint32        `json:"gid"`
+        X          float64       `json:"x"`
+        Y          float64       `json:"y"`
+        Width      float64       `json:"width"`
+        Height     float64       `json:"height"`
+        Properties []tmjProperty `json:"properties"`
+    } `json:"objects"`
+}
+
+// tmjLayerGIDs reads the GIDs of a TMJ layer, an array of numbers or a base64 string
+func tmjLayerGIDs(layer tmjLayer) ([]uint32, error) {
+    if layer.Encoding == "base64" {
+        var data string
+        if err := json.Unmarshal(layer.Data, &data); err != nil {
+            return nil, err
+        }
+        return decodeBase64GIDs(data, layer.Compression)
+    }
+    var gids []uint32
+    if err := json.Unmarshal(layer.Data, &gids); err != nil {
+        return nil, err
+    }
+    return gids, nil
+}
+
+func tmjProperties(properties []tmjProperty) map[string]any {
+    if len(properties) == 0 {
+        return nil
+    }
+    values := make(map[string]any, len(properties))
+    for _, property := range properties {
+        values[property.Name] = property.Value
+    }
+    return values
+}
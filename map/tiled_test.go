@@ -0,0 +1,68 @@
+package gamemap
+
+import (
+    "github.com/stretchr/testify/assert"
+    "os"
+    "testing"
+)
+
+func TestTiledImport(t *testing.T) {
+    for _, file := range []string{"csv.tmx", "base64_zlib.tmx", "csv.tmj", "base64_gzip.tmj"} {
+        t.Run(file, func(t *testing.T) {
+            m := MustLoadGameMapFile("testdata/tiled/" + file)
+            assert.Equal(t, "Tiny Meadow", m.Name)
+            assert.Equal(t, 6, m.Width)
+            assert.Equal(t, 5, m.Height)
+            if !assert.Len(t, m.Layers, 2) {
+                return
+            }
+            assert.Equal(t, "roads", m.Layers[1].Name)
+            assert.Equal(t, TileNone, m.Layers[1].Tiles[1][1])
+            assert.Equal(t, TileMountain, m.Tiles[0][0])
+            assert.Equal(t, TileGrass, m.Tiles[1][1])
+            assert.Equal(t, TileWater, m.Tiles[2][3])
+            // The second road tile is flipped, which must not change its type
+            assert.Equal(t, TileRoad, m.Tiles[2][1])
+            assert.Equal(t, TileRoad, m.Tiles[2][2])
+
+            assert.Equal(t, []SpawnPoint{
+                {Kind: SpawnPlayer, X: 1, Y: 1},
+                {Kind: SpawnNPC, X: 2, Y: 1, Properties: map[string]any{"name": "Scout"}},
+                {Kind: SpawnGoblinDen, X: 4, Y: 2, Properties: map[string]any{"maxMonsters": float64(2)}},
+                {Kind: SpawnMushroomField, X: 1, Y: 2, Width: 2, Height: 2, Properties: map[string]any{"count": float64(3)}},
+            }, m.Spawns)
+        })
+    }
+
+    t.Run("Tiles are mapped with the given tileset mapping", func(t *testing.T) {
+        content, err := os.ReadFile("testdata/tiled/csv.tmx")
+        if !assert.NoError(t, err) {
+            return
+        }
+        _, err = DecodeTMX(content, TilesetMapping{})
+        assert.ErrorContains(t, err, `tileset "terrain" has no mapping`)
+
+        swamp := TilesetMapping{"terrain": {0: TileSwamp, 1: TileMountain, 2: TileWater, 4: TileRoad}}
+        m, err := DecodeTMX(content, swamp)
+        if assert.NoError(t, err) {
+            assert.Equal(t, TileSwamp, m.Tiles[1][1])
+        }
+
+        _, err = DecodeTMX(content, TilesetMapping{"terrain": {0: TileGrass}})
+        assert.ErrorContains(t, err, `tile 1 of tileset "terrain" has no mapping`)
+
+        m, err = Loader{Tilesets: swamp}.LoadFile("testdata/tiled/csv.tmj")
+        if assert.NoError(t, err) {
+            assert.Equal(t, TileSwamp, m.Tiles[1][1])
+        }
+        DefaultTilesetMapping()["terrain"][0] = TileSwamp
+        assert.Equal(t, TileGrass, MustLoadGameMapFile("testdata/tiled/csv.tmj").Tiles[1][1])
+    })
+
+    t.Run("Unsupported maps are reported", func(t *testing.T) {
+        _, err := DecodeTMJ([]byte(`{"type": "map", "orientation": "isometric", "width": 1, "height": 1, "tilewidth": 32, "tileheight": 32}`), DefaultTilesetMapping())
+        assert.ErrorContains(t, err, "isometric")
+        _, err = DecodeTMJ([]byte(`{"type": "map", "orientation": "orthogonal", "infinite": true}`), DefaultTilesetMapping())
+        assert.ErrorContains(t, err, "infinite")
+    })
+}
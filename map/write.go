@@ -0,0 +1,76 @@
+package gamemap
+
+import (
+    "bufio"
+    "encoding/json"
+    "fmt"
+    "io"
+    "strconv"
+)
+
+// WriteCSV writes the tiles of a map as the CSV grid LoadGameMap reads, one row per line.
+// Layers are written merged and spawn points are left out.
+func WriteCSV(w io.Writer, m *GameMap) error {
+    out := bufio.NewWriter(w)
+    for _, row := range m.Tiles {
+        for x, tile := range row {
+            if x > 0 {
+                out.WriteByte(',')
+            }
+            out.WriteString(strconv.Itoa(int(tile)))
+        }
+        out.WriteByte('\n')
+    }
+    return out.Flush()
+}
+
+// WriteJSON writes a map in the JSON format with its layers and spawn points. Maps without
+// layers are written with their tiles as a single ground layer. Every row and spawn point
+// is kept on a line of its own so the files stay readable and diff well.
+func WriteJSON(w io.Writer, m *GameMap) error {
+    layers := m.Layers
+    if len(layers) == 0 {
+        layers = []Layer{{Name: "ground", Tiles: m.Tiles}}
+    }
+    name, err := json.Marshal(m.Name)
+    if err != nil {
+        return err
+    }
+    out := bufio.NewWriter(w)
+    fmt.Fprintf(out, "{\n    \"name\": %s,\n    \"version\": %d,\n    \"tileSize\": %d,\n    \"width\": %d,\n    \"height\": %d,\n",
+        name, FormatVersion, TileSize, m.Width, m.Height)
+    out.WriteString("    \"layers\": [\n")
+    for i, layer := range layers {
+        layerName, err := json.Marshal(layer.Name)
+        if err != nil {
+            return err
+        }
+        fmt.Fprintf(out, "        {\"name\": %s, \"tiles\": [\n", layerName)
+        for y, row := range layer.Tiles {
+            rowJSON, err := json.Marshal(row)
+            if err != nil {
+                return err
+            }
+            fmt.Fprintf(out, "            %s%s\n", rowJSON, separator(y, len(layer.Tiles)))
+        }
+        fmt.Fprintf(out, "        ]}%s\n", separator(i, len(layers)))
+    }
+    out.WriteString("    ],\n    \"objects\": [\n")
+    for i, spawn := range m.Spawns {
+        spawnJSON, err := json.Marshal(spawn)
+        if err != nil {
+            return err
+        }
+        fmt.Fprintf(out, "        %s%s\n", spawnJSON, separator(i, len(m.Spawns)))
+    }
+    out.WriteString("    ]\n}\n")
+    return out.Flush()
+}
+
+// separator returns the comma following element i of n in a JSON list
+func separator(i, n int) string {
+    if i < n-1 {
+        return ","
+    }
+    return ""
+}
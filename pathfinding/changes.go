@@ -0,0 +1,63 @@
+package pathfinding
+
+import (
+    "github.com/solarlune/resolv"
+    "image"
+)
+
+// maxChanges is the number of changed cells a Changes log keeps. Readers further behind
+// rescan the whole space.
+const maxChanges = 4096
+
+// Changes is a log of the cells whose obstacles or terrain changed. Whoever adds or removes
+// obstacles and terrain objects marks their cells, and the caches built on a space read the
+// cells changed since their last look instead of rescanning the whole space every tick.
+type Changes struct {
+    cells []image.Point
+    // first is the version of the oldest cell kept
+    first int
+}
+
+// NewChanges creates an empty change log
+func NewChanges() *Changes {
+    return &Changes{}
+}
+
+// Mark records that the obstacles or terrain of a cell changed
+func (c *Changes) Mark(x, y int) {
+    if len(c.cells) >= maxChanges {
+        dropped := len(c.cells) / 2
+        c.cells = append(c.cells[:0], c.cells[dropped:]...)
+        c.first += dropped
+    }
+    c.cells = append(c.cells, image.Pt(x, y))
+}
+
+// MarkObject records the cells of an object entering or leaving a space if it is an
+// obstacle or marks terrain. Other objects do not change routes and are ignored.
+func (c *Changes) MarkObject(space *resolv.Space, obj *resolv.Object) {
+    if !obj.HasTags(ObstacleTags...) && !obj.HasTags(TerrainTag) {
+        return
+    }
+    x0, y0 := space.WorldToSpace(obj.Position.X, obj.Position.Y)
+    x1, y1 := space.WorldToSpace(obj.Position.X+obj.Size.X-1, obj.Position.Y+obj.Size.Y-1)
+    for y := y0; y <= y1; y++ {
+        for x := x0; x <= x1; x++ {
+            c.Mark(x, y)
+        }
+    }
+}
+
+// Version returns the version of the log after its latest change
+func (c *Changes) Version() int {
+    return c.first + len(c.cells)
+}
+
+// Since returns the cells changed after a version, which may repeat. It reports false
+// when some of them were dropped from the log and the whole space must be rescanned.
+func (c *Changes) Since(version int) ([]image.Point, bool) {
+    if version < c.first {
+        return nil, false
+    }
+    return c.cells[version-c.first:], true
+}
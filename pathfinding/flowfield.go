@@ -0,0 +1,312 @@
+package pathfinding
+
+import (
+    "container/heap"
+    "github.com/solarlune/resolv"
+    "image"
+    "math"
+    "slices"
+)
+
+// ObstacleTags are the tags of objects that block movement through a cell
+var ObstacleTags = []string{"goblin_den", "mountain"}
+
+const diagonalCost = 1.414
+
+// FlowField holds the walking distance from every cell of a space to one target cell.
+// It is computed once with Dijkstra's algorithm and can then be sampled by any number
+// of agents heading to the same target. Movement follows the same rules as FindPath.
+type FlowField struct {
+    TargetX, TargetY int
+    grid
+    distance []float64
+    used     bool
+}
+
+// NewFlowField computes the flow field towards a target cell of the space.
+// The target cell itself may be blocked, e.g. when walking up to a den.
+func NewFlowField(space *resolv.Space, targetX, targetY int) *FlowField {
+    return newFlowField(newGrid(space), targetX, targetY)
+}
+
+func newFlowField(g grid, targetX, targetY int) *FlowField {
+    width := g.width
+    f := &FlowField{
+        TargetX:  targetX,
+        TargetY:  targetY,
+        grid:     g,
+        distance: make([]float64, g.width*g.height),
+    }
+    for i := range f.distance {
+        f.distance[i] = math.Inf(1)
+    }
+    if !f.inside(targetX, targetY) {
+        return f
+    }
+
+    target := f.index(targetX, targetY)
+    f.distance[target] = 0
+    open := &cellQueue{{index: target}}
+    for open.Len() > 0 {
+        current := heap.Pop(open).(queuedCell)
+        if current.distance > f.distance[current.index] {
+            continue
+        }
+        x, y := current.index%width, current.index/width
+        f.neighbors(x, y, func(nx, ny int, cost float64) {
+            next := f.index(nx, ny)
+            if d := current.distance + cost; d < f.distance[next] {
+                f.distance[next] = d
+                heap.Push(open, queuedCell{index: next, distance: d})
+            }
+        })
+    }
+    return f
+}
+
+// newGrid scans a space for obstacles and terrain
+func newGrid(space *resolv.Space) grid {
+    width, height := space.Width(), space.Height()
+    g := grid{width: width, height: height, blocked: make([]bool, width*height), cost: make([]float64, width*height)}
+    for y := 0; y < height; y++ {
+        for x := 0; x < width; x++ {
+            g.blocked[y*width+x], g.cost[y*width+x] = scanCell(space, x, y)
+        }
+    }
+    return g
+}
+
+// scanCell returns whether a cell of a space is blocked and its terrain cost
+func scanCell(space *resolv.Space, x, y int) (bool, float64) {
+    return space.Cell(x, y).ContainsTags(ObstacleTags...), TileAt(space, x, y).Terrain().Cost
+}
+
+// grid is the walkable cells of a space and their terrain cost, one entry per cell in row order
+type grid struct {
+    width, height int
+    blocked       []bool
+    cost          []float64
+}
+
+// equal reports whether two grids have the same obstacles and terrain
+func (g grid) equal(o grid) bool {
+    if g.width != o.width || g.height != o.height {
+        return false
+    }
+    for i := range g.blocked {
+        if g.blocked[i] != o.blocked[i] || g.cost[i] != o.cost[i] {
+            return false
+        }
+    }
+    return true
+}
+
+// changed returns the cells among cells whose obstacles or terrain in the space differ
+// from the grid, each once
+func (g grid) changed(space *resolv.Space, cells []image.Point) []image.Point {
+    var changed []image.Point
+    for _, cell := range cells {
+        if !g.inside(cell.X, cell.Y) || slices.Contains(changed, cell) {
+            continue
+        }
+        i := g.index(cell.X, cell.Y)
+        if blocked, cost := scanCell(space, cell.X, cell.Y); blocked != g.blocked[i] || cost != g.cost[i] {
+            changed = append(changed, cell)
+        }
+    }
+    return changed
+}
+
+// clone returns a copy of the grid that can be changed without affecting it
+func (g grid) clone() grid {
+    return grid{width: g.width, height: g.height, blocked: slices.Clone(g.blocked), cost: slices.Clone(g.cost)}
+}
+
+func (g grid) inside(x, y int) bool {
+    return x >= 0 && y >= 0 && x < g.width && y < g.height
+}
+
+func (g grid) index(x, y int) int {
+    return y*g.width + x
+}
+
+func (g grid) free(x, y int) bool {
+    return g.inside(x, y) && !g.blocked[g.index(x, y)]
+}
+
+// neighbors calls visit for every cell reachable in one step, with the same rules and
+// costs as PathNode: diagonal steps need both adjacent straight cells to be free.
+func (g grid) neighbors(x, y int, visit func(nx, ny int, cost float64)) {
+    left, right := g.free(x-1, y), g.free(x+1, y)
+    up, down := g.free(x, y-1), g.free(x, y+1)
+    step := func(nx, ny int, base float64) {
+        visit(nx, ny, base*(g.cost[g.index(x, y)]+g.cost[g.index(nx, ny)])/2)
+    }
+    if left {
+        step(x-1, y, 1)
+    }
+    if right {
+        step(x+1, y, 1)
+    }
+    if up {
+        step(x, y-1, 1)
+    }
+    if down {
+        step(x, y+1, 1)
+    }
+    if left && up && g.free(x-1, y-1) {
+        step(x-1, y-1, diagonalCost)
+    }
+    if right && up && g.free(x+1, y-1) {
+        step(x+1, y-1, diagonalCost)
+    }
+    if left && down && g.free(x-1, y+1) {
+        step(x-1, y+1, diagonalCost)
+    }
+    if right && down && g.free(x+1, y+1) {
+        step(x+1, y+1, diagonalCost)
+    }
+}
+
+// Distance returns the terrain weighted walking distance in cells from a cell to the target
+func (f *FlowField) Distance(x, y int) (float64, bool) {
+    if !f.inside(x, y) {
+        return 0, false
+    }
+    d := f.distance[f.index(x, y)]
+    return d, !math.IsInf(d, 1)
+}
+
+// Next returns the cell to step to from a cell on the way to the target.
+// It reports false at the target, next to a blocked target, and for cells that cannot reach it.
+func (f *FlowField) Next(x, y int) (int, int, bool) {
+    if d, ok := f.Distance(x, y); !ok || d == 0 {
+        return 0, 0, false
+    }
+    if abs(x-f.TargetX) <= 1 && abs(y-f.TargetY) <= 1 && !f.free(f.TargetX, f.TargetY) {
+        return 0, 0, false
+    }
+    bestX, bestY, best := 0, 0, math.Inf(1)
+    f.neighbors(x, y, func(nx, ny int, cost float64) {
+        if d := f.distance[f.index(nx, ny)] + cost; d < best {
+            bestX, bestY, best = nx, ny, d
+        }
+    })
+    return bestX, bestY, !math.IsInf(best, 1)
+}
+
+// queuedCell is an entry of the Dijkstra open list
+type queuedCell struct {
+    index    int
+    distance float64
+}
+
+// cellQueue is a binary heap of cells ordered by distance
+type cellQueue []queuedCell
+
+func (q cellQueue) Len() int { return len(q) }
+
+func (q cellQueue) Less(i, j int) bool {
+    if q[i].distance != q[j].distance {
+        return q[i].distance < q[j].distance
+    }
+    return q[i].index < q[j].index
+}
+
+func (q cellQueue) Swap(i, j int) { q[i], q[j] = q[j], q[i] }
+
+func (q *cellQueue) Push(x any) { *q = append(*q, x.(queuedCell)) }
+
+func (q *cellQueue) Pop() any {
+    old := *q
+    c := old[len(old)-1]
+    *q = old[:len(old)-1]
+    return c
+}
+
+// FlowFields caches flow fields by target cell for one space.
+// Call Sync once per tick: it drops every field when obstacles or terrain changed in the
+// space, and fields that were not sampled since the previous Sync.
+type FlowFields struct {
+    space   *resolv.Space
+    grid    grid
+    fields  map[[2]int]*FlowField
+    changes *Changes
+    version int
+}
+
+// NewFlowFields creates an empty flow field cache for the space. Sync rescans the cells
+// marked in changes, or the whole space when changes is nil.
+func NewFlowFields(space *resolv.Space, changes *Changes) *FlowFields {
+    c := &FlowFields{
+        space:   space,
+        grid:    newGrid(space),
+        fields:  make(map[[2]int]*FlowField),
+        changes: changes,
+    }
+    if changes != nil {
+        c.version = changes.Version()
+    }
+    return c
+}
+
+// Field returns the flow field towards a target cell, computing it if it is not cached
+func (c *FlowFields) Field(targetX, targetY int) *FlowField {
+    key := [2]int{targetX, targetY}
+    field, ok := c.fields[key]
+    if !ok {
+        field = newFlowField(c.grid, targetX, targetY)
+        c.fields[key] = field
+    }
+    field.used = true
+    return field
+}
+
+// Len returns the number of cached fields
+func (c *FlowFields) Len() int {
+    return len(c.fields)
+}
+
+// Sync rescans the changed cells for obstacles and terrain and evicts stale fields.
+// It reports whether either changed since the last scan.
+func (c *FlowFields) Sync() bool {
+    if c.update() {
+        c.fields = make(map[[2]int]*FlowField)
+        return true
+    }
+    for key, field := range c.fields {
+        if !field.used {
+            delete(c.fields, key)
+        }
+        field.used = false
+    }
+    return false
+}
+
+// update brings the grid up to date with the space and reports whether it changed.
+// The cached fields share the old grid, so it is copied rather than changed in place.
+func (c *FlowFields) update() bool {
+    cells, ok := []image.Point(nil), false
+    if c.changes != nil {
+        cells, ok = c.changes.Since(c.version)
+        c.version = c.changes.Version()
+    }
+    if !ok {
+        g := newGrid(c.space)
+        if g.equal(c.grid) {
+            return false
+        }
+        c.grid = g
+        return true
+    }
+    changed := c.grid.changed(c.space, cells)
+    if len(changed) == 0 {
+        return false
+    }
+    c.grid = c.grid.clone()
+    for _, cell := range changed {
+        i := c.grid.index(cell.X, cell.Y)
+        c.grid.blocked[i], c.grid.cost[i] = scanCell(c.space, cell.X, cell.Y)
+    }
+    return true
+}
@@ -0,0 +1,195 @@
+package pathfinding
+
+import (
+    gamemap "example.com/maj/map"
+    "github.com/solarlune/resolv"
+    "github.com/stretchr/testify/assert"
+    "math/rand"
+    "testing"
+)
+
+// mapSpace builds a collision space with the mountains of a map file
+func mapSpace(tb testing.TB, filename string) (*resolv.Space, *gamemap.GameMap) {
+    tb.Helper()
+    gameMap := gamemap.MustLoadGameMapFile(filename)
+    size := gamemap.TileSize
+    space := resolv.NewSpace(gameMap.Width*size, gameMap.Height*size, size, size)
+    for y := 0; y < gameMap.Height; y++ {
+        for x := 0; x < gameMap.Width; x++ {
+            if gameMap.Tiles[y][x] == gamemap.TileMountain {
+                addObstacle(space, x, y, "mountain")
+            }
+        }
+    }
+    return space, gameMap
+}
+
+func addObstacle(space *resolv.Space, x, y int, tags ...string) *resolv.Object {
+    size := float64(gamemap.TileSize)
+    obj := resolv.NewObject(float64(x)*size, float64(y)*size, size, size)
+    obj.AddTags(tags...)
+    space.Add(obj)
+    return obj
+}
+
+// freeCells picks n random cells without obstacles
+func freeCells(space *resolv.Space, rng *rand.Rand, n int) [][2]int {
+    var cells [][2]int
+    for len(cells) < n {
+        x, y := rng.Intn(space.Width()), rng.Intn(space.Height())
+        if !space.Cell(x, y).ContainsTags(ObstacleTags...) {
+            cells = append(cells, [2]int{x, y})
+        }
+    }
+    return cells
+}
+
+// followField walks the field from a cell and returns the number of steps to the target
+func followField(field *FlowField, x, y int) int {
+    steps := 0
+    for {
+        nx, ny, ok := field.Next(x, y)
+        if !ok {
+            return steps
+        }
+        x, y = nx, ny
+        steps++
+    }
+}
+
+func TestFlowField(t *testing.T) {
+    space, _ := mapSpace(t, "../map/map1.txt")
+    rng := rand.New(rand.NewSource(1))
+
+    t.Run("Distances match A* path costs", func(t *testing.T) {
+        target := freeCells(space, rng, 1)[0]
+        field := NewFlowField(space, target[0], target[1])
+        for _, start := range freeCells(space, rng, 30) {
+            _, cost, found := FindPath(space, start[0], start[1], target[0], target[1])
+            distance, reachable := field.Distance(start[0], start[1])
+            assert.Equal(t, found, reachable, "start %v", start)
+            if found {
+                assert.InDelta(t, cost, distance, 1e-9, "start %v", start)
+                steps := followField(field, start[0], start[1])
+                assert.LessOrEqual(t, float64(steps), distance+1e-9, "start %v", start)
+            }
+        }
+    })
+
+    t.Run("Route ends next to a blocked target", func(t *testing.T) {
+        space := resolv.NewSpace(10*gamemap.TileSize, 10*gamemap.TileSize, gamemap.TileSize, gamemap.TileSize)
+        addObstacle(space, 5, 5, "goblin_den", "mountain")
+        field := NewFlowField(space, 5, 5)
+
+        x, y := 1, 1
+        for i := 0; i < 10; i++ {
+            nx, ny, ok := field.Next(x, y)
+            if !ok {
+                break
+            }
+            x, y = nx, ny
+        }
+        assert.Equal(t, [2]int{4, 4}, [2]int{x, y})
+    })
+
+    t.Run("Cache is invalidated when obstacles change", func(t *testing.T) {
+        space := resolv.NewSpace(10*gamemap.TileSize, 10*gamemap.TileSize, gamemap.TileSize, gamemap.TileSize)
+        changes := NewChanges()
+        cache := NewFlowFields(space, changes)
+        field := cache.Field(9, 0)
+        assert.Same(t, field, cache.Field(9, 0))
+
+        assert.False(t, cache.Sync())
+        assert.Equal(t, 1, cache.Len())
+
+        den := addObstacle(space, 8, 0, "goblin_den", "mountain")
+        changes.MarkObject(space, den)
+        assert.True(t, cache.Sync())
+        assert.Equal(t, 0, cache.Len())
+        d, _ := cache.Field(9, 0).Distance(7, 0)
+        assert.InDelta(t, 4, d, 1e-9)
+
+        space.Remove(den)
+        changes.MarkObject(space, den)
+        assert.True(t, cache.Sync())
+        d, _ = cache.Field(9, 0).Distance(7, 0)
+        assert.InDelta(t, 2, d, 1e-9)
+
+        changes.Mark(3, 3)
+        assert.False(t, cache.Sync(), "marked cells that did not change keep the fields")
+    })
+
+    t.Run("Changes dropped from the log rescan the whole space", func(t *testing.T) {
+        space := resolv.NewSpace(10*gamemap.TileSize, 10*gamemap.TileSize, gamemap.TileSize, gamemap.TileSize)
+        changes := NewChanges()
+        cache := NewFlowFields(space, changes)
+        addObstacle(space, 8, 0, "mountain")
+        for i := 0; i <= maxChanges; i++ {
+            changes.Mark(0, 0)
+        }
+        assert.True(t, cache.Sync())
+        d, _ := cache.Field(9, 0).Distance(7, 0)
+        assert.InDelta(t, 4, d, 1e-9)
+    })
+
+    t.Run("Unused fields are evicted", func(t *testing.T) {
+        space := resolv.NewSpace(10*gamemap.TileSize, 10*gamemap.TileSize, gamemap.TileSize, gamemap.TileSize)
+        cache := NewFlowFields(space, NewChanges())
+        cache.Field(1, 1)
+        cache.Field(2, 2)
+        cache.Sync()
+        cache.Field(1, 1)
+        cache.Sync()
+        assert.Equal(t, 1, cache.Len())
+    })
+}
+
+// benchmarkAgents is the number of agents heading to the same target in the benchmarks
+const benchmarkAgents = 50
+
+func BenchmarkAStarAgents(b *testing.B) {
+    space, _ := mapSpace(b, "../map/map1.txt")
+    rng := rand.New(rand.NewSource(1))
+    target := freeCells(space, rng, 1)[0]
+    agents := freeCells(space, rng, benchmarkAgents)
+
+    b.ResetTimer()
+    for i := 0; i < b.N; i++ {
+        for _, agent := range agents {
+            FindPath(space, agent[0], agent[1], target[0], target[1])
+        }
+    }
+}
+
+func BenchmarkFlowFieldAgents(b *testing.B) {
+    space, _ := mapSpace(b, "../map/map1.txt")
+    rng := rand.New(rand.NewSource(1))
+    target := freeCells(space, rng, 1)[0]
+    agents := freeCells(space, rng, benchmarkAgents)
+
+    b.ResetTimer()
+    for i := 0; i < b.N; i++ {
+        field := NewFlowField(space, target[0], target[1])
+        for _, agent := range agents {
+            followField(field, agent[0], agent[1])
+        }
+    }
+}
+
+// BenchmarkFlowFieldAgentsCached samples a field that stays cached between ticks
+func BenchmarkFlowFieldAgentsCached(b *testing.B) {
+    space, _ := mapSpace(b, "../map/map1.txt")
+    rng := rand.New(rand.NewSource(1))
+    target := freeCells(space, rng, 1)[0]
+    agents := freeCells(space, rng, benchmarkAgents)
+    cache := NewFlowFields(space, NewChanges())
+
+    b.ResetTimer()
+    for i := 0; i < b.N; i++ {
+        field := cache.Field(target[0], target[1])
+        for _, agent := range agents {
+            field.Next(agent[0], agent[1])
+        }
+        cache.Sync()
+    }
+}
@@ -0,0 +1,400 @@
+package pathfinding
+
+import (
+    "container/heap"
+    "github.com/solarlune/resolv"
+    "image"
+    "math"
+)
+
+// PathFollower keeps the route of one agent to a target cell between ticks.
+// The route is searched with D* Lite, rooted at the target, so the agent moving along
+// it costs nothing. Obstacles changing and the target moving to another cell are repaired
+// incrementally: the target is treated as the only cell linked to a virtual root, and
+// moving it changes two of the root's edges.
+type PathFollower struct {
+    space *resolv.Space
+    grid
+    // Path is the cached route from the agent's cell to the target
+    Path []PathNode
+    // Searches counts full searches and Repairs incremental repairs after the target
+    // moved or obstacles changed
+    Searches, Repairs int
+    // Hierarchy, when set, routes to targets more than two of its clusters away on its
+    // entrance graph instead of searching the whole grid
+    Hierarchy *Hierarchy
+
+    changes *Changes
+    version int
+    // goal is the target cell of the D* Lite search, -1 for none. It is kept free in the
+    // grid since a blocked target can still be walked up to.
+    goal, start int
+    g, rhs      []float64
+    open        dstarQueue
+    km          float64
+    // coarseGoal is the target cell of a route from the hierarchy, -1 for none
+    coarseGoal int
+}
+
+// NewPathFollower creates a follower for routes through the space. It rescans the cells
+// marked in changes, or compares every cell of the space on each call when changes is nil.
+func NewPathFollower(space *resolv.Space, changes *Changes) *PathFollower {
+    f := &PathFollower{space: space, grid: newGrid(space), changes: changes, goal: -1, coarseGoal: -1}
+    if changes != nil {
+        f.version = changes.Version()
+    }
+    return f
+}
+
+// Space returns the space the follower routes through
+func (f *PathFollower) Space() *resolv.Space {
+    return f.space
+}
+
+// Next returns the cell to step to from a cell on the way to the target cell.
+// It reports false at the target, next to a blocked target, and when there is no route.
+func (f *PathFollower) Next(x, y, targetX, targetY int) (int, int, bool) {
+    if f.space.Cell(x, y) == nil || f.space.Cell(targetX, targetY) == nil {
+        f.Path = nil
+        return 0, 0, false
+    }
+    start := y*f.space.Width() + x
+    goal := targetY*f.space.Width() + targetX
+    changed := f.changedCells()
+    if f.Hierarchy != nil && max(abs(x-targetX), abs(y-targetY)) > 2*f.Hierarchy.ClusterSize {
+        f.setGoal(-1)
+        f.rescan(changed)
+        return f.nextCoarse(start, goal)
+    }
+    switch {
+    case f.goal < 0:
+        f.rescan(changed)
+        f.search(start, goal)
+    case goal != f.goal || len(changed) > 0:
+        f.repair(start, goal, changed)
+    case start != f.start:
+        f.advance(start)
+    }
+
+    if len(f.Path) < 2 {
+        return 0, 0, false
+    }
+    if abs(x-targetX) <= 1 && abs(y-targetY) <= 1 && f.space.Cell(targetX, targetY).ContainsTags(ObstacleTags...) {
+        return 0, 0, false
+    }
+    return f.Path[1].X, f.Path[1].Y, true
+}
+
+// nextCoarse follows a route from the hierarchy to a distant target. The route is searched
+// again when the target changes cell, the agent leaves the route or an obstacle appears on it.
+func (f *PathFollower) nextCoarse(start, goal int) (int, int, bool) {
+    width := f.space.Width()
+    onRoute := -1
+    if goal == f.coarseGoal {
+        for i, node := range f.Path {
+            if node.Y*width+node.X == start {
+                onRoute = i
+                break
+            }
+        }
+    }
+    if onRoute < 0 || f.coarseBlocked() {
+        f.Searches++
+        f.Path, _, _ = f.Hierarchy.FindPath(start%width, start/width, goal%width, goal/width)
+        onRoute = 0
+    }
+    // The D* Lite search starts over once the target is near
+    f.coarseGoal = goal
+    if len(f.Path) > 0 {
+        f.Path = f.Path[onRoute:]
+    }
+    if len(f.Path) < 2 {
+        return 0, 0, false
+    }
+    return f.Path[1].X, f.Path[1].Y, true
+}
+
+// coarseBlocked reports whether an obstacle appeared on the route from the hierarchy
+func (f *PathFollower) coarseBlocked() bool {
+    for i, node := range f.Path {
+        if i < len(f.Path)-1 && f.blocked[f.index(node.X, node.Y)] {
+            return true
+        }
+    }
+    return false
+}
+
+// changedCells returns the cells whose obstacles or terrain differ from the grid
+func (f *PathFollower) changedCells() []image.Point {
+    if f.changes != nil {
+        cells, ok := f.changes.Since(f.version)
+        f.version = f.changes.Version()
+        if ok {
+            return f.changed(f.space, cells)
+        }
+    }
+    var changed []image.Point
+    scanned := newGrid(f.space)
+    for i := range scanned.blocked {
+        if scanned.cost[i] != f.cost[i] || (i != f.goal && scanned.blocked[i] != f.blocked[i]) {
+            changed = append(changed, image.Pt(i%f.width, i/f.width))
+        }
+    }
+    return changed
+}
+
+// rescan updates the grid for changed cells without repairing the search
+func (f *PathFollower) rescan(changed []image.Point) {
+    for _, cell := range changed {
+        i := f.index(cell.X, cell.Y)
+        f.blocked[i], f.cost[i] = scanCell(f.space, cell.X, cell.Y)
+        if i == f.goal {
+            f.blocked[i] = false
+        }
+    }
+}
+
+// setGoal moves the goal of the search to another cell, -1 for none, and frees it in the grid
+func (f *PathFollower) setGoal(goal int) {
+    old := f.goal
+    f.goal = goal
+    if old >= 0 {
+        f.blocked[old], _ = scanCell(f.space, old%f.width, old/f.width)
+    }
+    if goal >= 0 {
+        f.blocked[goal] = false
+    }
+}
+
+// search starts a new D* Lite search towards the goal cell
+func (f *PathFollower) search(start, goal int) {
+    f.Searches++
+    f.coarseGoal = -1
+    f.setGoal(goal)
+    f.start, f.km = start, 0
+    if len(f.g) != len(f.blocked) {
+        f.g = make([]float64, len(f.blocked))
+        f.rhs = make([]float64, len(f.blocked))
+        f.open = dstarQueue{position: make([]int, len(f.blocked))}
+    }
+    for i := range f.g {
+        f.g[i], f.rhs[i] = math.Inf(1), math.Inf(1)
+    }
+    f.open.clear()
+    f.rhs[goal] = 0
+    f.open.set(goal, f.key(goal))
+    f.computeShortestPath()
+    f.extractPath()
+}
+
+// advance moves the search start to the agent's new cell
+func (f *PathFollower) advance(start int) {
+    f.km += f.heuristic(f.start, start)
+    f.start = start
+    for i, node := range f.Path {
+        if f.index(node.X, node.Y) == start {
+            // Still on the route, drop the waypoints that were reached
+            f.Path = f.Path[i:]
+            return
+        }
+    }
+    f.computeShortestPath()
+    f.extractPath()
+}
+
+// repair moves the start and goal of the search and updates the cells whose obstacles
+// or terrain changed, then fixes the route incrementally
+func (f *PathFollower) repair(start, goal int, changed []image.Point) {
+    f.Repairs++
+    f.km += f.heuristic(f.start, start)
+    f.start = start
+    for _, cell := range changed {
+        i := f.index(cell.X, cell.Y)
+        blocked, cost := scanCell(f.space, cell.X, cell.Y)
+        f.setCell(i, blocked && i != f.goal, cost)
+    }
+    if goal != f.goal {
+        old := f.goal
+        oldBlocked, _ := scanCell(f.space, old%f.width, old/f.width)
+        // The old goal loses its edge to the root and the new one gains it
+        f.goal = goal
+        f.setCell(old, oldBlocked, f.cost[old])
+        f.setCell(goal, false, f.cost[goal])
+        f.updateVertex(old)
+        f.updateVertex(goal)
+    }
+    f.computeShortestPath()
+    f.extractPath()
+}
+
+// setCell changes whether a cell is blocked and its terrain cost
+func (f *PathFollower) setCell(i int, blocked bool, cost float64) {
+    if blocked == f.blocked[i] && cost == f.cost[i] {
+        return
+    }
+    f.blocked[i], f.cost[i] = blocked, cost
+    // Changing a cell changes the edges of every cell around it, including diagonals passing its corner
+    x, y := i%f.width, i/f.width
+    for dy := -1; dy <= 1; dy++ {
+        for dx := -1; dx <= 1; dx++ {
+            if f.inside(x+dx, y+dy) {
+                f.updateVertex(f.index(x+dx, y+dy))
+            }
+        }
+    }
+}
+
+// heuristic is the octile distance between two cells over the cheapest terrain,
+// which never overestimates a route
+func (f *PathFollower) heuristic(a, b int) float64 {
+    return octileDistance(abs(a%f.width-b%f.width), abs(a/f.width-b/f.width))
+}
+
+func (f *PathFollower) key(i int) dstarKey {
+    m := math.Min(f.g[i], f.rhs[i])
+    return dstarKey{m + f.heuristic(f.start, i) + f.km, m}
+}
+
+func (f *PathFollower) updateVertex(i int) {
+    if i == f.goal {
+        f.rhs[i] = 0
+    } else {
+        f.rhs[i] = math.Inf(1)
+        if !f.blocked[i] {
+            f.neighbors(i%f.width, i/f.width, func(nx, ny int, cost float64) {
+                if d := cost + f.g[f.index(nx, ny)]; d < f.rhs[i] {
+                    f.rhs[i] = d
+                }
+            })
+        }
+    }
+    f.open.remove(i)
+    if f.g[i] != f.rhs[i] {
+        f.open.set(i, f.key(i))
+    }
+}
+
+func (f *PathFollower) computeShortestPath() {
+    for f.open.Len() > 0 && (f.open.top().key.less(f.key(f.start)) || f.rhs[f.start] != f.g[f.start]) {
+        top := f.open.top()
+        u := top.cell
+        if newKey := f.key(u); top.key.less(newKey) {
+            f.open.set(u, newKey)
+            continue
+        }
+        f.open.remove(u)
+        x, y := u%f.width, u/f.width
+        if f.g[u] > f.rhs[u] {
+            f.g[u] = f.rhs[u]
+        } else {
+            f.g[u] = math.Inf(1)
+            f.updateVertex(u)
+        }
+        f.neighbors(x, y, func(nx, ny int, _ float64) {
+            f.updateVertex(f.index(nx, ny))
+        })
+    }
+}
+
+// extractPath follows the cheapest neighbors from the start to the goal
+func (f *PathFollower) extractPath() {
+    f.Path = f.Path[:0]
+    if math.IsInf(f.g[f.start], 1) && f.start != f.goal {
+        return
+    }
+    current := f.start
+    for steps := 0; steps <= len(f.g); steps++ {
+        f.Path = append(f.Path, PathNode{X: current % f.width, Y: current / f.width, space: f.space})
+        if current == f.goal {
+            return
+        }
+        best, bestCost := -1, math.Inf(1)
+        f.neighbors(current%f.width, current/f.width, func(nx, ny int, cost float64) {
+            next := f.index(nx, ny)
+            if d := cost + f.g[next]; d < bestCost {
+                best, bestCost = next, d
+            }
+        })
+        if best < 0 {
+            break
+        }
+        current = best
+    }
+    f.Path = f.Path[:0]
+}
+
+// dstarKey is the two-part priority of a D* Lite queue entry
+type dstarKey [2]float64
+
+func (k dstarKey) less(o dstarKey) bool {
+    return k[0] < o[0] || (k[0] == o[0] && k[1] < o[1])
+}
+
+type dstarEntry struct {
+    cell int
+    key  dstarKey
+}
+
+// dstarQueue is a binary heap of cells that supports updating and removing entries
+type dstarQueue struct {
+    entries []dstarEntry
+    // position holds the heap index plus one of every queued cell, zero when not queued
+    position []int
+}
+
+func (q *dstarQueue) Len() int { return len(q.entries) }
+
+func (q *dstarQueue) Less(i, j int) bool {
+    if q.entries[i].key == q.entries[j].key {
+        return q.entries[i].cell < q.entries[j].cell
+    }
+    return q.entries[i].key.less(q.entries[j].key)
+}
+
+func (q *dstarQueue) Swap(i, j int) {
+    q.entries[i], q.entries[j] = q.entries[j], q.entries[i]
+    q.position[q.entries[i].cell] = i + 1
+    q.position[q.entries[j].cell] = j + 1
+}
+
+func (q *dstarQueue) Push(x any) {
+    e := x.(dstarEntry)
+    q.entries = append(q.entries, e)
+    q.position[e.cell] = len(q.entries)
+}
+
+func (q *dstarQueue) Pop() any {
+    e := q.entries[len(q.entries)-1]
+    q.entries = q.entries[:len(q.entries)-1]
+    q.position[e.cell] = 0
+    return e
+}
+
+func (q *dstarQueue) top() dstarEntry {
+    return q.entries[0]
+}
+
+// set inserts a cell or changes its key
+func (q *dstarQueue) set(cell int, key dstarKey) {
+    if i := q.position[cell]; i > 0 {
+        q.entries[i-1].key = key
+        heap.Fix(q, i-1)
+        return
+    }
+    heap.Push(q, dstarEntry{cell: cell, key: key})
+}
+
+// clear empties the queue
+func (q *dstarQueue) clear() {
+    for _, e := range q.entries {
+        q.position[e.cell] = 0
+    }
+    q.entries = q.entries[:0]
+}
+
+// remove takes a cell out of the queue if it is in it
+func (q *dstarQueue) remove(cell int) {
+    if i := q.position[cell]; i > 0 {
+        heap.Remove(q, i-1)
+    }
+}
@@ -0,0 +1,182 @@
+package pathfinding
+
+import (
+    gamemap "example.com/maj/map"
+    "github.com/solarlune/resolv"
+    "github.com/stretchr/testify/assert"
+    "math/rand"
+    "testing"
+)
+
+// routeCost sums the step costs of a route
+func routeCost(path []PathNode) float64 {
+    cost := 0.0
+    for i := 1; i < len(path); i++ {
+        cost += path[i-1].PathNeighborCost(path[i])
+    }
+    return cost
+}
+
+// chaseStep moves a target to a random free neighbor cell
+func chaseStep(space *resolv.Space, rng *rand.Rand, cell [2]int) [2]int {
+    neighbors := PathNode{X: cell[0], Y: cell[1], space: space}.PathNeighbors()
+    if len(neighbors) == 0 {
+        return cell
+    }
+    next := neighbors[rng.Intn(len(neighbors))].(PathNode)
+    return [2]int{next.X, next.Y}
+}
+
+func TestPathFollower(t *testing.T) {
+    space, _ := mapSpace(t, "../map/map1.txt")
+    rng := rand.New(rand.NewSource(2))
+
+    t.Run("Routes are as short as A* paths", func(t *testing.T) {
+        target := freeCells(space, rng, 1)[0]
+        for _, start := range freeCells(space, rng, 20) {
+            follower := NewPathFollower(space, NewChanges())
+            _, _, found := follower.Next(start[0], start[1], target[0], target[1])
+            _, cost, reachable := FindPath(space, start[0], start[1], target[0], target[1])
+            assert.Equal(t, reachable, len(follower.Path) > 0, "start %v", start)
+            if reachable && start != target {
+                assert.True(t, found, "start %v", start)
+                assert.InDelta(t, cost, routeCost(follower.Path), 1e-9, "start %v", start)
+            }
+        }
+    })
+
+    t.Run("Walking along the route does not search again", func(t *testing.T) {
+        follower := NewPathFollower(space, NewChanges())
+        start, target := freeCells(space, rng, 1)[0], freeCells(space, rng, 1)[0]
+        x, y := start[0], start[1]
+        for i := 0; i < 200; i++ {
+            nx, ny, ok := follower.Next(x, y, target[0], target[1])
+            if !ok {
+                break
+            }
+            x, y = nx, ny
+        }
+        assert.Equal(t, target, [2]int{x, y})
+        assert.Equal(t, 1, follower.Searches)
+        assert.Equal(t, 0, follower.Repairs)
+    })
+
+    t.Run("An obstacle on the route is repaired around", func(t *testing.T) {
+        space := resolv.NewSpace(10*gamemap.TileSize, 10*gamemap.TileSize, gamemap.TileSize, gamemap.TileSize)
+        changes := NewChanges()
+        follower := NewPathFollower(space, changes)
+        _, _, ok := follower.Next(0, 5, 9, 5)
+        assert.True(t, ok)
+        assert.InDelta(t, 9, routeCost(follower.Path), 1e-9)
+
+        var wall []*resolv.Object
+        for y := 3; y <= 7; y++ {
+            wall = append(wall, addObstacle(space, 5, y, "mountain"))
+            changes.MarkObject(space, wall[len(wall)-1])
+        }
+        x, y, ok := follower.Next(0, 5, 9, 5)
+        assert.True(t, ok)
+        assert.Equal(t, 1, follower.Searches)
+        assert.Equal(t, 1, follower.Repairs)
+
+        follower.Next(x, y, 9, 5)
+        _, expected, _ := FindPath(space, x, y, 9, 5)
+        assert.InDelta(t, expected, routeCost(follower.Path), 1e-9)
+        for _, node := range follower.Path {
+            assert.False(t, space.Cell(node.X, node.Y).ContainsTags("mountain"), "route goes through %v", node)
+        }
+
+        // The route gets shorter again once the obstacle is gone
+        for _, obj := range wall {
+            space.Remove(obj)
+            changes.MarkObject(space, obj)
+        }
+        follower.Next(x, y, 9, 5)
+        _, expected, _ = FindPath(space, x, y, 9, 5)
+        assert.InDelta(t, expected, routeCost(follower.Path), 1e-9)
+        assert.Equal(t, 1, follower.Searches)
+        assert.Equal(t, 2, follower.Repairs)
+    })
+
+    t.Run("A moving target is repaired without searching again", func(t *testing.T) {
+        follower := NewPathFollower(space, NewChanges())
+        cells := freeCells(space, rng, 2)
+        agent, target := cells[0], cells[1]
+        for tick := 0; tick < 200; tick++ {
+            _, _, ok := follower.Next(agent[0], agent[1], target[0], target[1])
+            _, cost, reachable := FindPath(space, agent[0], agent[1], target[0], target[1])
+            if reachable && agent != target {
+                assert.True(t, ok, "tick %d", tick)
+                assert.InDelta(t, cost, routeCost(follower.Path), 1e-9, "tick %d", tick)
+            }
+            if tick%2 == 0 && len(follower.Path) > 1 {
+                agent = [2]int{follower.Path[1].X, follower.Path[1].Y}
+            }
+            if tick%3 == 0 {
+                target = chaseStep(space, rng, target)
+            }
+        }
+        assert.Equal(t, 1, follower.Searches)
+        assert.Greater(t, follower.Repairs, 0)
+    })
+
+    t.Run("Route ends next to a blocked target", func(t *testing.T) {
+        space := resolv.NewSpace(10*gamemap.TileSize, 10*gamemap.TileSize, gamemap.TileSize, gamemap.TileSize)
+        addObstacle(space, 5, 5, "goblin_den", "mountain")
+        follower := NewPathFollower(space, NewChanges())
+
+        x, y := 1, 1
+        for i := 0; i < 10; i++ {
+            nx, ny, ok := follower.Next(x, y, 5, 5)
+            if !ok {
+                break
+            }
+            x, y = nx, ny
+        }
+        assert.Equal(t, [2]int{4, 4}, [2]int{x, y})
+    })
+}
+
+// chaseTicks is the number of ticks simulated by the chase benchmarks. Like an NPC, the
+// agent asks for its next cell every tick and moves a cell every few ticks, while the
+// chased monster wanders to a neighbor cell more slowly.
+const chaseTicks = 256
+
+func benchmarkChase(b *testing.B, space *resolv.Space, next func(agent, target [2]int) ([2]int, bool)) {
+    b.ResetTimer()
+    for i := 0; i < b.N; i++ {
+        rng := rand.New(rand.NewSource(3))
+        cells := freeCells(space, rng, 2)
+        agent, target := cells[0], cells[1]
+        for tick := 0; tick < chaseTicks; tick++ {
+            step, ok := next(agent, target)
+            if ok && tick%8 == 0 {
+                agent = step
+            }
+            if tick%16 == 0 {
+                target = chaseStep(space, rng, target)
+            }
+        }
+    }
+}
+
+func BenchmarkChaseAStar(b *testing.B) {
+    space, _ := mapSpace(b, "../map/map1.txt")
+    benchmarkChase(b, space, func(agent, target [2]int) ([2]int, bool) {
+        path, _, found := FindPath(space, agent[0], agent[1], target[0], target[1])
+        if !found || len(path) < 2 {
+            return agent, false
+        }
+        step := path[len(path)-2].(PathNode)
+        return [2]int{step.X, step.Y}, true
+    })
+}
+
+func BenchmarkChasePathFollower(b *testing.B) {
+    space, _ := mapSpace(b, "../map/map1.txt")
+    follower := NewPathFollower(space, NewChanges())
+    benchmarkChase(b, space, func(agent, target [2]int) ([2]int, bool) {
+        x, y, ok := follower.Next(agent[0], agent[1], target[0], target[1])
+        return [2]int{x, y}, ok
+    })
+}
@@ -0,0 +1,427 @@
+package pathfinding
+
+import (
+    "container/heap"
+    gamemap "example.com/maj/map"
+    "github.com/solarlune/resolv"
+    "image"
+    "math"
+    "slices"
+    "sort"
+)
+
+// DefaultClusterSize is the width and height in cells of the clusters of a Hierarchy
+const DefaultClusterSize = 10
+
+// maxEntranceWidth is the length of an open border run from which on it gets an entrance
+// at both ends instead of a single one in the middle
+const maxEntranceWidth = 6
+
+// Hierarchy is an HPA* abstraction of a space for long routes on large maps.
+// The grid is split into square clusters. Cells on both sides of open runs along cluster
+// borders become entrances, and the costs between the entrances of a cluster are
+// precomputed. Long queries are searched on this small graph of entrances and then
+// refined inside each cluster they cross.
+type Hierarchy struct {
+    ClusterSize int
+
+    space *resolv.Space
+    grid
+    changes    *Changes
+    version    int
+    cols, rows int
+    clusters   []cluster
+    // links are the steps between entrances of neighboring clusters
+    links map[int][]abstractEdge
+}
+
+// cluster is a square of cells with its entrances in cell index order
+type cluster struct {
+    x0, y0, x1, y1 int
+    entrances      []int
+    // costs holds the route cost between every two entrances inside the cluster,
+    // infinite when they are not connected within it
+    costs [][]float64
+}
+
+type abstractEdge struct {
+    to   int
+    cost float64
+}
+
+// NewHierarchy builds the abstraction of a space with clusters of the given size. Sync
+// rescans the cells marked in changes, or the whole space when changes is nil.
+func NewHierarchy(space *resolv.Space, changes *Changes, clusterSize int) *Hierarchy {
+    h := &Hierarchy{ClusterSize: clusterSize, space: space, changes: changes}
+    if changes != nil {
+        h.version = changes.Version()
+    }
+    h.build(newGrid(space))
+    return h
+}
+
+// Sync rescans the changed cells and recomputes the clusters they are in, and the
+// neighboring clusters whose entrances changed. It reports whether anything changed
+// since the last scan.
+func (h *Hierarchy) Sync() bool {
+    cells, ok := []image.Point(nil), false
+    if h.changes != nil {
+        cells, ok = h.changes.Since(h.version)
+        h.version = h.changes.Version()
+    }
+    if !ok {
+        g := newGrid(h.space)
+        if g.equal(h.grid) {
+            return false
+        }
+        h.build(g)
+        return true
+    }
+    changed := h.changed(h.space, cells)
+    if len(changed) == 0 {
+        return false
+    }
+    dirty := make([]bool, len(h.clusters))
+    for _, cell := range changed {
+        i := h.index(cell.X, cell.Y)
+        h.blocked[i], h.cost[i] = scanCell(h.space, cell.X, cell.Y)
+        dirty[h.clusterOf(i)] = true
+    }
+    h.update(dirty)
+    return true
+}
+
+// build splits a grid into clusters and connects all of them
+func (h *Hierarchy) build(g grid) {
+    h.grid = g
+    size := h.ClusterSize
+    h.cols = (g.width + size - 1) / size
+    h.rows = (g.height + size - 1) / size
+    h.clusters = make([]cluster, h.cols*h.rows)
+    for cy := 0; cy < h.rows; cy++ {
+        for cx := 0; cx < h.cols; cx++ {
+            h.clusters[cy*h.cols+cx] = cluster{
+                x0: cx * size, y0: cy * size,
+                x1: min(cx*size+size, g.width), y1: min(cy*size+size, g.height),
+            }
+        }
+    }
+    h.links = make(map[int][]abstractEdge)
+    dirty := make([]bool, len(h.clusters))
+    for i := range dirty {
+        dirty[i] = true
+    }
+    h.update(dirty)
+}
+
+// update scans the borders of the dirty clusters for entrances again. The dirty clusters
+// and their neighbors whose entrances changed then have their costs recomputed.
+func (h *Hierarchy) update(dirty []bool) {
+    // Every cluster owns the borders to its right and below it
+    right := make([]bool, len(h.clusters))
+    below := make([]bool, len(h.clusters))
+    touched := make([]bool, len(h.clusters))
+    for i, d := range dirty {
+        if !d {
+            continue
+        }
+        cx, cy := i%h.cols, i/h.cols
+        right[i], below[i], touched[i] = true, true, true
+        if cx > 0 {
+            right[i-1], touched[i-1] = true, true
+        }
+        if cx < h.cols-1 {
+            touched[i+1] = true
+        }
+        if cy > 0 {
+            below[i-h.cols], touched[i-h.cols] = true, true
+        }
+        if cy < h.rows-1 {
+            touched[i+h.cols] = true
+        }
+    }
+
+    for i := range h.clusters {
+        c := &h.clusters[i]
+        if right[i] && c.x1 < h.width {
+            h.scanBorder(c.y1-c.y0, func(k int) (int, int) {
+                return h.index(c.x1-1, c.y0+k), h.index(c.x1, c.y0+k)
+            })
+        }
+        if below[i] && c.y1 < h.height {
+            h.scanBorder(c.x1-c.x0, func(k int) (int, int) {
+                return h.index(c.x0+k, c.y1-1), h.index(c.x0+k, c.y1)
+            })
+        }
+    }
+
+    for i := range h.clusters {
+        if !touched[i] {
+            continue
+        }
+        c := &h.clusters[i]
+        entrances := h.entrances(c)
+        if !dirty[i] && slices.Equal(c.entrances, entrances) {
+            continue
+        }
+        c.entrances = entrances
+        h.connect(c)
+    }
+}
+
+// entrances returns the border cells of a cluster linked to a neighboring cluster, in cell index order
+func (h *Hierarchy) entrances(c *cluster) []int {
+    var entrances []int
+    for y := c.y0; y < c.y1; y++ {
+        for x := c.x0; x < c.x1; x++ {
+            if y != c.y0 && y != c.y1-1 && x != c.x0 && x != c.x1-1 {
+                // Only the first and last cell of the inner rows are on the border
+                x = c.x1 - 2
+                continue
+            }
+            if len(h.links[h.index(x, y)]) > 0 {
+                entrances = append(entrances, h.index(x, y))
+            }
+        }
+    }
+    return entrances
+}
+
+// scanBorder replaces the links across a border with one for every run of border cell
+// pairs that are free on both sides
+func (h *Hierarchy) scanBorder(length int, pair func(k int) (int, int)) {
+    for k := 0; k < length; k++ {
+        a, b := pair(k)
+        h.unlink(a, b)
+        h.unlink(b, a)
+    }
+    first := -1
+    for k := 0; k <= length; k++ {
+        open := false
+        if k < length {
+            a, b := pair(k)
+            open = !h.blocked[a] && !h.blocked[b]
+        }
+        switch {
+        case open && first < 0:
+            first = k
+        case !open && first >= 0:
+            last := k - 1
+            if last-first+1 < maxEntranceWidth {
+                h.link(pair((first + last) / 2))
+            } else {
+                h.link(pair(first))
+                h.link(pair(last))
+            }
+            first = -1
+        }
+    }
+}
+
+// unlink removes the link from a border cell to its neighbor across the border
+func (h *Hierarchy) unlink(from, to int) {
+    edges := slices.DeleteFunc(h.links[from], func(e abstractEdge) bool { return e.to == to })
+    if len(edges) == 0 {
+        delete(h.links, from)
+        return
+    }
+    h.links[from] = edges
+}
+
+// link connects two neighboring border cells as entrances of their clusters
+func (h *Hierarchy) link(a, b int) {
+    cost := (h.cost[a] + h.cost[b]) / 2
+    h.links[a] = append(h.links[a], abstractEdge{to: b, cost: cost})
+    h.links[b] = append(h.links[b], abstractEdge{to: a, cost: cost})
+}
+
+// connect computes the costs between the entrances of a cluster
+func (h *Hierarchy) connect(c *cluster) {
+    c.costs = make([][]float64, len(c.entrances))
+    for i, entrance := range c.entrances {
+        distance, _ := h.search(c, entrance)
+        c.costs[i] = make([]float64, len(c.entrances))
+        for j, other := range c.entrances {
+            c.costs[i][j] = distance[c.local(h.grid, other)]
+        }
+    }
+}
+
+func (h *Hierarchy) clusterOf(i int) int {
+    x, y := i%h.width, i/h.width
+    return (y/h.ClusterSize)*h.cols + x/h.ClusterSize
+}
+
+// local is the index of a cell within the cluster bounds
+func (c *cluster) local(g grid, i int) int {
+    return (i/g.width-c.y0)*(c.x1-c.x0) + i%g.width - c.x0
+}
+
+func (c *cluster) contains(x, y int) bool {
+    return x >= c.x0 && y >= c.y0 && x < c.x1 && y < c.y1
+}
+
+// search runs Dijkstra's algorithm from a root cell without leaving the cluster. It returns
+// the distance of every cell of the cluster to the root and the cell index of the next step
+// towards the root, both by local index. The root itself may be blocked.
+func (h *Hierarchy) search(c *cluster, root int) ([]float64, []int) {
+    n := (c.x1 - c.x0) * (c.y1 - c.y0)
+    distance := make([]float64, n)
+    next := make([]int, n)
+    for i := range distance {
+        distance[i] = math.Inf(1)
+        next[i] = -1
+    }
+    distance[c.local(h.grid, root)] = 0
+    open := &cellQueue{{index: root}}
+    for open.Len() > 0 {
+        current := heap.Pop(open).(queuedCell)
+        if current.distance > distance[c.local(h.grid, current.index)] {
+            continue
+        }
+        h.neighbors(current.index%h.width, current.index/h.width, func(nx, ny int, cost float64) {
+            if !c.contains(nx, ny) {
+                return
+            }
+            cell := h.index(nx, ny)
+            if d := current.distance + cost; d < distance[c.local(h.grid, cell)] {
+                distance[c.local(h.grid, cell)] = d
+                next[c.local(h.grid, cell)] = current.index
+                heap.Push(open, queuedCell{index: cell, distance: d})
+            }
+        })
+    }
+    return distance, next
+}
+
+// FindPath returns a route between two cells, start first, and its cost. Targets within
+// a cluster size are searched with FindPath on the full grid. Longer routes are searched on
+// the entrance graph and refined inside each cluster they cross; they are close to but not
+// always exactly the shortest, and may end at a blocked target, e.g. a den.
+func (h *Hierarchy) FindPath(startX, startY, endX, endY int) ([]PathNode, float64, bool) {
+    if !h.inside(startX, startY) || !h.inside(endX, endY) {
+        return nil, 0, false
+    }
+    if max(abs(endX-startX), abs(endY-startY)) <= h.ClusterSize {
+        path, cost, found := FindPath(h.space, startX, startY, endX, endY)
+        if !found {
+            return nil, 0, false
+        }
+        nodes := make([]PathNode, len(path))
+        for i, p := range path {
+            // go-astar returns the route from the end
+            nodes[len(path)-1-i] = p.(PathNode)
+        }
+        return nodes, cost, true
+    }
+
+    start, goal := h.index(startX, startY), h.index(endX, endY)
+    abstract, found := h.searchAbstract(start, goal)
+    if !found {
+        return nil, 0, false
+    }
+    return h.refine(abstract)
+}
+
+// searchAbstract runs A* over the entrances from the start cell to the goal cell and
+// returns the cells visited, start first
+func (h *Hierarchy) searchAbstract(start, goal int) ([]int, bool) {
+    startCluster, goalCluster := &h.clusters[h.clusterOf(start)], &h.clusters[h.clusterOf(goal)]
+    fromStart, _ := h.search(startCluster, start)
+    toGoal, _ := h.search(goalCluster, goal)
+
+    cost := map[int]float64{start: 0}
+    parent := make(map[int]int)
+    closed := make(map[int]bool)
+    open := &cellQueue{{index: start, distance: h.estimate(start, goal)}}
+    for open.Len() > 0 {
+        current := heap.Pop(open).(queuedCell).index
+        if closed[current] {
+            continue
+        }
+        closed[current] = true
+        if current == goal {
+            path := []int{goal}
+            for path[0] != start {
+                path = append([]int{parent[path[0]]}, path...)
+            }
+            return path, true
+        }
+
+        relax := func(next int, step float64) {
+            if closed[next] || math.IsInf(step, 1) {
+                return
+            }
+            if d, known := cost[next]; !known || cost[current]+step < d {
+                cost[next] = cost[current] + step
+                parent[next] = current
+                heap.Push(open, queuedCell{index: next, distance: cost[next] + h.estimate(next, goal)})
+            }
+        }
+        if current == start {
+            for _, entrance := range startCluster.entrances {
+                relax(entrance, fromStart[startCluster.local(h.grid, entrance)])
+            }
+        }
+        c := &h.clusters[h.clusterOf(current)]
+        if i := sort.SearchInts(c.entrances, current); i < len(c.entrances) && c.entrances[i] == current {
+            for j, other := range c.entrances {
+                relax(other, c.costs[i][j])
+            }
+            for _, edge := range h.links[current] {
+                relax(edge.to, edge.cost)
+            }
+            if c == goalCluster {
+                relax(goal, toGoal[c.local(h.grid, current)])
+            }
+        }
+    }
+    return nil, false
+}
+
+// refine expands the abstract route into cells. Steps between clusters are single cells,
+// steps inside a cluster are searched again within it.
+func (h *Hierarchy) refine(abstract []int) ([]PathNode, float64, bool) {
+    cells := []int{abstract[0]}
+    for k := 1; k < len(abstract); k++ {
+        from, to := abstract[k-1], abstract[k]
+        if h.clusterOf(from) != h.clusterOf(to) {
+            cells = append(cells, to)
+            continue
+        }
+        c := &h.clusters[h.clusterOf(to)]
+        _, next := h.search(c, to)
+        for cell := from; cell != to; {
+            cell = next[c.local(h.grid, cell)]
+            if cell < 0 {
+                return nil, 0, false
+            }
+            cells = append(cells, cell)
+        }
+    }
+
+    path := make([]PathNode, len(cells))
+    cost := 0.0
+    for i, cell := range cells {
+        path[i] = PathNode{X: cell % h.width, Y: cell / h.width, space: h.space}
+        if i > 0 {
+            base := 1.0
+            if path[i].X != path[i-1].X && path[i].Y != path[i-1].Y {
+                base = diagonalCost
+            }
+            cost += base * (h.cost[cells[i-1]] + h.cost[cell]) / 2
+        }
+    }
+    return path, cost, true
+}
+
+// estimate is the octile distance between two cells over the cheapest terrain
+func (h *Hierarchy) estimate(a, b int) float64 {
+    return octileDistance(abs(a%h.width-b%h.width), abs(a/h.width-b/h.width))
+}
+
+// octileDistance is the cost of the shortest route over dx and dy cells of the cheapest
+// terrain without obstacles, which never overestimates a real route
+func octileDistance(dx, dy int) float64 {
+    return (float64(max(dx, dy)) + (diagonalCost-1)*float64(min(dx, dy))) * gamemap.MinTerrainCost()
+}
@@ -0,0 +1,210 @@
+package pathfinding
+
+import (
+    gamemap "example.com/maj/map"
+    "github.com/solarlune/resolv"
+    "github.com/stretchr/testify/assert"
+    "math/rand"
+    "testing"
+)
+
+// randomSpace builds a square space with scattered mountains and a few long walls
+func randomSpace(size int, seed int64) *resolv.Space {
+    rng := rand.New(rand.NewSource(seed))
+    space := resolv.NewSpace(size*gamemap.TileSize, size*gamemap.TileSize, gamemap.TileSize, gamemap.TileSize)
+    for i := 0; i < size*size/8; i++ {
+        addObstacle(space, rng.Intn(size), rng.Intn(size), "mountain")
+    }
+    for i := 0; i < size/10; i++ {
+        x, y := rng.Intn(size), rng.Intn(size)
+        for k := 0; k < size/3 && x+k < size; k++ {
+            addObstacle(space, x+k, y, "mountain")
+        }
+    }
+    return space
+}
+
+// assertRoute checks that a route is made of allowed steps between free cells
+func assertRoute(t *testing.T, space *resolv.Space, path []PathNode, start, end [2]int) {
+    t.Helper()
+    if !assert.NotEmpty(t, path) {
+        return
+    }
+    assert.Equal(t, start, [2]int{path[0].X, path[0].Y})
+    assert.Equal(t, end, [2]int{path[len(path)-1].X, path[len(path)-1].Y})
+    for i := 1; i < len(path); i++ {
+        allowed := false
+        for _, n := range path[i-1].PathNeighbors() {
+            if n.(PathNode) == path[i] {
+                allowed = true
+            }
+        }
+        assert.True(t, allowed, "step %v -> %v", path[i-1], path[i])
+    }
+}
+
+func TestHierarchy(t *testing.T) {
+    t.Run("Routes are found like A* and stay close to the shortest", func(t *testing.T) {
+        mapped, _ := mapSpace(t, "../map/map1.txt")
+        for _, space := range []*resolv.Space{randomSpace(120, 1), mapped} {
+            h := NewHierarchy(space, nil, DefaultClusterSize)
+            rng := rand.New(rand.NewSource(4))
+            for i := 0; i < 20; i++ {
+                cells := freeCells(space, rng, 2)
+                start, end := cells[0], cells[1]
+                _, shortest, reachable := FindPath(space, start[0], start[1], end[0], end[1])
+                path, cost, found := h.FindPath(start[0], start[1], end[0], end[1])
+                assert.Equal(t, reachable, found, "%v -> %v", start, end)
+                if found {
+                    assertRoute(t, space, path, start, end)
+                    assert.InDelta(t, routeCost(path), cost, 1e-9)
+                    assert.LessOrEqual(t, cost, shortest*1.25+1e-9, "%v -> %v", start, end)
+                }
+            }
+        }
+    })
+
+    t.Run("Short routes are searched with A*", func(t *testing.T) {
+        space, _ := mapSpace(t, "../map/map1.txt")
+        h := NewHierarchy(space, nil, DefaultClusterSize)
+        rng := rand.New(rand.NewSource(5))
+        for found := 0; found < 10; {
+            start := freeCells(space, rng, 1)[0]
+            end := [2]int{start[0] + rng.Intn(11) - 5, start[1] + rng.Intn(11) - 5}
+            if _, shortest, ok := FindPath(space, start[0], start[1], end[0], end[1]); ok {
+                _, cost, _ := h.FindPath(start[0], start[1], end[0], end[1])
+                assert.InDelta(t, shortest, cost, 1e-9)
+                found++
+            }
+        }
+    })
+
+    t.Run("Sync rebuilds the clusters of new obstacles", func(t *testing.T) {
+        space := resolv.NewSpace(40*gamemap.TileSize, 40*gamemap.TileSize, gamemap.TileSize, gamemap.TileSize)
+        changes := NewChanges()
+        h := NewHierarchy(space, changes, DefaultClusterSize)
+        _, before, _ := h.FindPath(0, 20, 39, 20)
+        assert.InDelta(t, 39, before, 1e-9)
+        assert.False(t, h.Sync())
+
+        for y := 5; y < 40; y++ {
+            changes.MarkObject(space, addObstacle(space, 25, y, "mountain"))
+        }
+        assert.True(t, h.Sync())
+        path, after, found := h.FindPath(0, 20, 39, 20)
+        assert.True(t, found)
+        assertRoute(t, space, path, [2]int{0, 20}, [2]int{39, 20})
+        assert.Greater(t, after, before)
+        assert.False(t, h.Sync())
+    })
+
+    t.Run("Syncing changed cells gives the same clusters as a rebuild", func(t *testing.T) {
+        space := randomSpace(60, 5)
+        changes := NewChanges()
+        h := NewHierarchy(space, changes, DefaultClusterSize)
+        rng := rand.New(rand.NewSource(5))
+        var added []*resolv.Object
+        for i := 0; i < 40; i++ {
+            // Cells on cluster borders and corners change entrances of several clusters
+            x, y := rng.Intn(6)*10+rng.Intn(2)*9, rng.Intn(60)
+            if space.Cell(x, y).ContainsTags(ObstacleTags...) {
+                continue
+            }
+            obj := addObstacle(space, x, y, "goblin_den", "mountain")
+            changes.MarkObject(space, obj)
+            added = append(added, obj)
+        }
+        assert.True(t, h.Sync())
+        assert.Equal(t, NewHierarchy(space, nil, DefaultClusterSize).clusters, h.clusters)
+
+        for _, obj := range added[:len(added)/2] {
+            space.Remove(obj)
+            changes.MarkObject(space, obj)
+        }
+        assert.True(t, h.Sync())
+        rebuilt := NewHierarchy(space, nil, DefaultClusterSize)
+        assert.Equal(t, rebuilt.clusters, h.clusters)
+        for _, r := range longRoutes(space) {
+            _, want, _ := rebuilt.FindPath(r[0][0], r[0][1], r[1][0], r[1][1])
+            _, got, _ := h.FindPath(r[0][0], r[0][1], r[1][0], r[1][1])
+            assert.InDelta(t, want, got, 1e-9)
+        }
+    })
+
+    t.Run("A follower uses the hierarchy for distant targets", func(t *testing.T) {
+        space := randomSpace(120, 2)
+        follower := NewPathFollower(space, NewChanges())
+        follower.Hierarchy = NewHierarchy(space, nil, DefaultClusterSize)
+        rng := rand.New(rand.NewSource(6))
+        var start, target [2]int
+        for {
+            cells := freeCells(space, rng, 2)
+            start, target = cells[0], cells[1]
+            _, _, ok := FindPath(space, start[0], start[1], target[0], target[1])
+            if ok && max(abs(start[0]-target[0]), abs(start[1]-target[1])) > 60 {
+                break
+            }
+        }
+
+        x, y := start[0], start[1]
+        for i := 0; i < 1000; i++ {
+            nx, ny, ok := follower.Next(x, y, target[0], target[1])
+            if !ok {
+                break
+            }
+            x, y = nx, ny
+        }
+        assert.Equal(t, target, [2]int{x, y})
+        // One route from the hierarchy and one D* Lite search near the target
+        assert.Equal(t, 2, follower.Searches)
+    })
+}
+
+// benchmarkRoutes is the number of long queries in the route benchmarks
+const benchmarkRoutes = 20
+
+func longRoutes(space *resolv.Space) [][2][2]int {
+    rng := rand.New(rand.NewSource(7))
+    var routes [][2][2]int
+    for len(routes) < benchmarkRoutes {
+        cells := freeCells(space, rng, 2)
+        if max(abs(cells[0][0]-cells[1][0]), abs(cells[0][1]-cells[1][1])) > space.Width()/2 {
+            routes = append(routes, [2][2]int{cells[0], cells[1]})
+        }
+    }
+    return routes
+}
+
+func BenchmarkLongRoutesAStar(b *testing.B) {
+    space := randomSpace(300, 3)
+    routes := longRoutes(space)
+
+    b.ResetTimer()
+    for i := 0; i < b.N; i++ {
+        for _, r := range routes {
+            FindPath(space, r[0][0], r[0][1], r[1][0], r[1][1])
+        }
+    }
+}
+
+func BenchmarkLongRoutesHierarchy(b *testing.B) {
+    space := randomSpace(300, 3)
+    routes := longRoutes(space)
+    h := NewHierarchy(space, nil, DefaultClusterSize)
+
+    b.ResetTimer()
+    for i := 0; i < b.N; i++ {
+        for _, r := range routes {
+            h.FindPath(r[0][0], r[0][1], r[1][0], r[1][1])
+        }
+    }
+}
+
+func BenchmarkHierarchyBuild(b *testing.B) {
+    space := randomSpace(300, 3)
+
+    b.ResetTimer()
+    for i := 0; i < b.N; i++ {
+        NewHierarchy(space, nil, DefaultClusterSize)
+    }
+}
@@ -1,6 +1,7 @@
 package pathfinding
 
 import (
+    gamemap "example.com/maj/map"
     "github.com/beefsack/go-astar"
     "github.com/solarlune/resolv"
     "math"
@@ -51,20 +52,22 @@ outer:
     return neighbors
 }
 
+// PathNeighborCost is the step length weighted by the terrain cost of both cells
 func (n PathNode) PathNeighborCost(to astar.Pather) float64 {
     toNode := to.(PathNode)
-    diagonal := toNode.X != n.X && toNode.Y != n.Y
-    if diagonal {
-        return 1.414
+    base := 1.0
+    if toNode.X != n.X && toNode.Y != n.Y {
+        base = diagonalCost
     }
-    return 1
+    return stepCost(base, TileAt(n.space, n.X, n.Y), TileAt(n.space, toNode.X, toNode.Y))
 }
 
+// PathEstimatedCost is the straight line distance scaled by the cheapest terrain
 func (n PathNode) PathEstimatedCost(to astar.Pather) float64 {
     toNode := to.(PathNode)
     dx := float64(abs(toNode.X - n.X))
     dy := float64(abs(toNode.Y - n.Y))
-    return math.Sqrt(dx*dx + dy*dy)
+    return math.Sqrt(dx*dx+dy*dy) * gamemap.MinTerrainCost()
 }
 
 func abs(x int) int {
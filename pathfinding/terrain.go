@@ -0,0 +1,45 @@
+package pathfinding
+
+import (
+    gamemap "example.com/maj/map"
+    "github.com/solarlune/resolv"
+)
+
+// TerrainTag tags the objects that mark the tile type of a cell. Their Data is a gamemap.TileType.
+// Cells without such an object are grass.
+const TerrainTag = "terrain"
+
+// NewTerrainObject creates the object marking the tile type of a cell
+func NewTerrainObject(x, y int, tile gamemap.TileType) *resolv.Object {
+    size := float64(gamemap.TileSize)
+    obj := resolv.NewObject(float64(x)*size, float64(y)*size, size, size)
+    obj.AddTags(TerrainTag)
+    obj.Data = tile
+    return obj
+}
+
+// TileAt returns the tile type of a cell of the space
+func TileAt(space *resolv.Space, x, y int) gamemap.TileType {
+    cell := space.Cell(x, y)
+    if cell == nil {
+        return gamemap.TileGrass
+    }
+    for _, obj := range cell.Objects {
+        if tile, ok := obj.Data.(gamemap.TileType); ok && obj.HasTags(TerrainTag) {
+            return tile
+        }
+    }
+    return gamemap.TileGrass
+}
+
+// TerrainAt returns the terrain under a position in world coordinates
+func TerrainAt(space *resolv.Space, position resolv.Vector) gamemap.Terrain {
+    x, y := space.WorldToSpace(position.X, position.Y)
+    return TileAt(space, x, y).Terrain()
+}
+
+// stepCost is the cost of a step between two neighbor cells. It averages the terrain of
+// both cells so that a step costs the same in both directions.
+func stepCost(base float64, from, to gamemap.TileType) float64 {
+    return base * (from.Terrain().Cost + to.Terrain().Cost) / 2
+}
@@ -0,0 +1,96 @@
+package pathfinding
+
+import (
+    gamemap "example.com/maj/map"
+    "github.com/solarlune/resolv"
+    "github.com/stretchr/testify/assert"
+    "testing"
+)
+
+// terrainSpace is an open 10x10 space with a road along row 2 and a swamp across row 5
+func terrainSpace() *resolv.Space {
+    space := resolv.NewSpace(10*gamemap.TileSize, 10*gamemap.TileSize, gamemap.TileSize, gamemap.TileSize)
+    for x := 0; x < 10; x++ {
+        space.Add(NewTerrainObject(x, 2, gamemap.TileRoad))
+        space.Add(NewTerrainObject(x, 5, gamemap.TileSwamp))
+    }
+    return space
+}
+
+// pathRows returns the rows a path visits
+func pathRows(path []PathNode) map[int]bool {
+    rows := make(map[int]bool)
+    for _, node := range path {
+        rows[node.Y] = true
+    }
+    return rows
+}
+
+func TestTerrain(t *testing.T) {
+    t.Run("Tile types are read from terrain objects", func(t *testing.T) {
+        space := terrainSpace()
+        assert.Equal(t, gamemap.TileRoad, TileAt(space, 3, 2))
+        assert.Equal(t, gamemap.TileSwamp, TileAt(space, 3, 5))
+        assert.Equal(t, gamemap.TileGrass, TileAt(space, 3, 3))
+        assert.Equal(t, gamemap.TileGrass, TileAt(space, -1, 3))
+    })
+
+    t.Run("Step costs average the terrain of both cells", func(t *testing.T) {
+        space := terrainSpace()
+        grass, road := PathNode{X: 3, Y: 3, space: space}, PathNode{X: 3, Y: 2, space: space}
+        assert.InDelta(t, 0.75, grass.PathNeighborCost(road), 1e-9)
+        assert.InDelta(t, 0.75, road.PathNeighborCost(grass), 1e-9)
+        assert.InDelta(t, 0.5, road.PathNeighborCost(PathNode{X: 4, Y: 2, space: space}), 1e-9)
+    })
+
+    t.Run("Routes prefer roads", func(t *testing.T) {
+        space := terrainSpace()
+        path, cost, found := FindPath(space, 0, 3, 9, 3)
+        assert.True(t, found)
+        nodes := make([]PathNode, len(path))
+        for i, p := range path {
+            nodes[i] = p.(PathNode)
+        }
+        assert.True(t, pathRows(nodes)[2], "route does not use the road")
+        assert.Less(t, cost, 9.0)
+
+        field := NewFlowField(space, 9, 3)
+        distance, _ := field.Distance(0, 3)
+        assert.InDelta(t, cost, distance, 1e-9)
+
+        follower := NewPathFollower(space, NewChanges())
+        follower.Next(0, 3, 9, 3)
+        assert.InDelta(t, cost, routeCost(follower.Path), 1e-9)
+    })
+
+    t.Run("Routes avoid swamps unless they must cross them", func(t *testing.T) {
+        space := terrainSpace()
+        follower := NewPathFollower(space, NewChanges())
+        follower.Next(0, 4, 9, 6)
+        swampCells := 0
+        for _, node := range follower.Path {
+            if node.Y == 5 {
+                swampCells++
+            }
+        }
+        assert.Equal(t, 1, swampCells)
+
+        follower.Next(0, 6, 9, 6)
+        assert.False(t, pathRows(follower.Path)[5], "route crosses the swamp")
+    })
+
+    t.Run("Terrain changes invalidate cached fields", func(t *testing.T) {
+        space := resolv.NewSpace(10*gamemap.TileSize, 10*gamemap.TileSize, gamemap.TileSize, gamemap.TileSize)
+        changes := NewChanges()
+        cache := NewFlowFields(space, changes)
+        d, _ := cache.Field(9, 0).Distance(0, 0)
+        assert.InDelta(t, 9, d, 1e-9)
+
+        water := NewTerrainObject(5, 0, gamemap.TileWater)
+        space.Add(water)
+        changes.MarkObject(space, water)
+        assert.True(t, cache.Sync())
+        d, _ = cache.Field(9, 0).Distance(0, 0)
+        assert.Greater(t, d, 9.0)
+    })
+}
@@ -0,0 +1,37 @@
+package sim
+
+import "time"
+
+// TicksPerSecond is the number of simulation ticks in one second of simulated time
+const TicksPerSecond = 60
+
+// Clock is the simulation clock. It only moves forward when Advance is called,
+// so pausing the simulation also pauses every timer that reads from it.
+type Clock struct {
+    tick uint64
+}
+
+// NewClock creates a clock starting at tick zero
+func NewClock() *Clock {
+    return &Clock{}
+}
+
+// NewClockAt creates a clock resuming from the given tick
+func NewClockAt(tick uint64) *Clock {
+    return &Clock{tick: tick}
+}
+
+// Advance moves the clock forward by one tick
+func (c *Clock) Advance() {
+    c.tick++
+}
+
+// Tick returns the number of ticks simulated so far
+func (c *Clock) Tick() uint64 {
+    return c.tick
+}
+
+// Now returns the simulated time elapsed since the clock started
+func (c *Clock) Now() time.Duration {
+    return time.Duration(c.tick) * time.Second / TicksPerSecond
+}
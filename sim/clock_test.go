@@ -0,0 +1,28 @@
+package sim
+
+import (
+    "github.com/stretchr/testify/assert"
+    "testing"
+    "time"
+)
+
+func TestClock(t *testing.T) {
+    t.Run("Time only passes when the clock advances", func(t *testing.T) {
+        clock := NewClock()
+        assert.Zero(t, clock.Now())
+        assert.Zero(t, clock.Now())
+        clock.Advance()
+        assert.Equal(t, uint64(1), clock.Tick())
+        assert.Equal(t, time.Second/TicksPerSecond, clock.Now())
+    })
+
+    t.Run("Ticks convert to simulated time at TicksPerSecond", func(t *testing.T) {
+        clock := NewClock()
+        for i := 0; i < TicksPerSecond; i++ {
+            clock.Advance()
+        }
+        assert.Equal(t, time.Second, clock.Now())
+        assert.Equal(t, 1500*time.Millisecond, NewClockAt(TicksPerSecond*3/2).Now())
+        assert.Equal(t, time.Minute, NewClockAt(60*TicksPerSecond).Now())
+    })
+}
@@ -0,0 +1,52 @@
+package sim
+
+import "math/rand"
+
+// NewRand creates the random source used by a simulation.
+// A given seed always produces the same sequence of values.
+func NewRand(seed int64) *rand.Rand {
+    return rand.New(rand.NewSource(seed))
+}
+
+// Source is a seeded random source that counts the values it produced, so a saved
+// simulation can resume its sequence. Random values built on it are the same as NewRand's.
+type Source struct {
+    src   rand.Source64
+    seed  int64
+    draws uint64
+}
+
+// NewSource creates a source for the sequence of a seed
+func NewSource(seed int64) *Source {
+    return &Source{src: rand.NewSource(seed).(rand.Source64), seed: seed}
+}
+
+func (s *Source) Int63() int64 {
+    s.draws++
+    return s.src.Int63()
+}
+
+func (s *Source) Uint64() uint64 {
+    s.draws++
+    return s.src.Uint64()
+}
+
+// Seed restarts the sequence of a seed
+func (s *Source) Seed(seed int64) {
+    s.src.Seed(seed)
+    s.seed = seed
+    s.draws = 0
+}
+
+// Seek moves to the position in the sequence after its first draws values
+func (s *Source) Seek(draws uint64) {
+    s.Seed(s.seed)
+    for s.draws < draws {
+        s.Int63()
+    }
+}
+
+// Draws returns the number of values produced since the sequence started
+func (s *Source) Draws() uint64 {
+    return s.draws
+}
@@ -0,0 +1,36 @@
+package sim
+
+import (
+    "github.com/stretchr/testify/assert"
+    "math/rand"
+    "testing"
+)
+
+func TestSource(t *testing.T) {
+    t.Run("Values are the same as NewRand's", func(t *testing.T) {
+        expected, r := NewRand(5), rand.New(NewSource(5))
+        for i := 0; i < 100; i++ {
+            assert.Equal(t, expected.Intn(1000), r.Intn(1000))
+            assert.Equal(t, expected.Float64(), r.Float64())
+            assert.Equal(t, expected.Uint64(), r.Uint64())
+        }
+    })
+
+    t.Run("Seeking resumes the sequence after the drawn values", func(t *testing.T) {
+        source := NewSource(5)
+        r := rand.New(source)
+        for i := 0; i < 50; i++ {
+            r.Intn(10)
+            r.Float64()
+        }
+        draws := source.Draws()
+        next := []float64{r.Float64(), r.Float64()}
+
+        resumed := NewSource(5)
+        resumed.Int63()
+        resumed.Seek(draws)
+        r = rand.New(resumed)
+        assert.Equal(t, next, []float64{r.Float64(), r.Float64()})
+        assert.Equal(t, draws+2, resumed.Draws())
+    })
+}
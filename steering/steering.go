@@ -0,0 +1,130 @@
+// Package steering picks the velocity an agent moves with each tick. It sits between path
+// following, which gives the velocity an agent would like to move with, and the move itself:
+// agents push apart when they overlap and choose among sampled velocities the one closest to
+// the preferred velocity that avoids colliding with their neighbors soon.
+package steering
+
+import (
+    "github.com/solarlune/resolv"
+    "math"
+    "math/rand"
+)
+
+// Agent is a moving body as seen by the steering of its neighbors
+type Agent struct {
+    // Position is the center of the agent
+    Position resolv.Vector
+    Velocity resolv.Vector
+    Radius   float64
+}
+
+// Config tunes the steering of an agent
+type Config struct {
+    // NeighborRadius is the distance up to which other agents are avoided
+    NeighborRadius float64
+    // TimeHorizon is how many ticks ahead collisions with neighbors are predicted
+    TimeHorizon float64
+    // Separation scales the push away from overlapping neighbors, relative to the agent's speed
+    Separation float64
+    // Avoidance weighs how strongly a predicted collision counts against a velocity
+    Avoidance float64
+    // Samples is the number of directions tried besides the preferred velocity
+    Samples int
+}
+
+// DefaultConfig returns the steering used by characters and monsters
+func DefaultConfig() Config {
+    return Config{
+        NeighborRadius: 3 * 32,
+        TimeHorizon:    30,
+        Separation:     1,
+        Avoidance:      30,
+        Samples:        16,
+    }
+}
+
+// Steer chooses the velocity of an agent that would like to move with the preferred
+// velocity, at most maxSpeed. Velocities for which free reports false, e.g. because they
+// walk into a wall, are never chosen; the result is zero when none is free.
+// The random source only breaks ties between agents standing on the same spot.
+func (cfg Config) Steer(self Agent, preferred resolv.Vector, maxSpeed float64, neighbors []Agent, free func(velocity resolv.Vector) bool, rng *rand.Rand) resolv.Vector {
+    desired := preferred.Add(separation(self, neighbors, rng).Scale(cfg.Separation * maxSpeed)).ClampMagnitude(maxSpeed)
+
+    best, bestPenalty := resolv.Vector{}, math.Inf(1)
+    try := func(v resolv.Vector) {
+        if v.Magnitude() > 0 && !free(v) {
+            return
+        }
+        penalty := v.Sub(desired).Magnitude()
+        if t := timeToCollision(self, v, neighbors); t < cfg.TimeHorizon {
+            penalty += cfg.Avoidance / math.Max(t, 0.1)
+        }
+        if penalty < bestPenalty {
+            best, bestPenalty = v, penalty
+        }
+    }
+
+    try(desired)
+    try(resolv.Vector{})
+    if len(neighbors) == 0 && bestPenalty == 0 {
+        return best
+    }
+    for i := 0; i < cfg.Samples; i++ {
+        angle := 2 * math.Pi * float64(i) / float64(cfg.Samples)
+        direction := resolv.NewVector(math.Cos(angle), math.Sin(angle))
+        try(direction.Scale(maxSpeed))
+        try(direction.Scale(maxSpeed / 2))
+    }
+    return best
+}
+
+// separation is the sum of pushes away from overlapping neighbors, each growing with the
+// overlap up to length one
+func separation(self Agent, neighbors []Agent, rng *rand.Rand) resolv.Vector {
+    push := resolv.Vector{}
+    for _, n := range neighbors {
+        offset := self.Position.Sub(n.Position)
+        distance := offset.Magnitude()
+        reach := self.Radius + n.Radius
+        if distance >= reach {
+            continue
+        }
+        direction := offset.Unit()
+        if distance == 0 {
+            angle := rng.Float64() * 2 * math.Pi
+            direction = resolv.NewVector(math.Cos(angle), math.Sin(angle))
+        }
+        push = push.Add(direction.Scale((reach - distance) / reach))
+    }
+    return push
+}
+
+// timeToCollision is the number of ticks until the agent moving with velocity v touches
+// one of its neighbors. Like in reciprocal velocity obstacles, each agent is assumed to
+// take half of the avoidance, so the agent's own change of velocity counts twice.
+// Neighbors that already overlap are left to the separation.
+func timeToCollision(self Agent, v resolv.Vector, neighbors []Agent) float64 {
+    earliest := math.Inf(1)
+    reciprocal := v.Scale(2).Sub(self.Velocity)
+    for _, n := range neighbors {
+        p := n.Position.Sub(self.Position)
+        r := self.Radius + n.Radius
+        c := p.Dot(p) - r*r
+        if c <= 0 {
+            continue
+        }
+        u := reciprocal.Sub(n.Velocity)
+        a, b := u.Dot(u), p.Dot(u)
+        if a == 0 || b <= 0 {
+            continue
+        }
+        discriminant := b*b - a*c
+        if discriminant < 0 {
+            continue
+        }
+        if t := (b - math.Sqrt(discriminant)) / a; t < earliest {
+            earliest = t
+        }
+    }
+    return earliest
+}
@@ -0,0 +1,50 @@
+package steering
+
+import (
+    "github.com/solarlune/resolv"
+    "github.com/stretchr/testify/assert"
+    "math/rand"
+    "testing"
+)
+
+func free(resolv.Vector) bool { return true }
+
+func TestSteer(t *testing.T) {
+    cfg := DefaultConfig()
+    rng := rand.New(rand.NewSource(1))
+
+    t.Run("Without neighbors the preferred velocity is kept", func(t *testing.T) {
+        self := Agent{Position: resolv.NewVector(100, 100), Radius: 16}
+        v := cfg.Steer(self, resolv.NewVector(2, 0), 2, nil, free, rng)
+        assert.Equal(t, resolv.NewVector(2, 0), v)
+    })
+
+    t.Run("Overlapping agents push apart", func(t *testing.T) {
+        self := Agent{Position: resolv.NewVector(100, 100), Radius: 16}
+        other := Agent{Position: resolv.NewVector(110, 100), Radius: 16}
+        v := cfg.Steer(self, resolv.Vector{}, 2, []Agent{other}, free, rng)
+        assert.Less(t, v.X, 0.0)
+    })
+
+    t.Run("Agents on the same spot push apart in some direction", func(t *testing.T) {
+        self := Agent{Position: resolv.NewVector(100, 100), Radius: 16}
+        v := cfg.Steer(self, resolv.Vector{}, 2, []Agent{self}, free, rng)
+        assert.InDelta(t, 2, v.Magnitude(), 1e-9)
+    })
+
+    t.Run("Agents heading into each other turn aside", func(t *testing.T) {
+        self := Agent{Position: resolv.NewVector(100, 100), Velocity: resolv.NewVector(2, 0), Radius: 16}
+        other := Agent{Position: resolv.NewVector(180, 100), Velocity: resolv.NewVector(-2, 0), Radius: 16}
+        v := cfg.Steer(self, resolv.NewVector(2, 0), 2, []Agent{other}, free, rng)
+        assert.NotEqual(t, 0.0, v.Y)
+        assert.Greater(t, v.X, 0.0)
+    })
+
+    t.Run("Blocked velocities are not chosen", func(t *testing.T) {
+        self := Agent{Position: resolv.NewVector(100, 100), Radius: 16}
+        other := Agent{Position: resolv.NewVector(100, 110), Radius: 16}
+        noUp := func(v resolv.Vector) bool { return v.Y >= 0 }
+        v := cfg.Steer(self, resolv.Vector{}, 2, []Agent{other}, noUp, rng)
+        assert.GreaterOrEqual(t, v.Y, 0.0)
+    })
+}
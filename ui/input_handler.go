@@ -1,6 +1,7 @@
-package game
+package ui
 
 import (
+    "example.com/maj/game"
     "github.com/hajimehoshi/ebiten/v2"
     "github.com/hajimehoshi/ebiten/v2/inpututil"
     "github.com/solarlune/resolv"
@@ -12,9 +13,9 @@ func NewInputHandler() *InputHandler {
     return &InputHandler{}
 }
 
-func (ih *InputHandler) HandleInput(world *World) {
+func (ih *InputHandler) HandleInput(world *game.World) {
     player := world.GetPlayerCharacter()
-    if player == nil {
+    if player == nil || !player.Alive() {
         return
     }
 
@@ -47,6 +48,6 @@ func (ih *InputHandler) HandleInput(world *World) {
 
     // Handle attack input
     if inpututil.IsKeyJustPressed(ebiten.KeyControl) {
-        player.Attack.TriggerAttack()
+        player.Attack.TriggerAttack(world.Clock.Now())
     }
 }
@@ -1,12 +1,16 @@
-package game
+package ui
 
 import (
+    "example.com/maj/events"
+    "example.com/maj/game"
     gamemap "example.com/maj/map"
     "example.com/maj/units"
+    "fmt"
     "github.com/hajimehoshi/ebiten/v2"
     "github.com/hajimehoshi/ebiten/v2/ebitenutil"
     "github.com/hajimehoshi/ebiten/v2/text"
     "github.com/hajimehoshi/ebiten/v2/vector"
+    "github.com/solarlune/resolv"
     "golang.org/x/image/font"
     "golang.org/x/image/font/gofont/goregular"
     "golang.org/x/image/font/opentype"
@@ -18,6 +22,23 @@ import (
 type Renderer struct {
     font    font.Face
     sprites Sprites
+
+    // ShowGoalScores draws each NPC's goal utilities next to it
+    ShowGoalScores bool
+
+    world   *game.World
+    unwatch func()
+    popups  []damagePopup
+}
+
+// popupTicks is how long a damage number stays on screen
+const popupTicks = 45
+
+// damagePopup is the damage a unit or den took, drawn rising above where it was hit
+type damagePopup struct {
+    position resolv.Vector
+    amount   int
+    tick     uint64
 }
 
 type Sprites struct {
@@ -47,7 +68,30 @@ func NewRenderer(sprites Sprites) *Renderer {
     }
 }
 
-func (r *Renderer) Render(screen *ebiten.Image, world *World, camera *Camera) {
+// Watch subscribes the renderer to the damage dealt in a world, which is shown as numbers
+// above the units that were hit. Watching another world ends the previous subscription.
+func (r *Renderer) Watch(world *game.World) {
+    if r.unwatch != nil {
+        r.unwatch()
+    }
+    r.world, r.popups = world, nil
+    r.unwatch = events.Subscribe(world.Events, func(e units.DamageDealt) {
+        var obj *resolv.Object
+        switch target := e.Target.(type) {
+        case *units.Character:
+            obj = target.Object
+        case *units.Monster:
+            obj = target.Object
+        case *units.GoblinDen:
+            obj = target.Object
+        default:
+            return
+        }
+        r.popups = append(r.popups, damagePopup{position: obj.Position, amount: e.Amount, tick: world.Clock.Tick()})
+    })
+}
+
+func (r *Renderer) Render(screen *ebiten.Image, world *game.World, camera *Camera) {
     // Clear the screen
     screen.Fill(color.RGBA{135, 206, 235, 255}) // Sky blue background
 
@@ -64,6 +108,9 @@ func (r *Renderer) Render(screen *ebiten.Image, world *World, camera *Camera) {
             character := obj.Data.(*units.Character)
             r.drawCharacter(screen, character, camera)
             r.drawViewField(screen, character, camera)
+            if r.ShowGoalScores {
+                r.drawGoalScores(screen, character, camera)
+            }
         case *units.Monster:
             monster := obj.Data.(*units.Monster)
             r.drawMonster(screen, monster, camera)
@@ -75,6 +122,25 @@ func (r *Renderer) Render(screen *ebiten.Image, world *World, camera *Camera) {
             r.drawMushroom(screen, mushroom, camera)
         }
     }
+
+    if world == r.world {
+        r.drawDamagePopups(screen, world.Clock.Tick(), camera)
+    }
+}
+
+// drawDamagePopups draws the recent damage numbers and forgets the expired ones
+func (r *Renderer) drawDamagePopups(screen *ebiten.Image, tick uint64, camera *Camera) {
+    live := r.popups[:0]
+    for _, p := range r.popups {
+        age := tick - p.tick
+        if age > popupTicks {
+            continue
+        }
+        live = append(live, p)
+        screenX, screenY := camera.WorldToScreen(p.position.X, p.position.Y)
+        text.Draw(screen, fmt.Sprintf("-%d", p.amount), r.font, int(screenX)+8, int(screenY)-20-int(age)/2, color.RGBA{255, 64, 64, 255})
+    }
+    r.popups = live
 }
 
 func (r *Renderer) drawSprite(screen *ebiten.Image, sheet *ebiten.Image, indexX, indexY int, x, y float64) {
@@ -111,6 +177,14 @@ func (r *Renderer) drawTile(screen *ebiten.Image, x, y int, tileType gamemap.Til
         ebitenutil.DrawRect(screen, screenX, screenY, float64(gamemap.TileSize), float64(gamemap.TileSize), color.RGBA{34, 139, 34, 255}) // Forest green
     case gamemap.TileMountain:
         ebitenutil.DrawRect(screen, screenX, screenY, float64(gamemap.TileSize), float64(gamemap.TileSize), color.RGBA{139, 69, 19, 255}) // Saddle brown
+    case gamemap.TileWater:
+        ebitenutil.DrawRect(screen, screenX, screenY, float64(gamemap.TileSize), float64(gamemap.TileSize), color.RGBA{30, 144, 255, 255}) // Dodger blue
+    case gamemap.TileSwamp:
+        ebitenutil.DrawRect(screen, screenX, screenY, float64(gamemap.TileSize), float64(gamemap.TileSize), color.RGBA{85, 107, 47, 255}) // Dark olive green
+    case gamemap.TileRoad:
+        ebitenutil.DrawRect(screen, screenX, screenY, float64(gamemap.TileSize), float64(gamemap.TileSize), color.RGBA{210, 180, 140, 255}) // Tan
+    case gamemap.TileForest:
+        ebitenutil.DrawRect(screen, screenX, screenY, float64(gamemap.TileSize), float64(gamemap.TileSize), color.RGBA{0, 100, 0, 255}) // Dark green
     }
 
     borderColor := color.RGBA{0, 0, 0, 255} // Black border
@@ -143,6 +217,24 @@ func (r *Renderer) drawCharacter(screen *ebiten.Image, char *units.Character, ca
     }
 }
 
+func (r *Renderer) drawGoalScores(screen *ebiten.Image, char *units.Character, camera *Camera) {
+    pos := char.Object.Position
+    screenX, screenY := camera.WorldToScreen(pos.X, pos.Y)
+
+    y := int(screenY) + int(char.Height) + 12
+    for _, goal := range char.GoalScores {
+        if goal.Score <= 0 {
+            continue
+        }
+        clr := color.RGBA{255, 255, 255, 255}
+        if goal.Name == char.Executor.Goal.Name {
+            clr = color.RGBA{255, 255, 0, 255}
+        }
+        text.Draw(screen, fmt.Sprintf("%s %.1f", goal.Name, goal.Score), r.font, int(screenX), y, clr)
+        y += 12
+    }
+}
+
 func (r *Renderer) drawMonster(screen *ebiten.Image, monster *units.Monster, camera *Camera) {
     pos := monster.Object.Position
     screenX, screenY := camera.WorldToScreen(pos.X, pos.Y)
@@ -152,6 +244,9 @@ func (r *Renderer) drawMonster(screen *ebiten.Image, monster *units.Monster, cam
 
     // Draw health bar
     r.drawHealthBar(screen, screenX, screenY-10, monster.Width, 5, monster.Health, monster.MaxHealth)
+
+    // Draw behavior state
+    text.Draw(screen, monster.State.String(), r.font, int(screenX), int(screenY)-15, color.White)
 }
 
 func (r *Renderer) drawHealthBar(screen *ebiten.Image, x, y, width, height float64, health, maxHealth int) {
@@ -187,5 +282,5 @@ func (r *Renderer) drawViewField(screen *ebiten.Image, character *units.Characte
         float32(checkSize), float32(checkSize),
         1,                          // Line width
         color.RGBA{0, 255, 0, 255}, // Solid green for the border
-        false) // Disable anti-aliasing for a crisp border
+        false)                      // Disable anti-aliasing for a crisp border
 }
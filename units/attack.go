@@ -2,10 +2,11 @@ package units
 
 import "time"
 
+// Attack timers are expressed in simulated time as returned by sim.Clock.Now
 type Attack struct {
     IsAttacking      bool
-    AttackTimer      time.Time
-    CooldownTimer    time.Time
+    AttackTimer      time.Duration
+    CooldownTimer    time.Duration
     Message          string
     Range            float64
     Damage           int
@@ -26,19 +27,19 @@ func NewAttack(atackRange float64) Attack {
     }
 }
 
-func (a *Attack) TriggerAttack() bool {
-    if time.Now().After(a.CooldownTimer) {
+func (a *Attack) TriggerAttack(now time.Duration) bool {
+    if now >= a.CooldownTimer {
         a.IsAttacking = true
-        a.AttackTimer = time.Now().Add(a.AttackDuration)
-        a.CooldownTimer = time.Now().Add(a.CooldownDuration)
+        a.AttackTimer = now + a.AttackDuration
+        a.CooldownTimer = now + a.CooldownDuration
         a.HasDealtDamage = false
         return true
     }
     return false
 }
 
-func (a *Attack) Update() {
-    if a.IsAttacking && time.Now().After(a.AttackTimer) {
+func (a *Attack) Update(now time.Duration) {
+    if a.IsAttacking && now > a.AttackTimer {
         a.IsAttacking = false
         a.HasDealtDamage = false // Reset this flag when attack ends
     }
@@ -0,0 +1,52 @@
+package units
+
+import (
+    "example.com/maj/sim"
+    "github.com/stretchr/testify/assert"
+    "testing"
+    "time"
+)
+
+// ticks returns the number of clock ticks in a simulated duration
+func ticks(d time.Duration) int {
+    return int(d * sim.TicksPerSecond / time.Second)
+}
+
+func TestAttackTimers(t *testing.T) {
+    t.Run("Timers do not expire while the clock is paused", func(t *testing.T) {
+        clock := sim.NewClock()
+        attack := NewAttack(32)
+        assert.True(t, attack.TriggerAttack(clock.Now()))
+        for i := 0; i < 1000; i++ {
+            attack.Update(clock.Now())
+            assert.False(t, attack.TriggerAttack(clock.Now()))
+        }
+        assert.True(t, attack.IsAttacking)
+    })
+
+    t.Run("The cooldown expires after exactly CooldownDuration", func(t *testing.T) {
+        clock := sim.NewClock()
+        attack := NewAttack(32)
+        assert.True(t, attack.TriggerAttack(clock.Now()))
+        for i := 1; i < ticks(attack.CooldownDuration); i++ {
+            clock.Advance()
+            assert.False(t, attack.TriggerAttack(clock.Now()), "tick %d", i)
+        }
+        clock.Advance()
+        assert.True(t, attack.TriggerAttack(clock.Now()))
+    })
+
+    t.Run("The attack ends once AttackDuration has passed", func(t *testing.T) {
+        clock := sim.NewClock()
+        attack := NewAttack(32)
+        attack.TriggerAttack(clock.Now())
+        for i := 0; i < ticks(attack.AttackDuration); i++ {
+            clock.Advance()
+            attack.Update(clock.Now())
+            assert.True(t, attack.IsAttacking, "tick %d", i+1)
+        }
+        clock.Advance()
+        attack.Update(clock.Now())
+        assert.False(t, attack.IsAttacking)
+    })
+}
@@ -2,38 +2,92 @@ package units
 
 import (
     "example.com/maj/ai"
-    "fmt"
+    "example.com/maj/events"
+    "example.com/maj/logging"
+    "example.com/maj/pathfinding"
+    "example.com/maj/sim"
+    "example.com/maj/steering"
     "github.com/solarlune/resolv"
+    "log/slog"
+    "math/rand"
     "time"
 )
 
 type Character struct {
-    Name          string
-    Speed         float64
-    IsPlayer      bool
-    Width         float64
-    Height        float64
-    Attack        Attack
-    Health        int
-    MaxHealth     int
-    Object        *resolv.Object
-    Planner       *ai.GOAPPlanner
-    CurrentPlan   []ai.GOAPAction
+    Name       string
+    Speed      float64
+    IsPlayer   bool
+    Width      float64
+    Height     float64
+    Attack     Attack
+    Health     int
+    MaxHealth  int
+    Object     *resolv.Object
+    Planner    *ai.GOAPPlanner
+    Behavior   *ai.Behavior
+    Executor   ai.PlanExecutor
+    Perception *Perception
+    FlowFields *pathfinding.FlowFields
+    Hierarchy  *pathfinding.Hierarchy
+    Changes    *pathfinding.Changes
+    Route      *pathfinding.PathFollower
+    Steering   steering.Config
+    Events     *events.Bus
+    Logger     *slog.Logger
+    // Velocity is the step the character moved with in its last move
+    Velocity      resolv.Vector
     TargetMonster *Monster
     WanderTarget  resolv.Vector
-    WanderTime    time.Time
+    WanderTime    time.Duration
+    // SpawnPoint is the position the character comes back to when it respawns
+    SpawnPoint resolv.Vector
+    Clock      *sim.Clock
+    Rand       *rand.Rand
+
+    // MushroomsEaten counts the mushrooms taken by this character
+    MushroomsEaten int
+    // GoalScores holds the utility of every goal from the last planning step, highest first
+    GoalScores []ai.ScoredGoal
+
+    // steeredAt is the clock tick of the last steered move
+    steeredAt uint64
+    // thought is what the NPC decided in its last think phase, carried out by Act
+    thought thought
+}
+
+// thought is the outcome of an NPC's think phase
+type thought struct {
+    // pending is set by Think and cleared by the Act that carries the thought out
+    pending bool
+    state   ai.GOAPState
+    planned bool
+    change  *PlanChanged
 }
 
+// NewCharacter creates an NPC, or the player when it is named "Player"
 func NewCharacter(x, y float64, name string) *Character {
+    return newCharacter(x, y, name, name == "Player")
+}
+
+// NewPlayer creates the character the player controls, whatever its name
+func NewPlayer(x, y float64, name string) *Character {
+    return newCharacter(x, y, name, true)
+}
+
+func newCharacter(x, y float64, name string, player bool) *Character {
     c := &Character{
-        Name:      name,
-        Speed:     2.0,
-        IsPlayer:  name == "Player",
-        Width:     float64(32),
-        Height:    float64(32),
-        Attack:    NewAttack(2 * 32),
-        Health:    100,
-        MaxHealth: 100,
+        Name:       name,
+        Speed:      2.0,
+        IsPlayer:   player,
+        Width:      float64(32),
+        Height:     float64(32),
+        Attack:     NewAttack(2 * 32),
+        Health:     100,
+        MaxHealth:  100,
+        Clock:      sim.NewClock(),
+        Rand:       sim.NewRand(1),
+        Perception: NewPerception(6*32, time.Second*20),
+        Steering:   steering.DefaultConfig(),
     }
     c.Object = resolv.NewObject(x, y, float64(32), float64(32))
     c.Object.SetShape(resolv.NewRectangle(0, 0, float64(32), float64(32)))
@@ -47,7 +101,7 @@ func NewCharacter(x, y float64, name string) *Character {
 }
 
 func (c *Character) MoveToPoint(target resolv.Vector) bool {
-    if c.Object.Center().Distance(target) <= c.Speed {
+    if c.Object.Center().Distance(target) <= c.CurrentSpeed() {
         c.Object.Position = target.Sub(c.Object.Center().Sub(c.Object.Position))
         c.Object.Update()
         return true
@@ -57,28 +111,110 @@ func (c *Character) MoveToPoint(target resolv.Vector) bool {
     return c.Move(direction)
 }
 
+// CurrentSpeed is the character's speed on the terrain it stands on
+func (c *Character) CurrentSpeed() float64 {
+    return c.Speed * terrainSpeed(c.Object)
+}
+
 func (c *Character) Move(direction resolv.Vector) bool {
     direction = direction.Unit()
 
-    step := direction.Mult(resolv.NewVector(c.Speed, c.Speed))
-    if collision := c.Object.Check(step.X, step.Y, "mountain", "goblin_den"); collision == nil {
-        c.Object.Position = c.Object.Position.Add(step)
-        c.Object.Update()
-        return true
-    } else {
+    speed := c.CurrentSpeed()
+    return c.Steer(direction.Mult(resolv.NewVector(speed, speed)))
+}
+
+// Steer moves an NPC with a step close to the preferred one that keeps it clear of other
+// characters and monsters. The player moves exactly as requested. It reports false when
+// the character could not move although it wanted to.
+func (c *Character) Steer(preferred resolv.Vector) bool {
+    if !c.Alive() || c.Object.Space == nil {
         return false
     }
+    step := preferred
+    if !c.IsPlayer {
+        c.steeredAt = c.Clock.Tick()
+        step = steer(c.Steering, c.Object, c.Velocity, preferred, c.CurrentSpeed(), c.Rand, "mountain", "goblin_den")
+    }
+    if collision := c.Object.Check(step.X, step.Y, "mountain", "goblin_den"); collision != nil || step.IsZero() {
+        c.Velocity = resolv.Vector{}
+        return preferred.IsZero()
+    }
+    c.Velocity = step
+    c.Object.Position = c.Object.Position.Add(step)
+    c.Object.Update()
+    return true
+}
+
+// keepApart lets an NPC that did not move this tick step away from units overlapping it
+func (c *Character) keepApart() {
+    if c.Health > 0 && c.steeredAt != c.Clock.Tick() {
+        c.Steer(resolv.Vector{})
+    }
 }
 
-func (c *Character) TakeDamage(amount int) {
-    c.Health -= amount
-    if c.Health < 0 {
-        c.Health = 0
+// TakeDamage lowers the character's health and publishes who dealt the damage.
+// Dead characters take no more damage.
+func (c *Character) TakeDamage(source any, amount int) {
+    if c.Health <= 0 {
+        return
     }
+    lost := takeDamage(&c.Health, amount)
+    c.Events.Publish(DamageDealt{Source: source, Target: c, Amount: lost, Remaining: c.Health})
 }
 
+// Alive reports whether the character has health left
+func (c *Character) Alive() bool {
+    return c.Health > 0
+}
+
+// Respawn brings a dead character back to full health at its spawn point. NPCs drop
+// their plan, target and memories. The caller adds the object back to the space.
+func (c *Character) Respawn() {
+    c.Executor.Abort(c)
+    c.thought = thought{}
+    c.GoalScores = nil
+    c.Health = c.MaxHealth
+    c.Attack.IsAttacking = false
+    c.Attack.HasDealtDamage = false
+    c.TargetMonster = nil
+    c.WanderTime = 0
+    c.Route = nil
+    c.Velocity = resolv.Vector{}
+    if c.Perception != nil {
+        c.Perception.Memory = nil
+    }
+    c.Object.Position = c.SpawnPoint
+    c.Object.Update()
+}
+
+// Update thinks and acts for one tick
 func (c *Character) Update() {
-    c.Attack.Update()
+    c.Think()
+    c.Act()
+}
+
+// Think lets an NPC sense the world and update its plan. It only reads the space and
+// changes the NPC itself, so NPCs can think in parallel while nothing moves.
+// The player does not think.
+func (c *Character) Think() {
+    if c.IsPlayer || !c.Alive() {
+        return
+    }
+    state := c.UpdateGOAPState()
+    planned, change := c.planGOAP(state)
+    c.thought = thought{pending: true, state: state, planned: planned, change: change}
+}
+
+// Act carries out the character's decisions for one tick: the player attacks as requested
+// and NPCs run their current action, thinking first unless they already did
+func (c *Character) Act() {
+    if !c.Alive() {
+        return
+    }
+    if !c.IsPlayer && !c.thought.pending {
+        c.Think()
+    }
+    c.Attack.Update(c.Clock.Now())
 
     if c.IsPlayer {
         // Player update logic (controlled by input)
@@ -87,21 +223,29 @@ func (c *Character) Update() {
             c.Attack.HasDealtDamage = true
         }
     } else {
-        // NPC update logic using GOAP
-        currentState := c.UpdateGOAPState()
-        goalState := c.GenerateGOAPGoal(currentState)
-
-        fmt.Println(currentState, goalState)
-        c.CurrentPlan = c.Planner.Plan(currentState, goalState)
-        if c.CurrentPlan == nil {
-            return
-        }
+        c.actNPC()
+        c.keepApart()
+    }
+}
 
-        action := c.CurrentPlan[0]
-        fmt.Println(c.Name, action)
-        c.ExecuteGOAPAction(action)
-        c.CurrentPlan = c.CurrentPlan[1:]
+// actNPC publishes the plan change of the last think phase and runs the current GOAP action
+func (c *Character) actNPC() {
+    c.thought.pending = false
+    if change := c.thought.change; change != nil {
+        c.log(slog.LevelDebug, logging.GOAP, "plan changed", "goal", change.Goal, "plan", change.Plan)
+        c.Events.Publish(*change)
+        c.thought.change = nil
+    }
+    if !c.thought.planned {
+        c.log(slog.LevelDebug, logging.Decision, "decision", "state", c.thought.state, "goal", "")
+        return
     }
+
+    goal := c.Executor.Goal.Name
+    action, _ := c.Executor.Current()
+    status := c.Executor.Tick(c)
+    c.log(slog.LevelDebug, logging.Decision, "decision", "state", c.thought.state, "goal", goal,
+        "action", action.Name, "status", status.String())
 }
 
 func (c *Character) PerformAttack() {
@@ -123,27 +267,32 @@ func (c *Character) PerformAttack() {
             switch {
             case obj.HasTags("monster"):
                 if monster, ok := obj.Data.(*Monster); ok {
-                    monster.TakeDamage(c.Attack.Damage)
+                    monster.TakeDamage(c, c.Attack.Damage)
                 }
             case obj.HasTags("goblin_den"):
                 if den, ok := obj.Data.(*GoblinDen); ok {
-                    den.TakeDamage(c.Attack.Damage)
+                    den.TakeDamage(c, c.Attack.Damage)
                 }
             }
         }
     }
 }
 
+// Take eats the mushrooms the character touches: they heal it and leave the space
 func (c *Character) Take() {
     collisions := c.Object.Check(0, 0, "mushroom")
-    if collisions != nil {
-        for _, obj := range collisions.Objects {
-            switch {
-            case obj.HasTags("mushroom"):
-                c.Health = min(c.Health+20, 120)
-                obj.Space.Remove(obj)
-            }
+    if collisions == nil {
+        return
+    }
+    for _, obj := range collisions.Objects {
+        mushroom, ok := obj.Data.(*Mushroom)
+        if !ok || obj.Space == nil {
+            continue
         }
+        c.Health = min(c.Health+20, 120)
+        c.MushroomsEaten++
+        obj.Space.Remove(obj)
+        c.Events.Publish(ItemPicked{By: c, Item: mushroom})
     }
 }
 
@@ -0,0 +1,65 @@
+package units
+
+// The events below are published on the event bus of the units that cause them.
+// Entities are *Character, *Monster, *GoblinDen or *Mushroom values.
+
+// DamageDealt is published when a unit or den loses health
+type DamageDealt struct {
+    // Source is the unit that dealt the damage, nil when unknown
+    Source any
+    Target any
+    // Amount is the health actually lost and Remaining the health left afterwards
+    Amount    int
+    Remaining int
+}
+
+// EntitySpawned is published when an entity enters the world, including characters that respawn
+type EntitySpawned struct {
+    Entity any
+}
+
+// EntityDied is published when a unit or den reaches zero health
+type EntityDied struct {
+    Entity any
+    // Killer is the source of the fatal damage, nil when unknown
+    Killer any
+}
+
+// EntityDespawned is published when an entity leaves the world
+type EntityDespawned struct {
+    Entity any
+}
+
+// ItemPicked is published when a character took a mushroom out of its space
+type ItemPicked struct {
+    By   *Character
+    Item *Mushroom
+}
+
+// MonsterSpawned is published when a den added a monster to its space. The world lets
+// the monster use its pathfinding.
+type MonsterSpawned struct {
+    Den     *GoblinDen
+    Monster *Monster
+}
+
+// PlanChanged is published when an NPC switches to another goal or plan, or drops its plan.
+// Goal is empty when the NPC has no plan.
+type PlanChanged struct {
+    Character *Character
+    Goal      string
+    Plan      []string
+}
+
+// MonsterStateChanged is published when a monster switches its behavior state
+type MonsterStateChanged struct {
+    Monster  *Monster
+    From, To MonsterState
+}
+
+// takeDamage lowers health by amount down to zero and reports how much was actually lost
+func takeDamage(health *int, amount int) int {
+    before := *health
+    *health = max(*health-amount, 0)
+    return before - *health
+}
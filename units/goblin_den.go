@@ -1,6 +1,8 @@
 package units
 
 import (
+    "example.com/maj/events"
+    "example.com/maj/sim"
     "github.com/solarlune/resolv"
     "math"
     "math/rand"
@@ -10,22 +12,28 @@ import (
 type GoblinDen struct {
     Object          *resolv.Object
     SpawnCooldown   time.Duration
-    LastSpawnTime   time.Time
+    LastSpawnTime   time.Duration
     MaxMonsters     int
     CurrentMonsters int
     Health          int
     MaxHealth       int
+    Clock           *sim.Clock
+    Rand            *rand.Rand
+    // Events is the bus the den publishes to, passed on to the monsters it spawns
+    Events *events.Bus
 }
 
-func NewGoblinDen(space *resolv.Space, x, y float64) *GoblinDen {
+func NewGoblinDen(space *resolv.Space, clock *sim.Clock, rng *rand.Rand, x, y float64) *GoblinDen {
     cooldown := time.Second * 30
     den := &GoblinDen{
         SpawnCooldown:   cooldown,
-        LastSpawnTime:   time.Now().Add(-cooldown),
+        LastSpawnTime:   clock.Now() - cooldown,
         MaxMonsters:     5,
         CurrentMonsters: 0,
         Health:          100,
         MaxHealth:       100,
+        Clock:           clock,
+        Rand:            rng,
     }
     size := float64(32)
     den.Object = resolv.NewObject(x, y, size, size)
@@ -36,27 +44,35 @@ func NewGoblinDen(space *resolv.Space, x, y float64) *GoblinDen {
     return den
 }
 
-func (d *GoblinDen) Update() []*Monster {
-    if time.Since(d.LastSpawnTime) >= d.SpawnCooldown && d.CurrentMonsters < d.MaxMonsters {
-        monster := d.SpawnMonster()
-        d.LastSpawnTime = time.Now()
-        d.CurrentMonsters++
-        return []*Monster{monster}
+// Update spawns a monster into the den's space once the cooldown has passed and the den
+// is not full, and publishes it in a MonsterSpawned event. It returns the spawned
+// monster, nil when none was spawned.
+func (d *GoblinDen) Update() *Monster {
+    if d.Object.Space == nil || d.Clock.Now()-d.LastSpawnTime < d.SpawnCooldown || d.CurrentMonsters >= d.MaxMonsters {
+        return nil
     }
-    return nil
+    monster := d.SpawnMonster()
+    d.Object.Space.Add(monster.Object)
+    d.LastSpawnTime = d.Clock.Now()
+    d.CurrentMonsters++
+    d.Events.Publish(MonsterSpawned{Den: d, Monster: monster})
+    return monster
 }
 
 func (d *GoblinDen) SpawnMonster() *Monster {
     spawnRadius := float64(32 * 2)
-    angle := rand.Float64() * 2 * math.Pi
+    angle := d.Rand.Float64() * 2 * math.Pi
     x := d.Object.Position.X + math.Cos(angle)*spawnRadius
     y := d.Object.Position.Y + math.Sin(angle)*spawnRadius
     return NewMonster(x, y, d)
 }
 
-func (d *GoblinDen) TakeDamage(amount int) {
-    d.Health -= amount
-    if d.Health < 0 {
-        d.Health = 0
+// TakeDamage lowers the den's health and publishes who dealt the damage.
+// Destroyed dens take no more damage.
+func (d *GoblinDen) TakeDamage(source any, amount int) {
+    if d.Health <= 0 {
+        return
     }
+    lost := takeDamage(&d.Health, amount)
+    d.Events.Publish(DamageDealt{Source: source, Target: d, Amount: lost, Remaining: d.Health})
 }
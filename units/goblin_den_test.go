@@ -0,0 +1,48 @@
+package units
+
+import (
+    "example.com/maj/sim"
+    "github.com/solarlune/resolv"
+    "github.com/stretchr/testify/assert"
+    "testing"
+    "time"
+)
+
+func TestUnitsWithoutAWorld(t *testing.T) {
+    t.Run("A den adds the monsters it spawns to its space", func(t *testing.T) {
+        space := resolv.NewSpace(1000, 1000, 32, 32)
+        clock := sim.NewClock()
+        den := NewGoblinDen(space, clock, sim.NewRand(1), 320, 320)
+        den.MaxMonsters = 2
+        den.SpawnCooldown = time.Second
+
+        var spawned []*Monster
+        for i := 0; i < 5*sim.TicksPerSecond; i++ {
+            clock.Advance()
+            if monster := den.Update(); monster != nil {
+                spawned = append(spawned, monster)
+            }
+        }
+        if !assert.Len(t, spawned, 2) {
+            return
+        }
+        assert.Equal(t, 2, den.CurrentMonsters)
+        for _, monster := range spawned {
+            assert.Same(t, space, monster.Object.Space)
+        }
+    })
+
+    t.Run("An eaten mushroom leaves the space and heals once", func(t *testing.T) {
+        space := resolv.NewSpace(1000, 1000, 32, 32)
+        char := NewCharacter(64, 64, "Eater")
+        space.Add(char.Object)
+        char.Health = 50
+        mushroom := NewMushroom(space, 64, 64)
+
+        char.Take()
+        char.Take()
+        assert.Nil(t, mushroom.Object.Space)
+        assert.Equal(t, 70, char.Health)
+        assert.Equal(t, 1, char.MushroomsEaten)
+    })
+}
@@ -2,6 +2,7 @@ package units
 
 import (
     gamemap "example.com/maj/map"
+    "example.com/maj/pathfinding"
     "github.com/solarlune/resolv"
     "math"
 )
@@ -44,23 +45,31 @@ func FindNearest(source *resolv.Object, distance float64, tags ...string) (*reso
 }
 
 func FindSafePoint(source *resolv.Object) resolv.Vector {
-    monsters := FindAll(source, 6*32, "monster")
-    if len(monsters) == 0 {
-        return source.Center()
+    var threats []resolv.Vector
+    for _, monster := range FindAll(source, 6*32, "monster") {
+        threats = append(threats, monster.Position)
+    }
+    return SafePointFrom(source.Center(), threats)
+}
+
+// SafePointFrom returns a point 5 tiles away from center, opposite to the average threat position
+func SafePointFrom(center resolv.Vector, threats []resolv.Vector) resolv.Vector {
+    if len(threats) == 0 {
+        return center
     }
 
     // Find the average position of all monsters
     var avgX, avgY float64
-    for _, monster := range monsters {
-        avgX += monster.Position.X
-        avgY += monster.Position.Y
+    for _, threat := range threats {
+        avgX += threat.X
+        avgY += threat.Y
     }
-    avgX /= float64(len(monsters))
-    avgY /= float64(len(monsters))
+    avgX /= float64(len(threats))
+    avgY /= float64(len(threats))
 
     // Move in the opposite direction of the average monster position
-    safeDirection := source.Center().Sub(resolv.NewVector(avgX, avgY)).Unit()
-    safePoint := source.Center().Add(safeDirection.Scale(5 * 32)) // Move 5 tiles away
+    safeDirection := center.Sub(resolv.NewVector(avgX, avgY)).Unit()
+    safePoint := center.Add(safeDirection.Scale(5 * 32)) // Move 5 tiles away
 
     // Ensure the safe point is within the world bounds
     //    safePoint.X = math.Max(0, math.Min(safePoint.X, float64(w.GameMap.Width*32)))
@@ -78,3 +87,11 @@ func CheckWorld(space *resolv.Space, x, y float64, w, h float64) []*resolv.Objec
     }
     return space.CheckWorld(x, y, w, h)
 }
+
+// terrainSpeed is the speed multiplier of the terrain under an object's center
+func terrainSpeed(obj *resolv.Object) float64 {
+    if obj.Space == nil {
+        return 1
+    }
+    return pathfinding.TerrainAt(obj.Space, obj.Center()).Speed
+}
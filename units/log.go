@@ -0,0 +1,16 @@
+package units
+
+import (
+    "example.com/maj/logging"
+    "log/slog"
+)
+
+// log writes a record about the character
+func (c *Character) log(level slog.Level, subsystem, msg string, args ...any) {
+    logging.Log(c.Logger, level, c.Clock.Tick(), c.Name, subsystem, msg, args...)
+}
+
+// log writes a record about the monster
+func (m *Monster) log(level slog.Level, subsystem, msg string, args ...any) {
+    logging.Log(m.Logger, level, m.Clock.Tick(), m.Name, subsystem, msg, args...)
+}
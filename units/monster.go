@@ -1,13 +1,21 @@
 package units
 
 import (
+    "example.com/maj/events"
+    "example.com/maj/logging"
+    "example.com/maj/pathfinding"
+    "example.com/maj/sim"
+    "example.com/maj/steering"
     "github.com/solarlune/resolv"
+    "log/slog"
     "math"
     "math/rand"
     "time"
 )
 
 type Monster struct {
+    // Name identifies the monster in the logs
+    Name          string
     Width, Height float64
     Speed         float64
     Direction     struct{ X, Y float64 }
@@ -17,22 +25,55 @@ type Monster struct {
     Object        *resolv.Object
     Den           *GoblinDen
     WanderRadius  float64
+    Clock         *sim.Clock
+    Rand          *rand.Rand
+    Behavior      *MonsterBehavior
+    Route         *pathfinding.PathFollower
+    Hierarchy     *pathfinding.Hierarchy
+    Changes       *pathfinding.Changes
+    Steering      steering.Config
+    Events        *events.Bus
+    Logger        *slog.Logger
+    // Velocity is the step the monster moved with in its last move
+    Velocity resolv.Vector
+
+    // State is the current behavior state and StateSince the time it was entered
+    State      MonsterState
+    StateSince time.Duration
+    // Target is the character the monster goes after, last seen at TargetPosition
+    Target         *Character
+    TargetPosition resolv.Vector
+    TargetLastSeen time.Duration
+
+    // steeredAt is the clock tick of the last steered move
+    steeredAt uint64
 }
 
 func NewMonster(x, y float64, den *GoblinDen) *Monster {
     attack := NewAttack(float64(32 * 1.5))
     attack.Damage = 10
     attack.CooldownDuration = time.Second * 2
+    clock, rng := sim.NewClock(), sim.NewRand(1)
+    var bus *events.Bus
+    if den != nil {
+        clock, rng, bus = den.Clock, den.Rand, den.Events
+    }
     m := &Monster{
         Width:        float64(32),
         Height:       float64(32),
         Speed:        1.0,
-        Direction:    struct{ X, Y float64 }{X: rand.Float64()*2 - 1, Y: rand.Float64()*2 - 1},
+        Direction:    struct{ X, Y float64 }{X: rng.Float64()*2 - 1, Y: rng.Float64()*2 - 1},
         Health:       100,
         MaxHealth:    100,
         Attack:       attack,
         Den:          den,
         WanderRadius: float64(32 * 5), // 5 tiles radius
+        Clock:        clock,
+        Rand:         rng,
+        Behavior:     NewMonsterBehavior(),
+        Steering:     steering.DefaultConfig(),
+        StateSince:   clock.Now(),
+        Events:       bus,
     }
     m.Object = resolv.NewObject(x, y, float64(32), float64(32))
     m.Object.SetShape(resolv.NewRectangle(0, 0, float64(32), float64(32)))
@@ -41,80 +82,181 @@ func NewMonster(x, y float64, den *GoblinDen) *Monster {
     return m
 }
 
+// Update senses the surroundings, takes the first transition that applies and acts
+// according to the resulting state
 func (m *Monster) Update() {
     if m.Health <= 0 {
         return
     }
 
-    nearestChar, distance := m.FindNearestCharacter()
-    if nearestChar != nil && distance <= m.Attack.Range {
-        m.AttackCharacter(nearestChar)
-    } else if nearestChar != nil && distance <= m.Attack.Range*4 {
-        m.MoveTowards(nearestChar.Object)
-    } else {
-        m.WanderNearDen()
+    senses := m.Sense()
+    for _, t := range m.Behavior.Transitions {
+        if t.appliesTo(m.State) && t.When(m, senses) {
+            m.SetState(t.To)
+            break
+        }
+    }
+
+    switch m.State {
+    case MonsterPatrol:
+        m.MoveRandomly()
+    case MonsterChase:
+        if senses.TargetVisible {
+            m.MoveTowards(m.Target.Object)
+        } else {
+            m.MoveAlongRoute(m.TargetPosition)
+        }
+    case MonsterAttack:
+        if m.Target != nil {
+            m.AttackCharacter(m.Target)
+        }
+    case MonsterReturn:
+        if m.Den != nil {
+            m.MoveTowards(m.Den.Object)
+        }
+    case MonsterFlee:
+        m.MoveAlongRoute(m.fleePoint())
+    }
+    if m.steeredAt != m.Clock.Tick() {
+        // Step away from units overlapping the monster while it stands still
+        m.TryMove(m.Object.Position.X, m.Object.Position.Y)
     }
 }
 
-func (m *Monster) FindNearestCharacter() (*Character, float64) {
-    nearestObj, distance := FindNearest(m.Object, 2*m.Attack.Range, "character")
-    if nearestObj != nil {
-        return nearestObj.Data.(*Character), distance
+// SetState switches the monster to a state
+func (m *Monster) SetState(state MonsterState) {
+    from := m.State
+    m.State = state
+    m.StateSince = m.Clock.Now()
+    if from != state {
+        m.log(slog.LevelDebug, logging.Monster, "state changed", "from", from.String(), "to", state.String())
+        m.Events.Publish(MonsterStateChanged{Monster: m, From: from, To: state})
     }
-    return nil, 0
 }
 
-func (m *Monster) WanderNearDen() {
-    denPos := m.Den.Object.Center()
-    monsterPos := m.Object.Center()
-    distanceToDen := monsterPos.Distance(denPos)
+// Sense looks for the nearest living character in sight, tracks it as the target and
+// returns what the monster knows for choosing its state
+func (m *Monster) Sense() MonsterSenses {
+    now := m.Clock.Now()
+    if nearest := m.FindNearestCharacter(); nearest != nil {
+        m.Target = nearest
+        m.TargetPosition = nearest.Object.Center()
+        m.TargetLastSeen = now
+    } else if m.Target != nil && m.Target.Health <= 0 {
+        m.Target = nil
+    }
 
-    if distanceToDen > m.WanderRadius {
-        // Move back towards den
-        m.MoveTowards(m.Den.Object)
-    } else {
-        // Wander randomly
-        m.MoveRandomly()
+    senses := MonsterSenses{
+        Health:  float64(m.Health) / float64(m.MaxHealth),
+        InState: now - m.StateSince,
+    }
+    center := m.Object.Center()
+    if m.Target != nil {
+        senses.HasTarget = true
+        senses.TargetLost = now - m.TargetLastSeen
+        senses.TargetVisible = senses.TargetLost == 0
+        senses.TargetDistance = center.Distance(m.TargetPosition)
     }
+    if m.Den != nil {
+        senses.DenDistance = center.Distance(m.Den.Object.Center())
+    }
+    return senses
+}
+
+// FindNearestCharacter returns the nearest living character within sight range and line of sight
+func (m *Monster) FindNearestCharacter() *Character {
+    center := m.Object.Center()
+    r := m.Behavior.SightRange
+    var nearest *Character
+    minDistance := r
+    for _, obj := range m.Object.Space.CheckWorld(center.X-r, center.Y-r, 2*r, 2*r, "character") {
+        char, ok := obj.Data.(*Character)
+        if !ok || char.Health <= 0 {
+            continue
+        }
+        distance := center.Distance(obj.Center())
+        if distance <= minDistance && LineOfSight(m.Object.Space, center, obj.Center(), m.Object, obj) {
+            nearest, minDistance = char, distance
+        }
+    }
+    return nearest
 }
 
 func (m *Monster) AttackCharacter(char *Character) {
-    m.Attack.TriggerAttack()
+    m.Attack.TriggerAttack(m.Clock.Now())
     if m.Attack.IsAttacking && !m.Attack.HasDealtDamage {
-        char.TakeDamage(m.Attack.Damage)
+        char.TakeDamage(m, m.Attack.Damage)
         m.Attack.HasDealtDamage = true
     }
 }
 
+// MoveTowards moves along the monster's route to an object
 func (m *Monster) MoveTowards(object *resolv.Object) {
-    position := m.Object.Position
-    distance := m.Object.Center().Distance(object.Center())
+    m.MoveAlongRoute(object.Center())
+}
 
-    if distance > 0 {
-        sub := object.Position.Sub(position)
-        m.Direction.X = sub.X / distance
-        m.Direction.Y = sub.Y / distance
+// MoveAlongRoute moves one step along the monster's cached route to a point.
+// The route is only searched again when the point changes cell or the route gets blocked.
+func (m *Monster) MoveAlongRoute(target resolv.Vector) {
+    space := m.Object.Space
+    if m.Route == nil || m.Route.Space() != space {
+        m.Route = pathfinding.NewPathFollower(space, m.Changes)
+        m.Route.Hierarchy = m.Hierarchy
     }
 
-    newX := position.X + m.Direction.X*m.Speed
-    newY := position.Y + m.Direction.Y*m.Speed
+    x, y := space.WorldToSpaceVec(m.Object.Center())
+    targetX, targetY := space.WorldToSpaceVec(target)
+    nextX, nextY, hasNext := m.Route.Next(x, y, targetX, targetY)
+    if len(m.Route.Path) == 0 {
+        return
+    }
+    m.MoveToPoint(routeWaypoint(m.Object, x, y, nextX, nextY, hasNext))
+}
+
+// MoveToPoint steps the monster's center straight towards a point without overshooting it
+func (m *Monster) MoveToPoint(point resolv.Vector) bool {
+    center := m.Object.Center()
+    distance := center.Distance(point)
+    if distance == 0 {
+        return true
+    }
+    m.Direction.X = (point.X - center.X) / distance
+    m.Direction.Y = (point.Y - center.Y) / distance
+    step := math.Min(m.Speed, distance)
+    return m.TryMove(m.Object.Position.X+m.Direction.X*step, m.Object.Position.Y+m.Direction.Y*step)
+}
 
-    m.TryMove(newX, newY)
+// fleePoint is a point away from the target, kept inside the space
+func (m *Monster) fleePoint() resolv.Vector {
+    point := SafePointFrom(m.Object.Center(), []resolv.Vector{m.TargetPosition})
+    space := m.Object.Space
+    maxX := float64(space.Width()*space.CellWidth) - 1
+    maxY := float64(space.Height()*space.CellHeight) - 1
+    point.X = math.Max(0, math.Min(point.X, maxX))
+    point.Y = math.Max(0, math.Min(point.Y, maxY))
+    return point
 }
 
+// TryMove steps towards a new position. The step is scaled by the terrain speed of the
+// monster's cell, so it ends short of the position on slow terrain and past it on roads,
+// and steered to keep clear of other characters and monsters. It reports false when the
+// monster could not move although it wanted to.
 func (m *Monster) TryMove(newX, newY float64) bool {
     position := m.Object.Position
-    dx := newX - position.X
-    dy := newY - position.Y
-
-    collision := m.Object.Check(dx, dy, "mountain")
-    if collision == nil {
-        m.Object.Position.X = newX
-        m.Object.Position.Y = newY
-        m.Object.Update()
-        return true
+    speed := terrainSpeed(m.Object)
+    preferred := resolv.NewVector((newX-position.X)*speed, (newY-position.Y)*speed)
+    maxSpeed := math.Max(m.Speed*speed, preferred.Magnitude())
+    m.steeredAt = m.Clock.Tick()
+    step := steer(m.Steering, m.Object, m.Velocity, preferred, maxSpeed, m.Rand, "mountain")
+
+    if collision := m.Object.Check(step.X, step.Y, "mountain"); collision != nil || step.IsZero() {
+        m.Velocity = resolv.Vector{}
+        return preferred.IsZero()
     }
-    return false
+    m.Velocity = step
+    m.Object.Position = position.Add(step)
+    m.Object.Update()
+    return true
 }
 
 func (m *Monster) MoveRandomly() {
@@ -124,8 +266,8 @@ func (m *Monster) MoveRandomly() {
 
     if !m.TryMove(newX, newY) {
         // Change direction if hit an obstacle
-        m.Direction.X = rand.Float64()*2 - 1
-        m.Direction.Y = rand.Float64()*2 - 1
+        m.Direction.X = m.Rand.Float64()*2 - 1
+        m.Direction.Y = m.Rand.Float64()*2 - 1
         m.NormalizeDirection()
     }
 }
@@ -138,9 +280,12 @@ func (m *Monster) NormalizeDirection() {
     }
 }
 
-func (m *Monster) TakeDamage(amount int) {
-    m.Health -= amount
-    if m.Health < 0 {
-        m.Health = 0
+// TakeDamage lowers the monster's health and publishes who dealt the damage.
+// Dead monsters take no more damage.
+func (m *Monster) TakeDamage(source any, amount int) {
+    if m.Health <= 0 {
+        return
     }
+    lost := takeDamage(&m.Health, amount)
+    m.Events.Publish(DamageDealt{Source: source, Target: m, Amount: lost, Remaining: m.Health})
 }
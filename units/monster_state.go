@@ -0,0 +1,201 @@
+package units
+
+import (
+    "fmt"
+    "time"
+)
+
+// MonsterState is the behavior a monster is currently in
+type MonsterState int
+
+const (
+    MonsterIdle MonsterState = iota
+    MonsterPatrol
+    MonsterChase
+    MonsterAttack
+    MonsterReturn
+    MonsterFlee
+)
+
+var monsterStateNames = []string{"idle", "patrol", "chase", "attack", "return", "flee"}
+
+func (s MonsterState) String() string {
+    if s >= 0 && int(s) < len(monsterStateNames) {
+        return monsterStateNames[s]
+    }
+    return fmt.Sprintf("MonsterState(%d)", int(s))
+}
+
+// MarshalText saves states by name
+func (s MonsterState) MarshalText() ([]byte, error) {
+    if s < 0 || int(s) >= len(monsterStateNames) {
+        return nil, fmt.Errorf("unknown monster state %d", int(s))
+    }
+    return []byte(s.String()), nil
+}
+
+// UnmarshalText reads a state saved by MarshalText
+func (s *MonsterState) UnmarshalText(text []byte) error {
+    for i, name := range monsterStateNames {
+        if name == string(text) {
+            *s = MonsterState(i)
+            return nil
+        }
+    }
+    return fmt.Errorf("unknown monster state %q", text)
+}
+
+// MonsterSenses is what a monster knows about its surroundings when choosing a state
+type MonsterSenses struct {
+    // HasTarget is true while the monster tracks a living character
+    HasTarget bool
+    // TargetVisible is true when the target is in sight this tick
+    TargetVisible bool
+    // TargetDistance is the distance to the target, or to where it was last seen
+    TargetDistance float64
+    // TargetLost is how long the target has been out of sight, zero while it is visible
+    TargetLost time.Duration
+    // DenDistance is the distance to the monster's den, zero for monsters without a den
+    DenDistance float64
+    // Health is the fraction of health left
+    Health float64
+    // InState is how long the monster has been in its current state
+    InState time.Duration
+}
+
+// MonsterTransition switches a monster from one of the From states, or from any state
+// when From is empty, to the To state when When holds
+type MonsterTransition struct {
+    From []MonsterState
+    To   MonsterState
+    When func(m *Monster, s MonsterSenses) bool
+}
+
+func (t MonsterTransition) appliesTo(state MonsterState) bool {
+    if t.To == state {
+        return false
+    }
+    if len(t.From) == 0 {
+        return true
+    }
+    for _, from := range t.From {
+        if from == state {
+            return true
+        }
+    }
+    return false
+}
+
+// MonsterBehavior configures how monsters pick their state.
+// Transitions are checked in order every tick and the first that applies is taken.
+type MonsterBehavior struct {
+    // SightRange is how far a monster notices characters in line of sight
+    SightRange float64
+    // LeashRadius is how far from its den a monster chases before it returns
+    LeashRadius float64
+    // GiveUpAfter is how long a monster looks for a target it lost sight of
+    GiveUpAfter time.Duration
+    // FleeHealth is the fraction of health below which a monster flees from its target
+    FleeHealth float64
+    // IdleTime and PatrolTime are how long a monster rests and wanders near its den in turns
+    IdleTime, PatrolTime time.Duration
+    Transitions          []MonsterTransition
+}
+
+// NewMonsterBehavior returns the default monster behavior
+func NewMonsterBehavior() *MonsterBehavior {
+    return &MonsterBehavior{
+        SightRange:  float64(32 * 6),
+        LeashRadius: float64(32 * 15),
+        GiveUpAfter: time.Second * 5,
+        FleeHealth:  0.2,
+        IdleTime:    time.Second * 2,
+        PatrolTime:  time.Second * 6,
+        Transitions: DefaultMonsterTransitions(),
+    }
+}
+
+// DefaultMonsterTransitions are the transitions of the default monster behavior
+func DefaultMonsterTransitions() []MonsterTransition {
+    inAttackRange := func(m *Monster, s MonsterSenses) bool {
+        return s.TargetVisible && s.TargetDistance <= m.Attack.Range
+    }
+    return []MonsterTransition{
+        {
+            From: []MonsterState{MonsterIdle, MonsterPatrol, MonsterChase, MonsterAttack, MonsterReturn},
+            To:   MonsterFlee,
+            When: func(m *Monster, s MonsterSenses) bool {
+                return s.TargetVisible && s.Health <= m.Behavior.FleeHealth
+            },
+        },
+        {
+            From: []MonsterState{MonsterFlee},
+            To:   MonsterReturn,
+            When: func(m *Monster, s MonsterSenses) bool {
+                return !s.HasTarget || s.TargetLost >= m.Behavior.GiveUpAfter
+            },
+        },
+        {
+            From: []MonsterState{MonsterIdle, MonsterPatrol, MonsterChase, MonsterReturn},
+            To:   MonsterAttack,
+            When: inAttackRange,
+        },
+        {
+            From: []MonsterState{MonsterAttack},
+            To:   MonsterChase,
+            When: func(m *Monster, s MonsterSenses) bool {
+                return !inAttackRange(m, s)
+            },
+        },
+        {
+            From: []MonsterState{MonsterIdle, MonsterPatrol},
+            To:   MonsterChase,
+            When: func(m *Monster, s MonsterSenses) bool {
+                return s.TargetVisible
+            },
+        },
+        {
+            // Monsters on their way back only turn around for targets close to the den
+            From: []MonsterState{MonsterReturn},
+            To:   MonsterChase,
+            When: func(m *Monster, s MonsterSenses) bool {
+                return s.TargetVisible && s.DenDistance <= m.WanderRadius
+            },
+        },
+        {
+            From: []MonsterState{MonsterChase},
+            To:   MonsterReturn,
+            When: func(m *Monster, s MonsterSenses) bool {
+                return !s.HasTarget || s.TargetLost >= m.Behavior.GiveUpAfter || s.DenDistance > m.Behavior.LeashRadius
+            },
+        },
+        {
+            From: []MonsterState{MonsterIdle, MonsterPatrol},
+            To:   MonsterReturn,
+            When: func(m *Monster, s MonsterSenses) bool {
+                return s.DenDistance > m.WanderRadius
+            },
+        },
+        {
+            From: []MonsterState{MonsterReturn},
+            To:   MonsterIdle,
+            When: func(m *Monster, s MonsterSenses) bool {
+                return s.DenDistance <= m.WanderRadius/2
+            },
+        },
+        {
+            From: []MonsterState{MonsterIdle},
+            To:   MonsterPatrol,
+            When: func(m *Monster, s MonsterSenses) bool {
+                return s.InState >= m.Behavior.IdleTime
+            },
+        },
+        {
+            From: []MonsterState{MonsterPatrol},
+            To:   MonsterIdle,
+            When: func(m *Monster, s MonsterSenses) bool {
+                return s.InState >= m.Behavior.PatrolTime
+            },
+        },
+    }
+}
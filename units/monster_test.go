@@ -0,0 +1,114 @@
+package units
+
+import (
+    "example.com/maj/events"
+    "example.com/maj/sim"
+    "github.com/solarlune/resolv"
+    "github.com/stretchr/testify/assert"
+    "testing"
+    "time"
+)
+
+// initMonster creates a space with a monster without a den and a character
+func initMonster(mx, my, cx, cy float64) (*resolv.Space, *Monster, *Character) {
+    space := resolv.NewSpace(1000, 1000, 32, 32)
+    monster := NewMonster(mx, my, nil)
+    space.Add(monster.Object)
+    char := NewCharacter(cx, cy, "Target")
+    space.Add(char.Object)
+    return space, monster, char
+}
+
+func TestMonsterStates(t *testing.T) {
+    t.Run("A visible character in range is attacked", func(t *testing.T) {
+        _, monster, char := initMonster(64, 64, 96, 64)
+        monster.Update()
+        assert.Equal(t, MonsterAttack, monster.State)
+        assert.Same(t, char, monster.Target)
+        assert.Less(t, char.Health, char.MaxHealth)
+    })
+
+    t.Run("State changes and attacks are published", func(t *testing.T) {
+        _, monster, char := initMonster(64, 64, 96, 64)
+        bus := events.NewBus()
+        monster.Events, char.Events = bus, bus
+        var published []any
+        bus.SubscribeAll(func(e any) { published = append(published, e) })
+
+        monster.Update()
+        assert.Equal(t, []any{
+            MonsterStateChanged{Monster: monster, From: MonsterIdle, To: MonsterAttack},
+            DamageDealt{Source: monster, Target: char, Amount: monster.Attack.Damage, Remaining: char.MaxHealth - monster.Attack.Damage},
+        }, published)
+    })
+
+    t.Run("The chase goes around mountains", func(t *testing.T) {
+        space, monster, char := initMonster(64, 64, 288, 64)
+        for y := 0.0; y < 6*32; y += 32 {
+            NewMountain(space, 160, y)
+        }
+        monster.Behavior.GiveUpAfter = time.Minute
+        monster.Target = char
+        monster.TargetPosition = char.Object.Center()
+        monster.SetState(MonsterChase)
+
+        for i := 0; i < 1000 && monster.State != MonsterAttack; i++ {
+            monster.Clock.Advance()
+            monster.Update()
+        }
+        assert.Equal(t, MonsterAttack, monster.State)
+    })
+
+    t.Run("The chase is given up after losing the target", func(t *testing.T) {
+        space := resolv.NewSpace(1000, 1000, 32, 32)
+        den := NewGoblinDen(space, sim.NewClock(), sim.NewRand(1), 64, 64)
+        monster := NewMonster(128, 64, den)
+        space.Add(monster.Object)
+        monster.Target = NewCharacter(800, 800, "Hidden")
+        monster.TargetPosition = monster.Target.Object.Center()
+        monster.SetState(MonsterChase)
+
+        for monster.State == MonsterChase {
+            monster.Clock.Advance()
+            monster.Update()
+        }
+        assert.Equal(t, MonsterReturn, monster.State)
+        assert.Equal(t, monster.Behavior.GiveUpAfter, monster.Clock.Now())
+    })
+
+    t.Run("A badly hurt monster flees", func(t *testing.T) {
+        _, monster, char := initMonster(160, 160, 224, 160)
+        monster.Health = 10
+        monster.Update()
+        assert.Equal(t, MonsterFlee, monster.State)
+
+        before := monster.Object.Center().Distance(char.Object.Center())
+        for i := 0; i < 30; i++ {
+            monster.Clock.Advance()
+            monster.Update()
+        }
+        assert.Greater(t, monster.Object.Center().Distance(char.Object.Center()), before)
+        assert.Equal(t, char.MaxHealth, char.Health)
+    })
+
+    t.Run("Transitions are configurable", func(t *testing.T) {
+        _, monster, _ := initMonster(64, 64, 900, 900)
+        monster.Behavior.Transitions = []MonsterTransition{{
+            To:   MonsterPatrol,
+            When: func(m *Monster, s MonsterSenses) bool { return !s.HasTarget },
+        }}
+        monster.Update()
+        assert.Equal(t, MonsterPatrol, monster.State)
+    })
+
+    t.Run("States are saved by name", func(t *testing.T) {
+        text, err := MonsterFlee.MarshalText()
+        assert.NoError(t, err)
+        assert.Equal(t, "flee", string(text))
+
+        var state MonsterState
+        assert.NoError(t, state.UnmarshalText([]byte("return")))
+        assert.Equal(t, MonsterReturn, state)
+        assert.Error(t, state.UnmarshalText([]byte("sleep")))
+    })
+}
@@ -1,12 +1,16 @@
 package units
 
 import (
+    "bytes"
+    _ "embed"
     "example.com/maj/ai"
     gamemap "example.com/maj/map"
     "example.com/maj/pathfinding"
+    "fmt"
     "github.com/solarlune/resolv"
     "math"
-    "math/rand"
+    "os"
+    "slices"
     "time"
 )
 
@@ -22,156 +26,319 @@ const (
     AttackDen       = "AttackDen"
 )
 
-func InitNPCGOAP(npc *Character) {
-    npc.Planner = ai.NewGOAPPlanner()
+//go:embed npc_behavior.json
+var defaultNPCBehavior []byte
+
+// NPCBehavior is the behavior given to NPCs created after it is set.
+// It defaults to the embedded npc_behavior.json.
+var NPCBehavior = mustLoadNPCBehavior(defaultNPCBehavior)
+
+// npcSensors computes the GOAP facts of an NPC from the world. Sensors and inputs run while
+// NPCs think in parallel: they may read the world but only change the NPC itself.
+var npcSensors = map[string]func(npc *Character) interface{}{
+    "lowHealth":        func(npc *Character) interface{} { return npc.Health < int(float32(npc.MaxHealth)*0.3) },
+    "hasFullHealth":    func(npc *Character) interface{} { return npc.Health == npc.MaxHealth },
+    "isHurt":           func(npc *Character) interface{} { return npc.Health < npc.MaxHealth },
+    "inDanger":         func(npc *Character) interface{} { return npc.IsInDanger() },
+    "hasTarget":        func(npc *Character) interface{} { return npc.HasTarget() },
+    "inAttackRange":    func(npc *Character) interface{} { return npc.IsInAttackRange() },
+    "monstersArround":  func(npc *Character) interface{} { return npc.IsMonstersArround() },
+    "mushroomNear":     func(npc *Character) interface{} { return npc.IsMushroomHere() },
+    "seeMushroom":      func(npc *Character) interface{} { return npc.IsMushroomNear() },
+    "denInAttackRange": func(npc *Character) interface{} { return npc.DenInAttackRange() },
+    "seeGoblinDen":     func(npc *Character) interface{} { return npc.seeGoblinDen() },
+}
 
-    // Run to safety action
-    npc.Planner.AddAction(ai.GOAPAction{
-        Name: RunToSafety,
-        CostFunc: func(state ai.GOAPState) float64 {
-            return 1
-        },
-        Preconditions: ai.GOAPState{"lowHealth": true, "monstersArround": true},
-        Effects:       ai.GOAPState{"inDanger": false},
-    })
+// NPCAction runs a GOAP action for an NPC over one or more ticks.
+// Start and Abort are optional. Start returning false fails the action before its first tick.
+// Abort may run while NPCs think in parallel, so it must only change the NPC itself.
+type NPCAction struct {
+    Start func(npc *Character) bool
+    Tick  func(npc *Character) ai.ActionStatus
+    Abort func(npc *Character)
+}
 
-    // Look for mushroom action
-    npc.Planner.AddAction(ai.GOAPAction{
-        Name: LookForMushroom,
-        CostFunc: func(state ai.GOAPState) float64 {
-            return 2
+// npcExecutors runs a GOAP action for an NPC, keyed by action name
+var npcExecutors = map[string]NPCAction{
+    RunToSafety: {
+        Tick: func(npc *Character) ai.ActionStatus {
+            if !npc.IsInDanger() {
+                return ai.ActionSucceeded
+            }
+            npc.RunToSafety()
+            return ai.ActionRunning
         },
-        Preconditions: ai.GOAPState{"seeMushroom": true},
-        Effects:       ai.GOAPState{"mushroomNear": true},
-    })
-    npc.Planner.AddAction(ai.GOAPAction{
-        Name: TakeMushroom,
-        CostFunc: func(state ai.GOAPState) float64 {
-            return 2
+    },
+    LookForMushroom: {
+        Start: (*Character).IsMushroomNear,
+        Tick: func(npc *Character) ai.ActionStatus {
+            if npc.IsMushroomHere() {
+                return ai.ActionSucceeded
+            }
+            if !npc.IsMushroomNear() {
+                return ai.ActionFailed
+            }
+            npc.LookForMushroom()
+            return ai.ActionRunning
         },
-        Preconditions: ai.GOAPState{"mushroomNear": true},
-        Effects:       ai.GOAPState{"hasFullHealth": true},
-    })
-
-    // Find monster action
-    npc.Planner.AddAction(ai.GOAPAction{
-        Name: FindMonster,
-        CostFunc: func(state ai.GOAPState) float64 {
-            return 3
+    },
+    TakeMushroom: {
+        Tick: func(npc *Character) ai.ActionStatus {
+            eaten := npc.MushroomsEaten
+            npc.Take()
+            if npc.MushroomsEaten == eaten {
+                return ai.ActionFailed
+            }
+            return ai.ActionSucceeded
         },
-        Preconditions: ai.GOAPState{"hasTarget": false, "monstersArround": true},
-        Effects:       ai.GOAPState{"hasTarget": true},
-    })
-
-    npc.Planner.AddAction(ai.GOAPAction{
-        Name: MoveToTarget,
-        CostFunc: func(state ai.GOAPState) float64 {
-            return 3
+    },
+    FindMonster: {
+        Tick: func(npc *Character) ai.ActionStatus {
+            npc.FindMonster()
+            if !npc.HasTarget() {
+                return ai.ActionFailed
+            }
+            return ai.ActionSucceeded
         },
-        Preconditions: ai.GOAPState{"hasTarget": true, "inAttackRange": false},
-        Effects:       ai.GOAPState{"inAttackRange": true},
-    })
-
-    // Attack monster action
-    npc.Planner.AddAction(ai.GOAPAction{
-        Name: AttackMonster,
-        CostFunc: func(state ai.GOAPState) float64 {
-            return 4
+    },
+    MoveToTarget: {
+        Start: (*Character).HasTarget,
+        Tick: func(npc *Character) ai.ActionStatus {
+            if !npc.targetAlive() || !npc.targetRemembered() {
+                return ai.ActionFailed
+            }
+            if npc.IsInAttackRange() {
+                return ai.ActionSucceeded
+            }
+            npc.MoveTowardsTarget()
+            return ai.ActionRunning
         },
-        Preconditions: ai.GOAPState{"hasTarget": true, "inAttackRange": true},
-        Effects:       ai.GOAPState{"hasDefeatedMonster": true},
-    })
-
-    npc.Planner.AddAction(ai.GOAPAction{
-        Name: MoveToDen,
-        CostFunc: func(state ai.GOAPState) float64 {
-            return 4
+    },
+    AttackMonster: {
+        Start: (*Character).HasTarget,
+        Tick: func(npc *Character) ai.ActionStatus {
+            if !npc.targetAlive() {
+                return ai.ActionSucceeded
+            }
+            if !npc.IsInAttackRange() {
+                return ai.ActionFailed
+            }
+            npc.AttackMonster()
+            return ai.ActionRunning
         },
-        Preconditions: ai.GOAPState{"seeGoblinDen": true},
-        Effects:       ai.GOAPState{"denInAttackRange": true},
-    })
-
-    npc.Planner.AddAction(ai.GOAPAction{
-        Name: AttackDen,
-        CostFunc: func(state ai.GOAPState) float64 {
-            return 4
+    },
+    Wander: {
+        Tick: func(npc *Character) ai.ActionStatus {
+            npc.Wander()
+            return ai.ActionRunning
         },
-        Preconditions: ai.GOAPState{"denInAttackRange": true},
-        Effects:       ai.GOAPState{"hasDefeatedDen": true},
-    })
-
-    npc.Planner.AddAction(ai.GOAPAction{
-        Name: Wander,
-        CostFunc: func(state ai.GOAPState) float64 {
-            return 6
+    },
+    MoveToDen: {
+        Start: (*Character).seeGoblinDen,
+        Tick: func(npc *Character) ai.ActionStatus {
+            if npc.DenInAttackRange() {
+                return ai.ActionSucceeded
+            }
+            if !npc.seeGoblinDen() {
+                return ai.ActionFailed
+            }
+            npc.MoveTowardsDen()
+            return ai.ActionRunning
+        },
+    },
+    AttackDen: {
+        Start: (*Character).DenInAttackRange,
+        Tick: func(npc *Character) ai.ActionStatus {
+            if !npc.DenInAttackRange() {
+                return ai.ActionSucceeded
+            }
+            npc.AttackDen()
+            return ai.ActionRunning
         },
-        Preconditions: ai.GOAPState{},
-        Effects:       ai.GOAPState{"monstersArround": true, "seeMushroom": true},
+    },
+}
+
+// npcInputs computes the consideration inputs used to score goals, in the 0..1 range
+var npcInputs = map[string]func(npc *Character) float64{
+    "healthRatio": func(npc *Character) float64 {
+        return math.Min(1, float64(npc.Health)/float64(npc.MaxHealth))
+    },
+    "missingHealth": func(npc *Character) float64 {
+        return math.Max(0, 1-float64(npc.Health)/float64(npc.MaxHealth))
+    },
+    "monsterProximity": func(npc *Character) float64 {
+        return npc.proximity("monster")
+    },
+    "denProximity": func(npc *Character) float64 {
+        return npc.proximity("goblin_den")
+    },
+}
+
+// proximity is 1 when a remembered object with the tag is at the NPC and falls to 0 at
+// sight range. Objects that are no longer visible count less as their memory fades.
+func (npc *Character) proximity(tag string) float64 {
+    p := npc.Perceive()
+    nearest, distance, ok := p.Nearest(npc.Object.Center(), tag)
+    if !ok {
+        return 0
+    }
+    return math.Max(0, 1-distance/p.SightRadius) * p.Confidence(nearest, npc.Clock.Now())
+}
+
+// RegisterNPCSensor adds a fact that behavior files can refer to
+func RegisterNPCSensor(fact string, sensor func(npc *Character) interface{}) {
+    npcSensors[fact] = sensor
+}
+
+// RegisterNPCInput adds a consideration input that behavior files can use to score goals
+func RegisterNPCInput(name string, input func(npc *Character) float64) {
+    npcInputs[name] = input
+}
+
+// RegisterNPCAction binds an action name used in behavior files to its executor
+func RegisterNPCAction(name string, action NPCAction) {
+    npcExecutors[name] = action
+}
+
+// LoadNPCBehavior reads a behavior definition and validates it against the registered sensors, inputs and actions
+func LoadNPCBehavior(data []byte) (*ai.Behavior, error) {
+    behavior, err := ai.ParseBehavior(bytes.NewReader(data))
+    if err != nil {
+        return nil, err
+    }
+    err = behavior.Validate(ai.Vocabulary{
+        IsSensed:    func(fact string) bool { return npcSensors[fact] != nil },
+        HasExecutor: func(action string) bool { return npcExecutors[action].Tick != nil },
+        HasInput:    func(input string) bool { return npcInputs[input] != nil },
     })
+    if err != nil {
+        return nil, err
+    }
+    return behavior, nil
+}
 
+// LoadNPCBehaviorFile reads and validates a behavior definition from a file
+func LoadNPCBehaviorFile(filename string) (*ai.Behavior, error) {
+    data, err := os.ReadFile(filename)
+    if err != nil {
+        return nil, err
+    }
+    behavior, err := LoadNPCBehavior(data)
+    if err != nil {
+        return nil, fmt.Errorf("%s: %w", filename, err)
+    }
+    return behavior, nil
 }
 
+func mustLoadNPCBehavior(data []byte) *ai.Behavior {
+    behavior, err := LoadNPCBehavior(data)
+    if err != nil {
+        panic(fmt.Sprintf("embedded NPC behavior: %v", err))
+    }
+    return behavior
+}
+
+func InitNPCGOAP(npc *Character) {
+    npc.Behavior = NPCBehavior
+    npc.Planner = NPCBehavior.NewPlanner()
+}
+
+// UpdateGOAPState refreshes the NPC's perception and derives the GOAP facts from it
 func (npc *Character) UpdateGOAPState() ai.GOAPState {
-    if npc.TargetMonster != nil && npc.TargetMonster.Object.Space == nil {
+    npc.Perceive()
+    if npc.TargetMonster != nil && !npc.targetRemembered() {
         npc.TargetMonster = nil
     }
 
-    state := ai.GOAPState{
-        "lowHealth":        npc.Health < int(float32(npc.MaxHealth)*0.3),
-        "hasFullHealth":    npc.Health == npc.MaxHealth,
-        "inDanger":         npc.IsInDanger(),
-        "hasTarget":        npc.HasTarget(),
-        "inAttackRange":    npc.IsInAttackRange(),
-        "monstersArround":  npc.IsMonstersArround(),
-        "mushroomNear":     npc.IsMushroomHere(),
-        "seeMushroom":      npc.IsMushroomNear(),
-        "denInAttackRange": npc.DenInAttackRange(),
-        "seeGoblinDen":     npc.seeGoblinDen(),
+    state := make(ai.GOAPState, len(npcSensors))
+    for fact, sensor := range npcSensors {
+        state[fact] = sensor(npc)
     }
     return state
 }
 
+// RankGOAPGoals scores the NPC's goals for the state and keeps the scores in GoalScores
+func (npc *Character) RankGOAPGoals(currentState ai.GOAPState) []ai.ScoredGoal {
+    npc.GoalScores = npc.Behavior.RankGoals(currentState, func(name string) float64 {
+        return npcInputs[name](npc)
+    })
+    return npc.GoalScores
+}
+
+// GenerateGOAPGoal returns the goal with the highest utility, or nil if none is relevant
 func (npc *Character) GenerateGOAPGoal(currentState ai.GOAPState) ai.GOAPState {
-    if currentState["lowHealth"].(bool) && currentState["monstersArround"].(bool) {
-        return ai.GOAPState{"inDanger": false}
-    } else if currentState["inAttackRange"].(bool) {
-        return ai.GOAPState{"hasDefeatedMonster": true}
-    } else if npc.Health < npc.MaxHealth {
-        return ai.GOAPState{"hasFullHealth": true}
-    } else if currentState["hasTarget"].(bool) {
-        return ai.GOAPState{"inAttackRange": true}
-    } else if currentState["monstersArround"].(bool) {
-        return ai.GOAPState{"hasTarget": true}
-    } else if currentState["denInAttackRange"].(bool) {
-        return ai.GOAPState{"hasDefeatedDen": true}
-    } else if currentState["seeGoblinDen"].(bool) {
-        return ai.GOAPState{"denInAttackRange": true}
-    } else {
-        return ai.GOAPState{"monstersArround": true}
-    }
-}
-
-func (npc *Character) ExecuteGOAPAction(action ai.GOAPAction) {
-    switch action.Name {
-    case RunToSafety:
-        npc.RunToSafety()
-    case LookForMushroom:
-        npc.LookForMushroom()
-    case TakeMushroom:
-        npc.Take()
-    case FindMonster:
-        npc.FindMonster()
-    case MoveToTarget:
-        npc.MoveTowards(npc.TargetMonster.Object.Center())
-    case AttackMonster:
-        npc.AttackMonster()
-    case Wander:
-        npc.Wander()
-    case MoveToDen:
-        npc.MoveTowardsDen()
-    case AttackDen:
-        npc.AttackDen()
+    ranked := npc.RankGOAPGoals(currentState)
+    if len(ranked) == 0 || ranked[0].Score <= 0 {
+        return nil
+    }
+    return ranked[0].State
+}
+
+// PlanGOAP keeps the current plan while it can still reach its goal and no more useful
+// goal has appeared. Otherwise it plans for the highest scoring goal that is not yet
+// satisfied and can be reached. It reports whether the NPC has a plan to execute.
+func (npc *Character) PlanGOAP(currentState ai.GOAPState) bool {
+    planned, change := npc.planGOAP(currentState)
+    if change != nil {
+        npc.Events.Publish(*change)
     }
+    return planned
+}
+
+// planGOAP is PlanGOAP without publishing, it returns the plan change to publish if any
+func (npc *Character) planGOAP(currentState ai.GOAPState) (bool, *PlanChanged) {
+    ranked := npc.RankGOAPGoals(currentState)
+    if npc.Executor.Refresh(currentState, npc) && !npc.Executor.Outranked(currentState, ranked) {
+        return true, nil
+    }
+    previousGoal, previousPlan := npc.Executor.Goal.Name, planNames(npc.Executor.Plan)
+    ok := npc.Executor.Replan(npc.Planner, currentState, ranked, npc)
+    if current := planNames(npc.Executor.Plan); npc.Executor.Goal.Name != previousGoal || !slices.Equal(current, previousPlan) {
+        return ok, &PlanChanged{Character: npc, Goal: npc.Executor.Goal.Name, Plan: current}
+    }
+    return ok, nil
+}
+
+// planNames returns the names of the actions of a plan
+func planNames(plan []ai.GOAPAction) []string {
+    names := make([]string, len(plan))
+    for i, action := range plan {
+        names[i] = action.Name
+    }
+    return names
+}
+
+// StartAction runs the Start hook of an action's executor
+func (npc *Character) StartAction(action ai.GOAPAction) bool {
+    executor, ok := npcExecutors[action.Name]
+    if !ok {
+        return false
+    }
+    return executor.Start == nil || executor.Start(npc)
+}
+
+// TickAction runs an action's executor for one tick
+func (npc *Character) TickAction(action ai.GOAPAction) ai.ActionStatus {
+    executor, ok := npcExecutors[action.Name]
+    if !ok {
+        return ai.ActionFailed
+    }
+    return executor.Tick(npc)
+}
+
+// AbortAction runs the Abort hook of an interrupted action's executor
+func (npc *Character) AbortAction(action ai.GOAPAction) {
+    if executor, ok := npcExecutors[action.Name]; ok && executor.Abort != nil {
+        executor.Abort(npc)
+    }
+}
+
+// ExecuteGOAPAction starts an action and runs it for a single tick outside of a plan
+func (npc *Character) ExecuteGOAPAction(action ai.GOAPAction) ai.ActionStatus {
+    if !npc.StartAction(action) {
+        return ai.ActionFailed
+    }
+    return npc.TickAction(action)
 }
 
 func (npc *Character) IsMushroomHere() bool {
@@ -179,24 +346,28 @@ func (npc *Character) IsMushroomHere() bool {
     return distance < 16
 }
 
+// IsMushroomNear reports whether the NPC remembers where a mushroom is
 func (npc *Character) IsMushroomNear() bool {
-    nearbyMonsters := FindAll(npc.Object, 6*32, "mushroom")
-    return len(nearbyMonsters) > 0
+    return len(npc.Perceive().Remembered("mushroom")) > 0
 }
 
 func (npc *Character) seeGoblinDen() bool {
-    nearbyMonsters := FindAll(npc.Object, 6*32, "goblin_den")
-    return len(nearbyMonsters) > 0
+    return len(npc.Perceive().Remembered("goblin_den")) > 0
 }
 
+// IsMonstersArround reports whether the NPC remembers a monster nearby
 func (npc *Character) IsMonstersArround() bool {
-    nearbyMonsters := FindAll(npc.Object, 6*32, "monster")
-    return len(nearbyMonsters) > 0
+    return len(npc.Perceive().Remembered("monster")) > 0
 }
 
+// IsInDanger reports whether the NPC can see a monster
 func (npc *Character) IsInDanger() bool {
-    nearbyMonsters := FindAll(npc.Object, 6*32, "monster")
-    return len(nearbyMonsters) > 0
+    for _, entry := range npc.Perceive().Remembered("monster") {
+        if entry.Visible {
+            return true
+        }
+    }
+    return false
 }
 
 func (npc *Character) HasTarget() bool {
@@ -204,6 +375,20 @@ func (npc *Character) HasTarget() bool {
     return npc.TargetMonster != nil
 }
 
+// targetAlive reports whether the target monster is still in the world
+func (npc *Character) targetAlive() bool {
+    return npc.TargetMonster != nil && npc.TargetMonster.Health > 0 && npc.TargetMonster.Object.Space != nil
+}
+
+// targetRemembered reports whether the NPC still knows where its target monster is
+func (npc *Character) targetRemembered() bool {
+    if npc.TargetMonster == nil {
+        return false
+    }
+    _, ok := npc.Perceive().Recall(npc.TargetMonster.Object)
+    return ok
+}
+
 func (npc *Character) IsInAttackRange() bool {
     if npc.TargetMonster == nil {
         return false
@@ -217,16 +402,20 @@ func (npc *Character) DenInAttackRange() bool {
     return distance <= npc.Attack.Range
 }
 
+// wanderAttempts is how many random directions a wandering NPC tries in a tick. Neighbors
+// can box an NPC in, so it may have to stand still for a tick.
+const wanderAttempts = 8
+
 func (npc *Character) Wander() {
     canMove := false
-    if npc.WanderTime.After(time.Now()) {
+    if npc.WanderTime > npc.Clock.Now() {
         canMove = npc.Move(npc.WanderTarget)
     }
 
-    for !canMove {
-        angle := rand.Float64() * 2 * math.Pi
+    for i := 0; !canMove && i < wanderAttempts; i++ {
+        angle := npc.Rand.Float64() * 2 * math.Pi
         direction := resolv.Vector{X: math.Cos(angle), Y: math.Sin(angle)}
-        npc.WanderTime = time.Now().Add(time.Second * 5)
+        npc.WanderTime = npc.Clock.Now() + time.Second*5
         npc.WanderTarget = direction
         canMove = npc.Move(direction)
     }
@@ -234,9 +423,13 @@ func (npc *Character) Wander() {
 }
 
 func (npc *Character) RunToSafety() {
-    // Find the furthest point from all monsters and move towards it
+    // Find the furthest point from all remembered monsters and move towards it
     npc.TargetMonster = nil
-    safePoint := FindSafePoint(npc.Object)
+    var threats []resolv.Vector
+    for _, entry := range npc.Perceive().Remembered("monster") {
+        threats = append(threats, entry.Position)
+    }
+    safePoint := SafePointFrom(npc.Object.Center(), threats)
     if safePoint == npc.Object.Center() {
         npc.Wander()
     } else {
@@ -245,27 +438,37 @@ func (npc *Character) RunToSafety() {
 }
 
 func (npc *Character) LookForMushroom() {
-    // Find the nearest mushroom and move towards it
-    nearestMushroom, _ := FindNearest(npc.Object, 32*6, "mushroom")
-    if nearestMushroom != nil {
-        npc.MoveTowards(nearestMushroom.Center())
+    // Go to the nearest remembered mushroom
+    nearestMushroom, _, ok := npc.Perceive().Nearest(npc.Object.Center(), "mushroom")
+    if ok {
+        npc.MoveAlongFlow(nearestMushroom.Position)
     }
 }
 
 func (npc *Character) FindMonster() {
-    // Find the nearest monster and set it as the target
-    nearestMonster, _ := FindNearest(npc.Object, 32*6, "monster")
-    if nearestMonster != nil {
-        npc.TargetMonster = nearestMonster.Data.(*Monster)
-        npc.MoveTowards(nearestMonster.Center())
+    // Pick the nearest remembered monster as the target
+    nearestMonster, _, ok := npc.Perceive().Nearest(npc.Object.Center(), "monster")
+    if !ok {
+        return
+    }
+    if monster, ok := nearestMonster.Object.Data.(*Monster); ok {
+        npc.TargetMonster = monster
+        npc.MoveAlongFlow(nearestMonster.Position)
+    }
+}
+
+// MoveTowardsTarget moves to where the target monster was last seen
+func (npc *Character) MoveTowardsTarget() {
+    if entry, ok := npc.Perceive().Recall(npc.TargetMonster.Object); ok {
+        npc.MoveTowards(entry.Position)
     }
 }
 
 func (npc *Character) AttackMonster() {
     if npc.TargetMonster != nil {
-        npc.Attack.TriggerAttack()
+        npc.Attack.TriggerAttack(npc.Clock.Now())
         if npc.Attack.IsAttacking && !npc.Attack.HasDealtDamage {
-            npc.TargetMonster.TakeDamage(npc.Attack.Damage)
+            npc.TargetMonster.TakeDamage(npc, npc.Attack.Damage)
             npc.Attack.HasDealtDamage = true
         }
     }
@@ -274,42 +477,71 @@ func (npc *Character) AttackMonster() {
 func (npc *Character) AttackDen() {
     denObj, distance := FindNearest(npc.Object, npc.Attack.Range, "goblin_den")
     if denObj != nil && distance <= npc.Attack.Range {
-        npc.Attack.TriggerAttack()
+        npc.Attack.TriggerAttack(npc.Clock.Now())
         if npc.Attack.IsAttacking && !npc.Attack.HasDealtDamage {
-            denObj.Data.(*GoblinDen).TakeDamage(npc.Attack.Damage)
+            denObj.Data.(*GoblinDen).TakeDamage(npc, npc.Attack.Damage)
             npc.Attack.HasDealtDamage = true
         }
     }
 }
 
+// MoveTowards moves one step along the NPC's cached route to the target's cell.
+// The route is only searched again when the target changes cell or gets blocked.
 func (npc *Character) MoveTowards(target resolv.Vector) {
-    npcCenter := npc.Object.Center()
-    startX, startY := npc.Object.Space.WorldToSpace(npcCenter.X, npcCenter.Y)
-
-    endX, endY := npc.Object.Space.WorldToSpace(target.X, target.Y)
+    space := npc.Object.Space
+    if npc.Route == nil || npc.Route.Space() != space {
+        npc.Route = pathfinding.NewPathFollower(space, npc.Changes)
+        npc.Route.Hierarchy = npc.Hierarchy
+    }
 
-    path, _, _ := pathfinding.FindPath(npc.Object.Space, startX, startY, endX, endY)
+    npcCenter := npc.Object.Center()
+    startX, startY := space.WorldToSpace(npcCenter.X, npcCenter.Y)
+    endX, endY := space.WorldToSpace(target.X, target.Y)
+    nextX, nextY, hasNext := npc.Route.Next(startX, startY, endX, endY)
+    if len(npc.Route.Path) == 0 {
+        return
+    }
+    npc.stepThroughCell(startX, startY, nextX, nextY, hasNext)
+}
 
-    if len(path) == 0 {
+// MoveAlongFlow moves towards a target that other NPCs may share by sampling the cached
+// flow field towards its cell. Without a flow field cache it falls back to MoveTowards.
+func (npc *Character) MoveAlongFlow(target resolv.Vector) {
+    if npc.FlowFields == nil {
+        npc.MoveTowards(target)
         return
     }
+    space := npc.Object.Space
+    field := npc.FlowFields.Field(space.WorldToSpace(target.X, target.Y))
+    x, y := space.WorldToSpaceVec(npc.Object.Center())
+    if _, ok := field.Distance(x, y); !ok {
+        return
+    }
+    nextX, nextY, hasNext := field.Next(x, y)
+    npc.stepThroughCell(x, y, nextX, nextY, hasNext)
+}
 
-    lastStep := path[len(path)-1].(pathfinding.PathNode)
-    lastStepWorld := npc.Object.Space.SpaceToWorldVec(lastStep.X, lastStep.Y)
-    nextTarget := lastStepWorld
-    if len(path) > 1 {
-        secondLastStep := path[len(path)-2].(pathfinding.PathNode)
-        secondLastStepWorld := npc.Object.Space.SpaceToWorldVec(secondLastStep.X, secondLastStep.Y)
-        // Check if NPC has surpassed the last target
-        if checkIfPassed(npc.Object.Position, lastStepWorld, secondLastStepWorld) {
-            nextTarget = secondLastStepWorld
-        }
+// stepThroughCell moves towards the center of the route cell the NPC is in, or on to
+// the next cell of the route once it has passed the current one
+func (npc *Character) stepThroughCell(x, y, nextX, nextY int, hasNext bool) {
+    npc.MoveToPoint(routeWaypoint(npc.Object, x, y, nextX, nextY, hasNext))
+}
 
+// routeWaypoint returns the point an object walking a route heads to: the center of the
+// route cell it is in, or of the next cell once it has passed the current one
+func routeWaypoint(obj *resolv.Object, x, y, nextX, nextY int, hasNext bool) resolv.Vector {
+    current := obj.Space.SpaceToWorldVec(x, y)
+    nextTarget := current
+    if hasNext {
+        next := obj.Space.SpaceToWorldVec(nextX, nextY)
+        // Check if the object has surpassed the current cell
+        if checkIfPassed(obj.Position, current, next) {
+            nextTarget = next
+        }
     }
 
     halfCell := resolv.Vector{X: float64(gamemap.TileSize / 2), Y: float64(gamemap.TileSize / 2)}
-    nextTarget = nextTarget.Add(halfCell)
-    npc.MoveToPoint(nextTarget)
+    return nextTarget.Add(halfCell)
 }
 
 func checkIfPassed(position, vecStep1, vecStep2 resolv.Vector) bool {
@@ -323,9 +555,8 @@ func checkIfPassed(position, vecStep1, vecStep2 resolv.Vector) bool {
 }
 
 func (npc *Character) MoveTowardsDen() {
-    denObj, _ := FindNearest(npc.Object, 6*32, "goblin_den")
-    if denObj != nil {
-        direction := denObj.Center().Sub(npc.Object.Center()).Unit()
-        npc.Move(direction)
+    den, _, ok := npc.Perceive().Nearest(npc.Object.Center(), "goblin_den")
+    if ok {
+        npc.MoveAlongFlow(den.Position)
     }
 }
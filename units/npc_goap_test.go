@@ -1,6 +1,7 @@
 package units
 
 import (
+    "example.com/maj/events"
     gamemap "example.com/maj/map"
     "fmt"
     "github.com/solarlune/resolv"
@@ -111,12 +112,12 @@ func Test_GOAPBehaviors(t *testing.T) {
         goalState := npc.GenerateGOAPGoal(currentState)
 
         fmt.Println(currentState, goalState)
-        npc.CurrentPlan = npc.Planner.Plan(currentState, goalState)
-        if npc.CurrentPlan == nil {
+        plan := npc.Planner.Plan(currentState, goalState)
+        if plan == nil {
             return
         }
 
-        action := npc.CurrentPlan[0]
+        action := plan[0]
         fmt.Println(npc.Name, action)
         npc.ExecuteGOAPAction(action)
 
@@ -140,10 +141,10 @@ func Test_GOAPBehaviors(t *testing.T) {
             goalState := npc.GenerateGOAPGoal(currentState)
 
             fmt.Println(currentState, goalState)
-            npc.CurrentPlan = npc.Planner.Plan(currentState, goalState)
-            assert.NotNil(t, npc.CurrentPlan, "Current plan is nil")
+            plan := npc.Planner.Plan(currentState, goalState)
+            assert.NotNil(t, plan, "Current plan is nil")
 
-            action := npc.CurrentPlan[0]
+            action := plan[0]
             fmt.Println(npc.Name, action, npc.Object.Center())
             npc.ExecuteGOAPAction(action)
 
@@ -157,4 +158,38 @@ func Test_GOAPBehaviors(t *testing.T) {
         }
     })
 
+    t.Run("Plan changes are published", func(t *testing.T) {
+        space, npc := InitSpace(96, 96)
+        NewMushroom(space, 192, 96)
+        npc.Health = 60
+        npc.Events = events.NewBus()
+        var changes []PlanChanged
+        events.Subscribe(npc.Events, func(e PlanChanged) { changes = append(changes, e) })
+
+        npc.Update()
+        npc.Update()
+        assert.Len(t, changes, 1)
+        assert.Equal(t, "Heal", changes[0].Goal)
+        assert.Equal(t, planNames(npc.Executor.Plan), changes[0].Plan)
+    })
+
+    t.Run("Test plan is kept across ticks", func(t *testing.T) {
+        space, npc := InitSpace(96, 96)
+        NewMushroom(space, 192, 96)
+        npc.Health = 60
+
+        npc.Update()
+        assert.Equal(t, "Heal", npc.Executor.Goal.Name)
+        assert.Equal(t, LookForMushroom, npc.Executor.Plan[0].Name)
+        plan := npc.Executor.Plan
+
+        for i := 0; i < 60 && npc.MushroomsEaten == 0; i++ {
+            npc.Update()
+            if npc.MushroomsEaten == 0 {
+                assert.Same(t, &plan[len(plan)-1], &npc.Executor.Plan[len(npc.Executor.Plan)-1], "Iter %v plan was replaced", i)
+            }
+        }
+        assert.Equal(t, 1, npc.MushroomsEaten, "NPC didn't eat the mushroom")
+    })
+
 }
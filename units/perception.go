@@ -0,0 +1,194 @@
+package units
+
+import (
+    gamemap "example.com/maj/map"
+    "github.com/solarlune/resolv"
+    "math"
+    "time"
+)
+
+// perceivedTags are the kinds of objects an NPC notices and remembers
+var perceivedTags = []string{"monster", "mushroom", "goblin_den"}
+
+// MemoryEntry is the last known whereabouts of an object
+type MemoryEntry struct {
+    Object *resolv.Object
+    Tag    string
+    // Position is the center of the object when it was last seen
+    Position resolv.Vector
+    LastSeen time.Duration
+    // Visible is true when the object was seen on the last perception update
+    Visible bool
+}
+
+// Perception is what an NPC sees and remembers of its surroundings.
+// Objects are seen within SightRadius when no mountain is in the way, and are
+// remembered at their last seen position for MemoryDuration.
+type Perception struct {
+    SightRadius    float64
+    MemoryDuration time.Duration
+    // Memory holds remembered objects in the order they were first seen
+    Memory []MemoryEntry
+
+    sensed   bool
+    sensedAt uint64
+}
+
+// NewPerception creates a perception with the given sight radius and memory span
+func NewPerception(sightRadius float64, memoryDuration time.Duration) *Perception {
+    return &Perception{
+        SightRadius:    sightRadius,
+        MemoryDuration: memoryDuration,
+    }
+}
+
+// Perceive refreshes the NPC's memory with what it can see, at most once per tick.
+// Objects that are not where they were remembered, or were not seen for longer than
+// the memory span, are forgotten.
+func (npc *Character) Perceive() *Perception {
+    p := npc.Perception
+    tick := npc.Clock.Tick()
+    if p.sensed && p.sensedAt == tick {
+        return p
+    }
+    p.sensed, p.sensedAt = true, tick
+    now := npc.Clock.Now()
+
+    for i := range p.Memory {
+        p.Memory[i].Visible = false
+    }
+    center := npc.Object.Center()
+    r := p.SightRadius
+    seen := make(map[*resolv.Object]bool)
+    for _, obj := range npc.Object.Space.CheckWorld(center.X-r, center.Y-r, 2*r, 2*r, perceivedTags...) {
+        if seen[obj] || !p.canSee(npc.Object, obj.Center(), obj) {
+            continue
+        }
+        seen[obj] = true
+        entry := p.remember(obj)
+        entry.Position = obj.Center()
+        entry.LastSeen = now
+        entry.Visible = true
+    }
+
+    kept := p.Memory[:0]
+    for _, entry := range p.Memory {
+        if !entry.Visible {
+            if now-entry.LastSeen > p.MemoryDuration {
+                continue
+            }
+            // The remembered spot is in plain sight but the object is gone
+            if p.canSee(npc.Object, entry.Position, nil) {
+                continue
+            }
+        }
+        kept = append(kept, entry)
+    }
+    p.Memory = kept
+    return p
+}
+
+// remember returns the memory entry of an object, adding one if it is new
+func (p *Perception) remember(obj *resolv.Object) *MemoryEntry {
+    for i := range p.Memory {
+        if p.Memory[i].Object == obj {
+            return &p.Memory[i]
+        }
+    }
+    tag := ""
+    for _, t := range perceivedTags {
+        if obj.HasTags(t) {
+            tag = t
+            break
+        }
+    }
+    p.Memory = append(p.Memory, MemoryEntry{Object: obj, Tag: tag})
+    return &p.Memory[len(p.Memory)-1]
+}
+
+// canSee reports whether a point is within sight radius and no mountain cell lies
+// on the line from the viewer to it. Cells occupied by target itself do not block.
+func (p *Perception) canSee(viewer *resolv.Object, point resolv.Vector, target *resolv.Object) bool {
+    from := viewer.Center()
+    if from.Distance(point) > p.SightRadius {
+        return false
+    }
+    return LineOfSight(viewer.Space, from, point, viewer, target)
+}
+
+// LineOfSight reports whether no mountain lies on the straight line between two points.
+// Cells containing one of the ignored objects are skipped.
+func LineOfSight(space *resolv.Space, from, to resolv.Vector, ignore ...*resolv.Object) bool {
+    step := float64(gamemap.TileSize) / 4
+    steps := int(math.Ceil(from.Distance(to) / step))
+    var last *resolv.Cell
+    for i := 0; i <= steps; i++ {
+        t := 1.0
+        if steps > 0 {
+            t = float64(i) / float64(steps)
+        }
+        cell := space.Cell(space.WorldToSpace(from.X+(to.X-from.X)*t, from.Y+(to.Y-from.Y)*t))
+        if cell == nil || cell == last {
+            continue
+        }
+        last = cell
+        if !cell.ContainsTags("mountain") || containsAny(cell, ignore) {
+            continue
+        }
+        return false
+    }
+    return true
+}
+
+func containsAny(cell *resolv.Cell, objects []*resolv.Object) bool {
+    for _, obj := range objects {
+        if obj != nil && cell.Contains(obj) {
+            return true
+        }
+    }
+    return false
+}
+
+// Remembered returns the remembered objects with the tag, in the order they were first seen
+func (p *Perception) Remembered(tag string) []MemoryEntry {
+    var entries []MemoryEntry
+    for _, entry := range p.Memory {
+        if entry.Tag == tag {
+            entries = append(entries, entry)
+        }
+    }
+    return entries
+}
+
+// Recall returns the memory entry of an object
+func (p *Perception) Recall(obj *resolv.Object) (MemoryEntry, bool) {
+    for _, entry := range p.Memory {
+        if entry.Object == obj {
+            return entry, true
+        }
+    }
+    return MemoryEntry{}, false
+}
+
+// Nearest returns the remembered object with the tag closest to a point
+func (p *Perception) Nearest(from resolv.Vector, tag string) (MemoryEntry, float64, bool) {
+    var nearest MemoryEntry
+    minDistance := math.Inf(1)
+    for _, entry := range p.Memory {
+        if entry.Tag != tag {
+            continue
+        }
+        if distance := from.Distance(entry.Position); distance < minDistance {
+            nearest, minDistance = entry, distance
+        }
+    }
+    return nearest, minDistance, !math.IsInf(minDistance, 1)
+}
+
+// Confidence is 1 for a visible object and falls to 0 as its memory fades
+func (p *Perception) Confidence(entry MemoryEntry, now time.Duration) float64 {
+    if entry.Visible || p.MemoryDuration <= 0 {
+        return 1
+    }
+    return math.Max(0, 1-float64(now-entry.LastSeen)/float64(p.MemoryDuration))
+}
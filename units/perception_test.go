@@ -0,0 +1,62 @@
+package units
+
+import (
+    "github.com/stretchr/testify/assert"
+    "testing"
+    "time"
+)
+
+func TestPerception(t *testing.T) {
+    t.Run("Mountains block line of sight", func(t *testing.T) {
+        space, npc := InitSpace(64, 64)
+        NewMushroom(space, 192, 64)
+        NewMountain(space, 128, 64)
+
+        assert.Empty(t, npc.Perceive().Memory)
+        assert.False(t, npc.IsMushroomNear())
+    })
+
+    t.Run("Objects outside sight radius are not seen", func(t *testing.T) {
+        space, npc := InitSpace(64, 64)
+        NewMushroom(space, 64+7*32, 64)
+
+        assert.False(t, npc.IsMushroomNear())
+    })
+
+    t.Run("A mushroom out of sight is remembered until memory fades", func(t *testing.T) {
+        space, npc := InitSpace(64, 64)
+        mushroom := NewMushroom(space, 128, 64)
+        assert.True(t, npc.IsMushroomNear())
+
+        NewMountain(space, 96, 64)
+        npc.Clock.Advance()
+        entry, ok := npc.Perceive().Recall(mushroom.Object)
+        assert.True(t, ok)
+        assert.False(t, entry.Visible)
+        assert.True(t, npc.IsMushroomNear())
+
+        for npc.Clock.Now() <= npc.Perception.MemoryDuration {
+            npc.Clock.Advance()
+        }
+        assert.False(t, npc.IsMushroomNear())
+    })
+
+    t.Run("A remembered spot in plain sight is forgotten when the object is gone", func(t *testing.T) {
+        space, npc := InitSpace(64, 64)
+        mushroom := NewMushroom(space, 128, 64)
+        assert.True(t, npc.IsMushroomNear())
+
+        space.Remove(mushroom.Object)
+        npc.Clock.Advance()
+        assert.False(t, npc.IsMushroomNear())
+    })
+
+    t.Run("Confidence fades with time since last seen", func(t *testing.T) {
+        p := NewPerception(6*32, 10*time.Second)
+        entry := MemoryEntry{LastSeen: 2 * time.Second}
+
+        assert.Equal(t, 1.0, p.Confidence(MemoryEntry{Visible: true}, time.Minute))
+        assert.InDelta(t, 0.5, p.Confidence(entry, 7*time.Second), 1e-9)
+        assert.Equal(t, 0.0, p.Confidence(entry, time.Minute))
+    })
+}
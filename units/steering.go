@@ -0,0 +1,57 @@
+package units
+
+import (
+    "example.com/maj/steering"
+    "github.com/solarlune/resolv"
+    "math/rand"
+)
+
+// agentRadius is the radius units keep clear of each other, half a tile
+const agentRadius = float64(32) / 2
+
+// steeringAgent describes a living character or monster for the steering of its neighbors
+func steeringAgent(obj *resolv.Object) (steering.Agent, bool) {
+    var velocity resolv.Vector
+    switch unit := obj.Data.(type) {
+    case *Character:
+        if unit.Health <= 0 {
+            return steering.Agent{}, false
+        }
+        velocity = unit.Velocity
+    case *Monster:
+        if unit.Health <= 0 {
+            return steering.Agent{}, false
+        }
+        velocity = unit.Velocity
+    default:
+        return steering.Agent{}, false
+    }
+    return steering.Agent{Position: obj.Center(), Velocity: velocity, Radius: agentRadius}, true
+}
+
+// neighborAgents returns the living characters and monsters around an object
+func neighborAgents(obj *resolv.Object, radius float64) []steering.Agent {
+    center := obj.Center()
+    var neighbors []steering.Agent
+    seen := make(map[*resolv.Object]bool)
+    for _, other := range obj.Space.CheckWorld(center.X-radius, center.Y-radius, 2*radius, 2*radius, "character", "monster") {
+        if other == obj || seen[other] {
+            continue
+        }
+        seen[other] = true
+        if agent, ok := steeringAgent(other); ok && agent.Position.Distance(center) <= radius {
+            neighbors = append(neighbors, agent)
+        }
+    }
+    return neighbors
+}
+
+// steer picks the velocity an object moves with instead of the preferred one so that it keeps
+// clear of its neighbors. Velocities that collide with objects of the blocking tags are not chosen.
+func steer(cfg steering.Config, obj *resolv.Object, velocity, preferred resolv.Vector, maxSpeed float64, rng *rand.Rand, blocking ...string) resolv.Vector {
+    self := steering.Agent{Position: obj.Center(), Velocity: velocity, Radius: agentRadius}
+    free := func(v resolv.Vector) bool {
+        return obj.Check(v.X, v.Y, blocking...) == nil
+    }
+    return cfg.Steer(self, preferred, maxSpeed, neighborAgents(obj, cfg.NeighborRadius), free, rng)
+}
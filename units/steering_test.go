@@ -0,0 +1,57 @@
+package units
+
+import (
+    "example.com/maj/sim"
+    "fmt"
+    "github.com/solarlune/resolv"
+    "github.com/stretchr/testify/assert"
+    "testing"
+)
+
+func TestSteering(t *testing.T) {
+    t.Run("NPCs spawned on the same spot spread out", func(t *testing.T) {
+        space := resolv.NewSpace(1000, 1000, 32, 32)
+        clock, rng := sim.NewClock(), sim.NewRand(1)
+        var npcs []*Character
+        for i := 0; i < 5; i++ {
+            npc := NewCharacter(320, 320, fmt.Sprintf("NPC%d", i))
+            npc.Clock, npc.Rand = clock, rng
+            space.Add(npc.Object)
+            npcs = append(npcs, npc)
+        }
+
+        for i := 0; i < 60; i++ {
+            clock.Advance()
+            for _, npc := range npcs {
+                npc.Update()
+            }
+        }
+        for i, a := range npcs {
+            for _, b := range npcs[i+1:] {
+                assert.Greater(t, a.Object.Center().Distance(b.Object.Center()), agentRadius, "%s and %s", a.Name, b.Name)
+            }
+        }
+    })
+
+    t.Run("Monsters do not walk through each other", func(t *testing.T) {
+        space := resolv.NewSpace(1000, 1000, 32, 32)
+        a := NewMonster(100, 300, nil)
+        b := NewMonster(400, 300, nil)
+        b.Clock = a.Clock
+        space.Add(a.Object)
+        space.Add(b.Object)
+
+        closest := a.Object.Center().Distance(b.Object.Center())
+        for i := 0; i < 300; i++ {
+            a.Clock.Advance()
+            a.MoveToPoint(resolv.NewVector(416, 316))
+            b.MoveToPoint(resolv.NewVector(116, 316))
+            if d := a.Object.Center().Distance(b.Object.Center()); d < closest {
+                closest = d
+            }
+        }
+        assert.GreaterOrEqual(t, closest, 2*agentRadius)
+        assert.Greater(t, a.Object.Center().X, 300.0)
+        assert.Less(t, b.Object.Center().X, 200.0)
+    })
+}